@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+	"telegrambot/internal/e"
+	"telegrambot/internal/logging"
+	"telegrambot/pkg/access"
+	tgClient "telegrambot/pkg/clients/telegram"
+	"telegrambot/pkg/deadletter"
+	"telegrambot/pkg/events/telegram"
+	"telegrambot/pkg/instrumented"
+)
+
+// replayDLQ processes updates kept in the dead letter queue again with the
+// configured bot and storage. Updates which fail again stay in the queue.
+func replayDLQ(ctx context.Context, fs *flag.FlagSet, args []string) (err error) {
+	defer func() {
+		err = e.WrapIfErr("can't replay dead letters", err)
+	}()
+
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	cfg, err := loadConfig(fs, args)
+	if err != nil {
+		return err
+	}
+	if cfg.DLQ.File == "" {
+		return errors.New("dead letter queue is disabled, set dlq.file")
+	}
+	if err := cfg.ValidateTelegram(); err != nil {
+		return fmt.Errorf("invalid config:\n%w", err)
+	}
+
+	queue, err := deadletter.New(cfg.DLQ.File)
+	if err != nil {
+		return err
+	}
+
+	storage, err := newRepository(ctx, cfg.Storage)
+	if err != nil {
+		return err
+	}
+
+	cache, err := newCache(ctx, cfg.State)
+	if err != nil {
+		return err
+	}
+
+	tg, err := tgClient.NewWithURL(cfg.Telegram.Scheme+"://"+cfg.Telegram.Host, cfg.Telegram.Token.Value())
+	if err != nil {
+		return err
+	}
+
+	bot, err := tg.GetMe(ctx)
+	if err != nil {
+		return e.Wrap("can't get bot info", err)
+	}
+
+	// limits are left out: the updates are replayed faster than they were
+	// sent, and throttled ones would be dropped
+	processor := telegram.New(tg, storage, instrumented.NewCache(cache), telegram.Options{
+		UndoWindow:    cfg.Pages.UndoWindow,
+		BotUsername:   bot.Username,
+		Access:        access.New(cfg.Access.Allowed, cfg.Access.Denied, cfg.Access.Admins, storage),
+		BroadcastRate: cfg.Limits.BroadcastRate,
+	})
+
+	stats, err := queue.Replay(ctx, func(ctx context.Context, u tgClient.Update) error {
+		err := processor.ProcessUpdate(ctx, u)
+		if err != nil {
+			slog.Warn("update failed again", "update_id", u.UpdateId, logging.Err(err))
+		}
+		return err
+	})
+
+	// broadcasts started by replayed commands are stopped
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+
+	if sErr := processor.Shutdown(shutdownCtx); sErr != nil {
+		slog.Error("can't stop processor", logging.Err(sErr))
+	}
+
+	if err := saveSnapshot(storage, cfg.Storage); err != nil {
+		slog.Error("can't save memory storage", logging.Err(err))
+	}
+
+	fmt.Printf("replayed %d updates, %d failed again and are kept in %s\n", stats.Replayed, stats.Failed, cfg.DLQ.File)
+
+	return err
+}
@@ -2,39 +2,209 @@ package main
 
 import (
 	"context"
-	"log"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"slices"
+	"strings"
 	"telegrambot/internal/config"
-	tgClient "telegrambot/pkg/clients/telegram"
-	eventConsumer "telegrambot/pkg/consumer/event-consumer"
-	"telegrambot/pkg/events/telegram"
+	"telegrambot/internal/logging"
+	"telegrambot/pkg/repository"
+	"telegrambot/pkg/repository/files"
+	"telegrambot/pkg/repository/memory"
+	"telegrambot/pkg/repository/postgres"
 	"telegrambot/pkg/repository/sqlite"
+	"telegrambot/pkg/state"
+	stateMemory "telegrambot/pkg/state/memory"
 	"telegrambot/pkg/state/redis"
+	stateSqlite "telegrambot/pkg/state/sqlite"
 )
 
+type command struct {
+	name  string
+	args  string
+	about string
+	run   func(ctx context.Context, fs *flag.FlagSet, args []string) error
+}
+
+var commands = []command{
+	{name: "serve", about: "run the bot (default)", run: serve},
+	{name: "migrate", about: "create or upgrade the storage schema", run: migrate},
+	{name: "export", args: "--user <owner> [--file <path>]", about: "write pages of a list as JSON lines", run: exportPages},
+	{name: "import", args: "--user <owner> --file <path>", about: "add pages from an export to a list", run: importPages},
+	{name: "stats", args: "[--user <owner>]", about: "show statistics of a list or of all users", run: stats},
+	{name: "users list", about: "show known users", run: listUsers},
+	{name: "convert-storage", args: "--to.backend <backend> [--to.sqlite_path|--to.files_path <path>] [--checkpoint <path>]", about: "copy the storage to another backend (postgres from TO_POSTGRES_DSN), resumable with --checkpoint", run: convertStorage},
+	{name: "replay", args: "--file <path> [--out <path>] [--snapshot <path>] [--bot <username>]", about: "feed recorded updates to the bot talking to a fake Telegram and show its replies", run: replay},
+	{name: "dlq replay", about: "process updates kept in the dead letter queue again", run: replayDLQ},
+}
+
 func main() {
+	args := os.Args[1:]
+	if len(args) > 0 && (args[0] == "help" || args[0] == "-h" || args[0] == "--help") {
+		usage()
+		return
+	}
 
-	cfg := config.MustLoad()
-	log.Printf("%v", cfg)
+	cmd, args := findCommand(args)
+	if cmd == nil {
+		usage()
+		os.Exit(2)
+	}
 
-	//rep := files.New(cfg.FilesRepositoryPath)
-	rep, err := sqlite.New(cfg.SqliteRepositoryPath)
+	fs := flag.NewFlagSet("telegrambot "+cmd.name, flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: telegrambot %s %s [config flags]\n", cmd.name, cmd.args)
+		fs.PrintDefaults()
+	}
+
+	err := cmd.run(context.Background(), fs, args)
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
 	if err != nil {
-		log.Fatal("can't connect to repository: ", err)
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+// findCommand returns the command named by the first arguments and the rest
+// of them. Without a command the bot is served, as it used to.
+func findCommand(args []string) (*command, []string) {
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		return &commands[0], args
+	}
+
+	for i, c := range commands {
+		words := strings.Fields(c.name)
+		if len(args) >= len(words) && slices.Equal(args[:len(words)], words) {
+			return &commands[i], args[len(words):]
+		}
 	}
 
-	red := redis.New(cfg)
+	return nil, args
+}
 
-	err = rep.Init(context.Background())
+func usage() {
+	fmt.Fprintln(os.Stderr, "Usage: telegrambot <command> [flags]")
+	fmt.Fprintln(os.Stderr, "\nCommands:")
+	for _, c := range commands {
+		fmt.Fprintf(os.Stderr, "  %-16s %s\n", c.name, c.about)
+	}
+	fmt.Fprintln(os.Stderr, "\nRun telegrambot <command> -h to see the flags of a command.")
+	fmt.Fprintln(os.Stderr, "Secrets have no flags: set them in the config file, environment variables or files named by <ENV>_FILE.")
+}
+
+// loadConfig reads the config shared by all commands and sets up logging.
+func loadConfig(fs *flag.FlagSet, args []string) (*config.Config, error) {
+	cfg, err := config.Load(fs, args)
 	if err != nil {
-		log.Fatal("can't init repository: ", err)
+		return nil, err
 	}
 
-	eventProcessor := telegram.New(tgClient.New(cfg.TgBotHost, cfg.TgBotToken), rep, red)
+	logger, err := newLogger(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("can't create logger: %w", err)
+	}
+	slog.SetDefault(logger)
 
-	log.Println("service started")
+	slog.Debug("config loaded", "config", fmt.Sprintf("%+v", *cfg))
+
+	return cfg, nil
+}
 
-	consumer := eventConsumer.New(eventProcessor, eventProcessor, 100)
-	if err := consumer.Start(); err != nil {
-		log.Fatal("service is stopped", err)
+// pinger is implemented by backends that can report whether they are reachable.
+type pinger interface {
+	Ping(ctx context.Context) error
+}
+
+func newRepository(ctx context.Context, cfg config.StorageConfig) (repository.Repository, error) {
+	switch cfg.Backend {
+	case config.StorageFiles:
+		return files.New(cfg.FilesPath), nil
+	case config.StorageMemory:
+		m := memory.New()
+		if cfg.MemorySnapshot != "" {
+			if err := m.LoadSnapshot(cfg.MemorySnapshot); err != nil {
+				return nil, err
+			}
+		}
+
+		return m, nil
+	case config.StoragePostgres:
+		db, err := postgres.New(cfg.Postgres.DSN.Value(), postgres.Options{
+			MaxOpenConns:    cfg.Postgres.MaxOpenConns,
+			MaxIdleConns:    cfg.Postgres.MaxIdleConns,
+			ConnMaxLifetime: cfg.Postgres.ConnMaxLifetime,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		if err := db.Init(ctx); err != nil {
+			return nil, err
+		}
+
+		return db, nil
+	default:
+		db, err := sqlite.New(cfg.SqlitePath)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := db.Init(ctx); err != nil {
+			return nil, err
+		}
+
+		return db, nil
 	}
 }
+
+// saveSnapshot keeps the memory storage until the next start, other
+// storages keep everything anyway.
+func saveSnapshot(rep repository.Repository, cfg config.StorageConfig) error {
+	m, ok := rep.(*memory.RepositoryMemory)
+	if !ok || cfg.MemorySnapshot == "" {
+		return nil
+	}
+
+	return m.SaveSnapshot(cfg.MemorySnapshot)
+}
+
+func newCache(ctx context.Context, cfg config.StateConfig) (state.Cache, error) {
+	switch cfg.Backend {
+	case config.StateMemory:
+		return stateMemory.New(), nil
+	case config.StateSQLite:
+		return stateSqlite.New(ctx, cfg.SqlitePath)
+	default:
+		return redis.New(cfg.Redis), nil
+	}
+}
+
+func newLogger(cfg *config.Config) (*slog.Logger, error) {
+	level, err := logging.ParseLevel(cfg.Log.Level)
+	if err != nil {
+		return nil, err
+	}
+
+	return logging.New(os.Stderr, logging.Options{
+		Level:  level,
+		Format: cfg.Log.Format,
+		Secrets: []string{
+			cfg.Telegram.Token.Value(),
+			cfg.State.Redis.Password.Value(),
+			cfg.AdminAPI.Token.Value(),
+			cfg.Storage.Postgres.DSN.Value(),
+			cfg.Record.Salt.Value(),
+		},
+	})
+}
+
+// fatal stops the bot when one of its servers fails.
+func fatal(msg string, err error) {
+	slog.Error(msg, logging.Err(err))
+	os.Exit(1)
+}
@@ -0,0 +1,218 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"telegrambot/internal/e"
+	"telegrambot/pkg/export"
+	"telegrambot/pkg/repository"
+	"text/tabwriter"
+	"time"
+)
+
+// exportPages writes all pages of the list including read and removed ones.
+func exportPages(ctx context.Context, fs *flag.FlagSet, args []string) (err error) {
+	defer func() {
+		err = e.WrapIfErr("can't export pages", err)
+	}()
+
+	owner := fs.String("user", "", "owner of the list: username, user-<id>, chat<id> or collection-<id>")
+	path := fs.String("file", "-", "file to write, - for stdout")
+
+	cfg, err := loadConfig(fs, args)
+	if err != nil {
+		return err
+	}
+	if *owner == "" {
+		return errors.New("--user is required")
+	}
+
+	rep, err := newRepository(ctx, cfg.Storage)
+	if err != nil {
+		return err
+	}
+
+	pages, err := rep.Pages(ctx, *owner)
+	if err != nil {
+		return err
+	}
+
+	var w io.Writer = os.Stdout
+	if *path != "-" {
+		f, err := os.Create(*path)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			if cErr := f.Close(); cErr != nil && err == nil {
+				err = cErr
+			}
+		}()
+		w = f
+	}
+
+	if err := export.Write(w, pages); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(os.Stderr, "exported %d pages\n", len(pages))
+
+	return nil
+}
+
+// importPages saves pages of an export to the list, replacing the saved
+// pages with the same urls.
+func importPages(ctx context.Context, fs *flag.FlagSet, args []string) (err error) {
+	defer func() {
+		err = e.WrapIfErr("can't import pages", err)
+	}()
+
+	owner := fs.String("user", "", "owner of the list: username, user-<id>, chat<id> or collection-<id>")
+	path := fs.String("file", "", "file written by export, - for stdin")
+
+	cfg, err := loadConfig(fs, args)
+	if err != nil {
+		return err
+	}
+	if *owner == "" || *path == "" {
+		return errors.New("--user and --file are required")
+	}
+
+	rep, err := newRepository(ctx, cfg.Storage)
+	if err != nil {
+		return err
+	}
+
+	var r io.Reader = os.Stdin
+	if *path != "-" {
+		f, err := os.Open(*path)
+		if err != nil {
+			return err
+		}
+		defer func() { _ = f.Close() }()
+		r = f
+	}
+
+	n := 0
+	err = export.Read(r, *owner, func(p *repository.Page) error {
+		n++
+		return rep.Save(ctx, p)
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := saveSnapshot(rep, cfg.Storage); err != nil {
+		return err
+	}
+
+	fmt.Printf("imported %d pages\n", n)
+
+	return nil
+}
+
+// stats shows statistics of a list, or a summary of lists of all users.
+func stats(ctx context.Context, fs *flag.FlagSet, args []string) (err error) {
+	defer func() {
+		err = e.WrapIfErr("can't get stats", err)
+	}()
+
+	owner := fs.String("user", "", "owner of the list, all users if empty")
+
+	cfg, err := loadConfig(fs, args)
+	if err != nil {
+		return err
+	}
+
+	rep, err := newRepository(ctx, cfg.Storage)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+
+	if *owner != "" {
+		s, err := rep.Stats(ctx, *owner, now)
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("total:           %d\n", s.Total)
+		fmt.Printf("unread:          %d\n", s.Unread)
+		fmt.Printf("read this week:  %d\n", s.ReadThisWeek)
+		fmt.Printf("avg unread age:  %s\n", s.AvgUnreadAge.Round(time.Hour))
+		printCounts("top domains:", s.TopDomains)
+		printCounts("top tags:", s.TopTags)
+
+		return nil
+	}
+
+	users, err := rep.Users(ctx)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "OWNER\tTOTAL\tUNREAD\tREAD THIS WEEK")
+	for _, u := range users {
+		s, err := rep.Stats(ctx, u.PagesOwner(), now)
+		if err != nil {
+			return err
+		}
+
+		fmt.Fprintf(w, "%s\t%d\t%d\t%d\n", u.PagesOwner(), s.Total, s.Unread, s.ReadThisWeek)
+	}
+
+	return w.Flush()
+}
+
+func printCounts(title string, counts []repository.Count) {
+	if len(counts) == 0 {
+		return
+	}
+
+	fmt.Println(title)
+	for _, c := range counts {
+		fmt.Printf("  %-30s %d\n", c.Name, c.Count)
+	}
+}
+
+// listUsers shows the registry of users who have written to the bot.
+func listUsers(ctx context.Context, fs *flag.FlagSet, args []string) (err error) {
+	defer func() {
+		err = e.WrapIfErr("can't list users", err)
+	}()
+
+	cfg, err := loadConfig(fs, args)
+	if err != nil {
+		return err
+	}
+
+	rep, err := newRepository(ctx, cfg.Storage)
+	if err != nil {
+		return err
+	}
+
+	users, err := rep.Users(ctx)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tUSERNAME\tFIRST NAME\tPRIVATE CHAT\tFIRST SEEN\tLAST SEEN\tBLOCKED")
+	for _, u := range users {
+		blocked := ""
+		if u.IsBlocked() {
+			blocked = u.BlockedAt.Format(time.DateTime)
+		}
+
+		fmt.Fprintf(w, "%d\t%s\t%s\t%d\t%s\t%s\t%s\n", u.ID, u.Username, u.FirstName, u.ChatID,
+			u.FirstSeen.Format(time.DateTime), u.LastSeen.Format(time.DateTime), blocked)
+	}
+
+	return w.Flush()
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"telegrambot/internal/e"
+	tgClient "telegrambot/pkg/clients/telegram"
+	"telegrambot/pkg/clients/telegram/telegramtest"
+	"telegrambot/pkg/events/telegram"
+	"telegrambot/pkg/recorder"
+	"telegrambot/pkg/repository/memory"
+	stateMemory "telegrambot/pkg/state/memory"
+)
+
+// replayResult is what the bot did in reply to a recorded update.
+type replayResult struct {
+	UpdateID int             `json:"update_id"`
+	Messages []replayMessage `json:"messages,omitempty"`
+	Edits    []replayMessage `json:"edits,omitempty"`
+	Answers  []string        `json:"answers,omitempty"`
+	Error    string          `json:"error,omitempty"`
+}
+
+type replayMessage struct {
+	ChatID      int             `json:"chat_id"`
+	MessageID   int             `json:"message_id"`
+	Text        string          `json:"text"`
+	ReplyMarkup json.RawMessage `json:"reply_markup,omitempty"`
+}
+
+// replay feeds a recording to the bot talking to a fake Telegram and writes
+// what the bot did for every update as JSON lines, so that the output of two
+// versions can be compared. Pages and state are kept in memory, the
+// configured storages are never touched.
+func replay(ctx context.Context, fs *flag.FlagSet, args []string) (err error) {
+	defer func() {
+		err = e.WrapIfErr("can't replay updates", err)
+	}()
+
+	path := fs.String("file", "", "recording, - for stdin")
+	out := fs.String("out", "-", "file to write results to, - for stdout")
+	snapshot := fs.String("snapshot", "", "memory storage snapshot to start from")
+	botName := fs.String("bot", "", "username of the recorded bot, so that commands like /rnd@bot are recognized")
+
+	cfg, err := loadConfig(fs, args)
+	if err != nil {
+		return err
+	}
+	if *path == "" {
+		return errors.New("--file is required")
+	}
+
+	srv := telegramtest.NewServer()
+	defer srv.Close()
+
+	if *botName != "" {
+		srv.Bot.Username = *botName
+	}
+
+	tg, err := tgClient.NewWithURL(srv.URL, telegramtest.Token)
+	if err != nil {
+		return err
+	}
+
+	storage := memory.New()
+	if *snapshot != "" {
+		if err := storage.LoadSnapshot(*snapshot); err != nil {
+			return err
+		}
+	}
+
+	// limits are left out: the recording is replayed faster than it was sent
+	processor := telegram.New(tg, storage, stateMemory.New(), telegram.Options{
+		UndoWindow:    cfg.Pages.UndoWindow,
+		BotUsername:   srv.Bot.Username,
+		BroadcastRate: cfg.Limits.BroadcastRate,
+	})
+
+	var r io.Reader = os.Stdin
+	if *path != "-" {
+		f, err := os.Open(*path)
+		if err != nil {
+			return err
+		}
+		defer func() { _ = f.Close() }()
+		r = f
+	}
+
+	// the fake numbers updates itself, as a recording may have gaps or
+	// several runs of the bot
+	var recordedIDs []int
+	err = recorder.Read(r, func(u tgClient.Update) error {
+		recordedIDs = append(recordedIDs, u.UpdateId)
+		u.UpdateId = 0
+		srv.Queue(u)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	var w io.Writer = os.Stdout
+	if *out != "-" {
+		f, err := os.Create(*out)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			if cErr := f.Close(); cErr != nil && err == nil {
+				err = cErr
+			}
+		}()
+		w = f
+	}
+
+	enc := json.NewEncoder(w)
+	lastMessageID := 0
+	failed := 0
+
+	for _, updateID := range recordedIDs {
+		gotEvents, err := processor.Fetch(ctx, 1)
+		if err != nil {
+			return err
+		}
+		if len(gotEvents) != 1 {
+			return fmt.Errorf("got %d events for update %d, want one", len(gotEvents), updateID)
+		}
+
+		edits, answers := len(srv.Edits()), len(srv.Answers())
+
+		res := replayResult{UpdateID: updateID}
+		if err := processor.Process(ctx, gotEvents[0]); err != nil {
+			res.Error = err.Error()
+			failed++
+		}
+
+		for _, m := range srv.Messages() {
+			if m.MessageID > lastMessageID {
+				res.Messages = append(res.Messages, newReplayMessage(m.ChatID, m.MessageID, m.Text, m.ReplyMarkup))
+				lastMessageID = m.MessageID
+			}
+		}
+		for _, edit := range srv.Edits()[edits:] {
+			res.Edits = append(res.Edits, newReplayMessage(edit.ChatID, edit.MessageID, edit.Text, edit.ReplyMarkup))
+		}
+		for _, a := range srv.Answers()[answers:] {
+			res.Answers = append(res.Answers, a.Text)
+		}
+
+		if err := enc.Encode(res); err != nil {
+			return err
+		}
+	}
+
+	fmt.Fprintf(os.Stderr, "replayed %d updates, %d failed\n", len(recordedIDs), failed)
+
+	return nil
+}
+
+func newReplayMessage(chatID int, messageID int, text string, replyMarkup string) replayMessage {
+	m := replayMessage{ChatID: chatID, MessageID: messageID, Text: text}
+	if replyMarkup != "" {
+		m.ReplyMarkup = json.RawMessage(replyMarkup)
+	}
+
+	return m
+}
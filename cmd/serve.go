@@ -0,0 +1,224 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"telegrambot/internal/e"
+	"telegrambot/internal/logging"
+	"telegrambot/pkg/access"
+	"telegrambot/pkg/adminapi"
+	tgClient "telegrambot/pkg/clients/telegram"
+	eventConsumer "telegrambot/pkg/consumer/event-consumer"
+	"telegrambot/pkg/deadletter"
+	"telegrambot/pkg/events/telegram"
+	"telegrambot/pkg/health"
+	"telegrambot/pkg/instrumented"
+	"telegrambot/pkg/metrics"
+	"telegrambot/pkg/recorder"
+	"telegrambot/pkg/scheduler"
+	"telegrambot/pkg/tracing"
+	"time"
+)
+
+// shutdownTimeout is how long a stopping bot waits for work in progress.
+const shutdownTimeout = 10 * time.Second
+
+// serve runs the bot until it is interrupted.
+func serve(ctx context.Context, fs *flag.FlagSet, args []string) error {
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	cfg, err := loadConfig(fs, args)
+	if err != nil {
+		return err
+	}
+
+	if err := cfg.ValidateTelegram(); err != nil {
+		return fmt.Errorf("invalid config:\n%w", err)
+	}
+
+	shutdownTracing, err := tracing.Setup(ctx, tracing.Options{
+		Exporter:    cfg.Tracing.Exporter,
+		ServiceName: "telegrambot",
+		Endpoint:    cfg.Tracing.Endpoint,
+		Insecure:    cfg.Tracing.Insecure,
+		File:        cfg.Tracing.File,
+		SampleRatio: cfg.Tracing.SampleRatio,
+	})
+	if err != nil {
+		return e.Wrap("can't set up tracing", err)
+	}
+
+	storage, err := newRepository(ctx, cfg.Storage)
+	if err != nil {
+		return e.Wrap("can't open repository", err)
+	}
+	rep := instrumented.NewRepository(storage)
+
+	cache, err := newCache(ctx, cfg.State)
+	if err != nil {
+		return e.Wrap("can't open state", err)
+	}
+
+	tg, err := tgClient.NewWithURL(cfg.Telegram.Scheme+"://"+cfg.Telegram.Host, cfg.Telegram.Token.Value())
+	if err != nil {
+		return err
+	}
+
+	bot, err := tg.GetMe(ctx)
+	if err != nil {
+		return e.Wrap("can't get bot info", err)
+	}
+
+	var rec *recorder.Recorder
+	if cfg.Record.File != "" {
+		rec, err = recorder.New(cfg.Record.File, cfg.Record.Salt.Value())
+		if err != nil {
+			return err
+		}
+		defer func() { _ = rec.Close() }()
+
+		slog.Info("recording updates", "file", cfg.Record.File)
+	}
+
+	var deadLetters *deadletter.Queue
+	if cfg.DLQ.File != "" {
+		deadLetters, err = deadletter.New(cfg.DLQ.File)
+		if err != nil {
+			return err
+		}
+	}
+
+	eventProcessor := telegram.New(tg, rep, instrumented.NewCache(cache), telegram.Options{
+		UndoWindow:  cfg.Pages.UndoWindow,
+		BotUsername: bot.Username,
+		Access:      access.New(cfg.Access.Allowed, cfg.Access.Denied, cfg.Access.Admins, rep),
+		Limits: telegram.Limits{
+			MaxPages:          cfg.Limits.MaxPages,
+			SavesPerMinute:    cfg.Limits.SavesPerMinute,
+			CommandsPerSecond: cfg.Limits.CommandsPerSecond,
+		},
+		BroadcastRate: cfg.Limits.BroadcastRate,
+		Recorder:      rec,
+		DeadLetters:   deadLetters,
+	})
+
+	sched := scheduler.New()
+	sched.Every(time.Minute, "resurface", eventProcessor.Resurface)
+	sched.Every(time.Hour, "purge trash", func(ctx context.Context) error {
+		n, err := rep.Purge(ctx, time.Now().Add(-cfg.Pages.TrashRetention))
+		if n > 0 {
+			slog.Info("purged pages from trash", "count", n)
+		}
+		return err
+	})
+
+	// the scheduler is stopped before the processor and the storage, so
+	// that resurfaced pages are neither sent nor changed after them
+	schedCtx, stopScheduler := context.WithCancel(ctx)
+	defer stopScheduler()
+
+	schedDone := make(chan struct{})
+	go func() {
+		defer close(schedDone)
+		sched.Run(schedCtx)
+	}()
+
+	// servers are shut down when the bot stops
+	var servers []*http.Server
+
+	if cfg.Ops.Addr != "" {
+		checker := health.New()
+		checker.Live("fetch", health.Recent(eventProcessor.LastFetch, cfg.Ops.FetchTimeout))
+		if p, ok := storage.(pinger); ok {
+			checker.Ready("repository", p.Ping)
+		}
+		if p, ok := cache.(pinger); ok {
+			checker.Ready("state", p.Ping)
+		}
+		checker.Ready("telegram", func(ctx context.Context) error {
+			_, err := tg.GetMe(ctx)
+			return err
+		})
+
+		mux := http.NewServeMux()
+		mux.Handle("/metrics", metrics.Handler())
+		mux.Handle("/healthz", checker.LivenessHandler())
+		mux.Handle("/readyz", checker.ReadinessHandler())
+
+		srv := &http.Server{
+			Addr:              cfg.Ops.Addr,
+			Handler:           mux,
+			ReadHeaderTimeout: 10 * time.Second,
+		}
+		servers = append(servers, srv)
+
+		go func() {
+			slog.Info("serving metrics and probes", "addr", cfg.Ops.Addr)
+			if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				fatal("ops server is stopped", err)
+			}
+		}()
+	}
+
+	if cfg.AdminAPI.Addr != "" {
+		srv := &http.Server{
+			Addr:              cfg.AdminAPI.Addr,
+			Handler:           adminapi.New(rep, cfg.AdminAPI.Token.Value()),
+			ReadHeaderTimeout: 10 * time.Second,
+		}
+		servers = append(servers, srv)
+
+		go func() {
+			slog.Info("serving admin api", "addr", cfg.AdminAPI.Addr)
+			if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				fatal("admin api is stopped", err)
+			}
+		}()
+	}
+
+	slog.Info("service started")
+
+	consumer := eventConsumer.New(eventProcessor, eventProcessor, 100)
+	err = consumer.Start(ctx)
+
+	slog.Info("service is stopping")
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+
+	// the admin api must not change the storage after it is saved
+	for _, srv := range servers {
+		if err := srv.Shutdown(shutdownCtx); err != nil {
+			slog.Error("can't stop http server", "addr", srv.Addr, logging.Err(err))
+		}
+	}
+
+	stopScheduler()
+	select {
+	case <-schedDone:
+	case <-shutdownCtx.Done():
+		slog.Error("can't stop scheduler", logging.Err(shutdownCtx.Err()))
+	}
+
+	if err := eventProcessor.Shutdown(shutdownCtx); err != nil {
+		slog.Error("can't stop processor", logging.Err(err))
+	}
+
+	if err := saveSnapshot(storage, cfg.Storage); err != nil {
+		slog.Error("can't save memory storage", logging.Err(err))
+	}
+
+	if err := shutdownTracing(context.Background()); err != nil {
+		slog.Error("can't flush traces", logging.Err(err))
+	}
+
+	return e.WrapIfErr("service is stopped", err)
+}
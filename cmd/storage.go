@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"telegrambot/internal/config"
+	"telegrambot/internal/e"
+	"telegrambot/pkg/repository/convert"
+)
+
+// migrate creates the storage schema or upgrades the one of an older version.
+func migrate(ctx context.Context, fs *flag.FlagSet, args []string) error {
+	cfg, err := loadConfig(fs, args)
+	if err != nil {
+		return err
+	}
+
+	if cfg.Storage.Backend != config.StorageSQLite && cfg.Storage.Backend != config.StoragePostgres {
+		fmt.Printf("%s storage has no schema, nothing to migrate\n", cfg.Storage.Backend)
+		return nil
+	}
+
+	if _, err := newRepository(ctx, cfg.Storage); err != nil {
+		return e.Wrap("can't migrate storage", err)
+	}
+
+	fmt.Printf("%s storage is up to date\n", cfg.Storage.Backend)
+
+	return nil
+}
+
+// toPostgresDSNEnv holds the connection string of the destination postgres,
+// it may be read from the file named by TO_POSTGRES_DSN_FILE as well.
+const toPostgresDSNEnv = "TO_POSTGRES_DSN"
+
+// convertStorage copies the configured storage to another backend.
+func convertStorage(ctx context.Context, fs *flag.FlagSet, args []string) (err error) {
+	defer func() {
+		err = e.WrapIfErr("can't convert storage", err)
+	}()
+
+	var to config.StorageConfig
+	fs.StringVar(&to.Backend, "to.backend", "", "destination storage: sqlite, files or postgres")
+	fs.StringVar(&to.SqlitePath, "to.sqlite_path", "", "destination sqlite database file")
+	fs.StringVar(&to.FilesPath, "to.files_path", "", "destination files storage directory")
+	checkpoint := fs.String("checkpoint", "", "file to record copied lists in, to resume an interrupted conversion")
+
+	cfg, err := loadConfig(fs, args)
+	if err != nil {
+		return err
+	}
+
+	// the connection string has a password, so it is not given as a flag
+	if to.Backend == config.StoragePostgres {
+		if to.Postgres.DSN, err = config.LookupSecret(toPostgresDSNEnv); err != nil {
+			return err
+		}
+	}
+
+	switch {
+	case to.Backend == config.StorageSQLite && to.SqlitePath == "",
+		to.Backend == config.StorageFiles && to.FilesPath == "",
+		to.Backend == config.StoragePostgres && to.Postgres.DSN == "":
+		return errors.New("destination path is required")
+	case to.Backend != config.StorageSQLite && to.Backend != config.StorageFiles && to.Backend != config.StoragePostgres:
+		return fmt.Errorf("unsupported destination backend %q", to.Backend)
+	case location(to) == location(cfg.Storage):
+		return errors.New("destination is the configured storage")
+	}
+
+	to.Postgres.MaxOpenConns = cfg.Storage.Postgres.MaxOpenConns
+	to.Postgres.MaxIdleConns = cfg.Storage.Postgres.MaxIdleConns
+	to.Postgres.ConnMaxLifetime = cfg.Storage.Postgres.ConnMaxLifetime
+
+	src, err := newRepository(ctx, cfg.Storage)
+	if err != nil {
+		return err
+	}
+
+	dst, err := newRepository(ctx, to)
+	if err != nil {
+		return err
+	}
+
+	progress, err := convert.Convert(ctx, src, dst, convert.Options{
+		Checkpoint: *checkpoint,
+		Progress: func(p convert.Progress) {
+			slog.Info("converting storage", "owner", p.Owner, "lists", p.Owners, "total_lists", p.TotalOwners,
+				"copied", p.Copied, "skipped", p.Skipped)
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("copied %d pages of %d lists, %d pages were already there, %d lists were copied before\n",
+		progress.Copied, progress.TotalOwners, progress.Skipped, progress.Resumed)
+
+	return nil
+}
+
+// location identifies where the storage keeps data.
+func location(cfg config.StorageConfig) string {
+	switch cfg.Backend {
+	case config.StorageSQLite:
+		return cfg.Backend + ":" + cfg.SqlitePath
+	case config.StorageFiles:
+		return cfg.Backend + ":" + cfg.FilesPath
+	case config.StoragePostgres:
+		return cfg.Backend + ":" + cfg.Postgres.DSN.Value()
+	case config.StorageMemory:
+		return cfg.Backend + ":" + cfg.MemorySnapshot
+	default:
+		return cfg.Backend
+	}
+}
@@ -0,0 +1,157 @@
+package config
+
+import (
+	"flag"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// overrides are config values given as command line flags. They are applied
+// after the file and environment, so they are kept as strings until then.
+type overrides struct {
+	values map[string]string
+	// fields maps flag names to indexes of the fields in Config.
+	fields map[string][]int
+	order  []string
+}
+
+// rawValue remembers the flag value in overrides.
+type rawValue struct {
+	name string
+	o    *overrides
+}
+
+func (v rawValue) String() string {
+	if v.o == nil {
+		return ""
+	}
+
+	return v.o.values[v.name]
+}
+
+func (v rawValue) Set(s string) error {
+	if _, ok := v.o.values[v.name]; !ok {
+		v.o.order = append(v.o.order, v.name)
+	}
+	v.o.values[v.name] = s
+
+	return nil
+}
+
+// registerFlags adds a flag for every config value but secrets, which would
+// be seen by everyone listing processes. Flags are named after the yaml
+// keys, e.g. --storage.backend.
+func registerFlags(fs *flag.FlagSet, cfg *Config) *overrides {
+	o := &overrides{
+		values: make(map[string]string),
+		fields: make(map[string][]int),
+	}
+
+	walkFields(reflect.TypeOf(cfg).Elem(), func(name string, field reflect.StructField, index []int) {
+		if field.Type == secretType {
+			return
+		}
+
+		usage := field.Tag.Get("env-description")
+		if env := field.Tag.Get("env"); env != "" {
+			usage += " (env " + env + ")"
+		}
+
+		o.fields[name] = index
+		fs.Var(rawValue{name: name, o: o}, name, usage)
+	})
+
+	return o
+}
+
+// walkFields calls fn for every config value with its dotted yaml name and
+// the index of the field.
+func walkFields(t reflect.Type, fn func(name string, field reflect.StructField, index []int)) {
+	var walk func(t reflect.Type, prefix string, index []int)
+	walk = func(t reflect.Type, prefix string, index []int) {
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			name := prefix + strings.Split(field.Tag.Get("yaml"), ",")[0]
+			fieldIndex := append(append([]int(nil), index...), i)
+
+			if field.Type.Kind() == reflect.Struct {
+				walk(field.Type, name+".", fieldIndex)
+				continue
+			}
+
+			fn(name, field, fieldIndex)
+		}
+	}
+
+	walk(t, "", nil)
+}
+
+// apply sets the values given as flags in the order they were given.
+func (o *overrides) apply(cfg *Config) error {
+	v := reflect.ValueOf(cfg).Elem()
+
+	for _, name := range o.order {
+		field := v.FieldByIndex(o.fields[name])
+
+		if err := setValue(field, o.values[name]); err != nil {
+			return fmt.Errorf("invalid value %q for flag --%s: %w", o.values[name], name, err)
+		}
+	}
+
+	return nil
+}
+
+func setValue(field reflect.Value, s string) error {
+	if field.Type() == reflect.TypeOf(time.Duration(0)) {
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return err
+		}
+		field.SetInt(int64(d))
+		return nil
+	}
+
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(s)
+	case reflect.Int:
+		n, err := strconv.Atoi(s)
+		if err != nil {
+			return err
+		}
+		field.SetInt(int64(n))
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		field.SetBool(b)
+	case reflect.Float64:
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return err
+		}
+		field.SetFloat(f)
+	case reflect.Slice:
+		items := reflect.MakeSlice(field.Type(), 0, 0)
+		for _, part := range strings.Split(s, ",") {
+			if part = strings.TrimSpace(part); part == "" {
+				continue
+			}
+
+			item := reflect.New(field.Type().Elem()).Elem()
+			if err := setValue(item, part); err != nil {
+				return err
+			}
+			items = reflect.Append(items, item)
+		}
+		field.Set(items)
+	default:
+		return fmt.Errorf("unsupported type %s", field.Type())
+	}
+
+	return nil
+}
@@ -0,0 +1,96 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"reflect"
+	"strings"
+)
+
+const redacted = "[REDACTED]"
+
+// Secret is a config value that must not be printed. It redacts itself when
+// formatted, logged or marshaled to JSON; Value returns the real value.
+type Secret string
+
+func (s Secret) Value() string {
+	return string(s)
+}
+
+func (s Secret) String() string {
+	if s == "" {
+		return ""
+	}
+
+	return redacted
+}
+
+func (s Secret) GoString() string {
+	return fmt.Sprintf("%q", s.String())
+}
+
+func (s Secret) Format(f fmt.State, verb rune) {
+	if verb == 'q' || (verb == 'v' && f.Flag('#')) {
+		_, _ = io.WriteString(f, s.GoString())
+		return
+	}
+
+	_, _ = io.WriteString(f, s.String())
+}
+
+func (s Secret) MarshalJSON() ([]byte, error) {
+	return json.Marshal(s.String())
+}
+
+func (s Secret) LogValue() slog.Value {
+	return slog.StringValue(s.String())
+}
+
+var secretType = reflect.TypeOf(Secret(""))
+
+// LookupSecret returns the secret in the env variable, or in the file named
+// by <env>_FILE, e.g. TG_BOT_TOKEN_FILE, as secrets are usually mounted as
+// files.
+func LookupSecret(env string) (Secret, error) {
+	path := os.Getenv(env + "_FILE")
+	if path == "" {
+		return Secret(os.Getenv(env)), nil
+	}
+
+	if os.Getenv(env) != "" {
+		return "", fmt.Errorf("both %s and %s_FILE are set", env, env)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("can't read %s_FILE: %w", env, err)
+	}
+
+	return Secret(strings.TrimRight(string(data), "\r\n")), nil
+}
+
+// readSecretFiles sets secrets from files named by <ENV>_FILE variables.
+func readSecretFiles(cfg *Config) error {
+	v := reflect.ValueOf(cfg).Elem()
+
+	var err error
+	walkFields(v.Type(), func(name string, field reflect.StructField, index []int) {
+		env := field.Tag.Get("env")
+		if err != nil || field.Type != secretType || env == "" || os.Getenv(env+"_FILE") == "" {
+			return
+		}
+
+		secret, lErr := LookupSecret(env)
+		if lErr != nil {
+			err = lErr
+			return
+		}
+
+		v.FieldByIndex(index).SetString(secret.Value())
+	})
+
+	return err
+}
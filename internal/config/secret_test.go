@@ -0,0 +1,109 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const token = "123456:secret-token"
+
+func TestSecretIsRedacted(t *testing.T) {
+	cfg := TelegramConfig{Host: "api.telegram.org", Token: token}
+
+	printed := []string{
+		fmt.Sprint(cfg.Token),
+		fmt.Sprintf("%s %v %q %#v", cfg.Token, cfg.Token, cfg.Token, cfg.Token),
+		fmt.Sprintf("%v %+v %#v", cfg, cfg, cfg),
+	}
+
+	data, err := json.Marshal(cfg)
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+	printed = append(printed, string(data))
+
+	var logged bytes.Buffer
+	slog.New(slog.NewTextHandler(&logged, nil)).Info("config", "token", cfg.Token)
+	printed = append(printed, logged.String())
+
+	for _, s := range printed {
+		if strings.Contains(s, token) || !strings.Contains(s, redacted) {
+			t.Errorf("secret is printed as %s", s)
+		}
+	}
+
+	if got := fmt.Sprintf("%s|%q", Secret(""), Secret("")); got != `|""` {
+		t.Errorf("empty secret is printed as %s, want nothing", got)
+	}
+
+	if cfg.Token.Value() != token {
+		t.Errorf("Value = %q, want %q", cfg.Token.Value(), token)
+	}
+}
+
+func writeFile(t *testing.T, data string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "secret")
+	if err := os.WriteFile(path, []byte(data), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+func load(t *testing.T, args ...string) (*Config, error) {
+	t.Helper()
+
+	return Load(flag.NewFlagSet("test", flag.ContinueOnError), args)
+}
+
+func TestLoadSecretFromFile(t *testing.T) {
+	t.Setenv("TG_BOT_TOKEN_FILE", writeFile(t, token+"\n"))
+
+	cfg, err := load(t)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+
+	if cfg.Telegram.Token.Value() != token {
+		t.Errorf("token = %q, want %q without the newline", cfg.Telegram.Token.Value(), token)
+	}
+}
+
+func TestLoadSecretFromEnvAndFile(t *testing.T) {
+	t.Setenv("TG_BOT_TOKEN", token)
+	t.Setenv("TG_BOT_TOKEN_FILE", writeFile(t, token))
+
+	if _, err := load(t); err == nil || !strings.Contains(err.Error(), "both TG_BOT_TOKEN and TG_BOT_TOKEN_FILE are set") {
+		t.Errorf("Load: err = %v, want both variables to be reported", err)
+	}
+}
+
+func TestLoadMissingSecretFile(t *testing.T) {
+	t.Setenv("REDIS_PASSWORD_FILE", filepath.Join(t.TempDir(), "missing"))
+
+	if _, err := load(t); err == nil || !strings.Contains(err.Error(), "REDIS_PASSWORD_FILE") {
+		t.Errorf("Load: err = %v, want the missing file to be reported", err)
+	}
+}
+
+func TestSecretsHaveNoFlags(t *testing.T) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	registerFlags(fs, &Config{})
+
+	walkFields(reflect.TypeOf(Config{}), func(name string, field reflect.StructField, _ []int) {
+		secret := field.Type == secretType
+		if hasFlag := fs.Lookup(name) != nil; hasFlag == secret {
+			t.Errorf("%s has a flag: %v, want %v", name, hasFlag, !secret)
+		}
+	})
+}
@@ -0,0 +1,79 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+)
+
+// Validate returns all problems of the config joined together. Telegram
+// settings are checked by ValidateTelegram, as only serving needs them.
+func (c *Config) Validate() error {
+	var errs []error
+	check := func(ok bool, format string, args ...any) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+
+	check(slices.Contains([]string{StorageSQLite, StorageFiles, StorageMemory, StoragePostgres}, c.Storage.Backend),
+		"storage.backend (STORAGE_BACKEND) must be sqlite, files, memory or postgres, got %q", c.Storage.Backend)
+	check(c.Storage.Backend != StorageSQLite || c.Storage.SqlitePath != "",
+		"storage.sqlite_path (SQLITE_REPOSITORY_PATH) is required for sqlite storage")
+	check(c.Storage.Backend != StorageFiles || c.Storage.FilesPath != "",
+		"storage.files_path (FILES_REPOSITORY_PATH) is required for files storage")
+	check(c.Storage.Backend != StoragePostgres || c.Storage.Postgres.DSN != "",
+		"storage.postgres.dsn (POSTGRES_DSN) is required for postgres storage")
+	check(c.Storage.Postgres.MaxOpenConns >= 0 && c.Storage.Postgres.MaxIdleConns >= 0 && c.Storage.Postgres.ConnMaxLifetime >= 0,
+		"storage.postgres pool settings must not be negative")
+
+	check(slices.Contains([]string{StateRedis, StateMemory, StateSQLite}, c.State.Backend),
+		"state.backend (STATE_BACKEND) must be redis, memory or sqlite, got %q", c.State.Backend)
+	check(c.State.Backend != StateSQLite || c.State.SqlitePath != "",
+		"state.sqlite_path (STATE_SQLITE_PATH) is required for sqlite state")
+	check(c.State.Backend != StateRedis || c.State.Redis.Addr != "",
+		"state.redis.addr (REDIS_URL) is required for redis state")
+
+	check(c.Pages.UndoWindow >= 0, "pages.undo_window (UNDO_WINDOW) must not be negative")
+	check(c.Pages.TrashRetention > 0, "pages.trash_retention (TRASH_RETENTION) must be positive")
+
+	check(c.Limits.MaxPages >= 0, "limits.max_pages (MAX_PAGES) must not be negative")
+	check(c.Limits.SavesPerMinute >= 0, "limits.saves_per_minute (SAVES_PER_MINUTE) must not be negative")
+	check(c.Limits.CommandsPerSecond >= 0, "limits.commands_per_second (COMMANDS_PER_SECOND) must not be negative")
+	check(c.Limits.BroadcastRate >= 0, "limits.broadcast_rate (BROADCAST_RATE) must not be negative")
+
+	check(c.AdminAPI.Addr == "" || c.AdminAPI.Token != "",
+		"admin_api.token (ADMIN_API_TOKEN) is required when the admin api is enabled")
+
+	check(c.Ops.FetchTimeout > 0, "ops.fetch_timeout (FETCH_TIMEOUT) must be positive")
+
+	var level slog.Level
+	check(level.UnmarshalText([]byte(c.Log.Level)) == nil,
+		"log.level (LOG_LEVEL) must be debug, info, warn or error, got %q", c.Log.Level)
+	check(c.Log.Format == "text" || c.Log.Format == "json",
+		"log.format (LOG_FORMAT) must be text or json, got %q", c.Log.Format)
+
+	check(slices.Contains([]string{"none", "otlp", "stdout", "file"}, c.Tracing.Exporter),
+		"tracing.exporter (TRACING_EXPORTER) must be none, otlp, stdout or file, got %q", c.Tracing.Exporter)
+	check(c.Tracing.SampleRatio >= 0 && c.Tracing.SampleRatio <= 1,
+		"tracing.sample_ratio (TRACING_SAMPLE_RATIO) must be between 0 and 1")
+
+	return errors.Join(errs...)
+}
+
+// ValidateTelegram checks the settings needed to talk to the Bot API.
+func (c *Config) ValidateTelegram() error {
+	var errs []error
+	if c.Telegram.Token == "" {
+		errs = append(errs, errors.New("telegram.token (TG_BOT_TOKEN) is required"))
+	}
+	if c.Telegram.Host == "" {
+		errs = append(errs, errors.New("telegram.host (TG_BOT_HOST) is required"))
+	}
+	if c.Telegram.Scheme != "https" && c.Telegram.Scheme != "http" {
+		errs = append(errs, fmt.Errorf("telegram.scheme (TG_BOT_SCHEME) must be https or http, got %q", c.Telegram.Scheme))
+	}
+
+	return errors.Join(errs...)
+}
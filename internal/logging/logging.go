@@ -0,0 +1,103 @@
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+)
+
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
+const redacted = "[REDACTED]"
+
+type Options struct {
+	Level  slog.Level
+	Format string
+	// Secrets are replaced in every logged string and error.
+	Secrets []string
+}
+
+// New creates logger which writes to w in the given format.
+func New(w io.Writer, opts Options) (*slog.Logger, error) {
+	secrets := make([]string, 0, len(opts.Secrets))
+	for _, s := range opts.Secrets {
+		if s != "" {
+			secrets = append(secrets, s)
+		}
+	}
+
+	handlerOpts := &slog.HandlerOptions{
+		Level: opts.Level,
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			return redact(a, secrets)
+		},
+	}
+
+	switch opts.Format {
+	case FormatText, "":
+		return slog.New(slog.NewTextHandler(w, handlerOpts)), nil
+	case FormatJSON:
+		return slog.New(slog.NewJSONHandler(w, handlerOpts)), nil
+	default:
+		return nil, fmt.Errorf("unknown log format %q", opts.Format)
+	}
+}
+
+// ParseLevel parses level names like "debug" or "warn".
+func ParseLevel(s string) (slog.Level, error) {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(s)); err != nil {
+		return 0, fmt.Errorf("unknown log level %q", s)
+	}
+
+	return level, nil
+}
+
+type ctxKey struct{}
+
+// WithLogger returns context carrying the logger, e.g. one with attributes
+// of the event being processed.
+func WithLogger(ctx context.Context, l *slog.Logger) context.Context {
+	return context.WithValue(ctx, ctxKey{}, l)
+}
+
+// FromContext returns the logger of the context or the default one.
+func FromContext(ctx context.Context) *slog.Logger {
+	if l, ok := ctx.Value(ctxKey{}).(*slog.Logger); ok {
+		return l
+	}
+
+	return slog.Default()
+}
+
+// Err is the attribute errors are logged under.
+func Err(err error) slog.Attr {
+	return slog.Any("error", err)
+}
+
+func redact(a slog.Attr, secrets []string) slog.Attr {
+	if len(secrets) == 0 {
+		return a
+	}
+
+	var s string
+	switch v := a.Value.Any().(type) {
+	case string:
+		s = v
+	case error:
+		s = v.Error()
+	default:
+		return a
+	}
+
+	for _, secret := range secrets {
+		s = strings.ReplaceAll(s, secret, redacted)
+	}
+
+	return slog.String(a.Key, s)
+}
@@ -0,0 +1,114 @@
+package access
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"telegrambot/internal/e"
+	"telegrambot/pkg/repository"
+)
+
+var (
+	ErrDenied     = errors.New("user is denied")
+	ErrNotAllowed = errors.New("user is not in the allowlist")
+	ErrBanned     = errors.New("user is banned")
+)
+
+// Policy decides which Telegram users may use the bot.
+type Policy struct {
+	allowed map[int]bool
+	denied  map[int]bool
+	admins  map[int]bool
+	bans    repository.Bans
+}
+
+// New creates policy. An empty allowlist allows everyone who is not denied
+// or banned. Admins are always allowed.
+func New(allowed []int, denied []int, admins []int, bans repository.Bans) *Policy {
+	return &Policy{
+		allowed: set(allowed),
+		denied:  set(denied),
+		admins:  set(admins),
+		bans:    bans,
+	}
+}
+
+// Authorize returns ErrDenied, ErrNotAllowed or ErrBanned if the user may
+// not use the bot.
+func (p *Policy) Authorize(ctx context.Context, userID int) error {
+	if p.IsAdmin(userID) {
+		return nil
+	}
+
+	if p.denied[userID] {
+		return ErrDenied
+	}
+
+	if len(p.allowed) > 0 && !p.allowed[userID] {
+		return ErrNotAllowed
+	}
+
+	banned, err := p.bans.IsBanned(ctx, userID)
+	if err != nil {
+		return e.Wrap("can't authorize user", err)
+	}
+	if banned {
+		return ErrBanned
+	}
+
+	return nil
+}
+
+func (p *Policy) IsAdmin(userID int) bool {
+	return p.admins[userID]
+}
+
+// IsForbidden reports whether err is returned by Authorize for a user who
+// may not use the bot.
+func IsForbidden(err error) bool {
+	return errors.Is(err, ErrDenied) || errors.Is(err, ErrNotAllowed) || errors.Is(err, ErrBanned)
+}
+
+func (p *Policy) Admins() []int {
+	return keys(p.admins)
+}
+
+func (p *Policy) Allowed() []int {
+	return keys(p.allowed)
+}
+
+func (p *Policy) Denied() []int {
+	return keys(p.denied)
+}
+
+func (p *Policy) Ban(ctx context.Context, userID int) error {
+	return p.bans.Ban(ctx, userID)
+}
+
+func (p *Policy) Unban(ctx context.Context, userID int) error {
+	return p.bans.Unban(ctx, userID)
+}
+
+func (p *Policy) Banned(ctx context.Context) ([]int, error) {
+	return p.bans.Banned(ctx)
+}
+
+func set(ids []int) map[int]bool {
+	res := make(map[int]bool, len(ids))
+	for _, id := range ids {
+		res[id] = true
+	}
+
+	return res
+}
+
+func keys(m map[int]bool) []int {
+	res := make([]int, 0, len(m))
+	for id := range m {
+		res = append(res, id)
+	}
+
+	sort.Ints(res)
+
+	return res
+}
@@ -0,0 +1,163 @@
+package adminapi
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+	"telegrambot/internal/logging"
+	"telegrambot/pkg/repository"
+	"time"
+)
+
+// Server serves the API. Every request must carry the token in the
+// "Authorization: Bearer <token>" header.
+type Server struct {
+	repository repository.Repository
+	token      string
+	mux        *http.ServeMux
+}
+
+func New(repository repository.Repository, token string) *Server {
+	s := &Server{
+		repository: repository,
+		token:      token,
+		mux:        http.NewServeMux(),
+	}
+
+	s.mux.HandleFunc("GET /api/users", s.listUsers)
+	s.mux.HandleFunc("GET /api/users/{owner}/pages", s.listPages)
+	s.mux.HandleFunc("DELETE /api/users/{owner}/pages", s.deletePage)
+	s.mux.HandleFunc("GET /api/users/{owner}/stats", s.stats)
+
+	return s
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !s.authorized(r) {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		writeError(w, http.StatusUnauthorized, "invalid token")
+		return
+	}
+
+	s.mux.ServeHTTP(w, r)
+}
+
+func (s *Server) authorized(r *http.Request) bool {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+
+	return ok && s.token != "" && subtle.ConstantTimeCompare([]byte(token), []byte(s.token)) == 1
+}
+
+// listUsers handles GET /api/users.
+func (s *Server) listUsers(w http.ResponseWriter, r *http.Request) {
+	users, err := s.repository.Users(r.Context())
+	if err != nil {
+		writeInternalError(w, err)
+		return
+	}
+
+	res := make([]userJSON, 0, len(users))
+	for _, u := range users {
+		res = append(res, newUserJSON(u))
+	}
+
+	writeJSON(w, http.StatusOK, res)
+}
+
+// listPages handles GET /api/users/{owner}/pages. Pages can be filtered by
+// status (unread, read, deleted), tag and q, a substring of the url.
+func (s *Server) listPages(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	status := query.Get("status")
+	if status != "" && status != statusUnread && status != statusRead && status != statusDeleted {
+		writeError(w, http.StatusBadRequest, "unknown status")
+		return
+	}
+
+	pages, err := s.repository.Pages(r.Context(), r.PathValue("owner"))
+	if err != nil {
+		writeInternalError(w, err)
+		return
+	}
+
+	tag := strings.ToLower(strings.TrimPrefix(query.Get("tag"), "#"))
+	search := strings.ToLower(query.Get("q"))
+
+	res := make([]pageJSON, 0, len(pages))
+	for _, p := range pages {
+		if status != "" && pageStatus(p) != status {
+			continue
+		}
+		if tag != "" && !p.HasTag(tag) {
+			continue
+		}
+		if search != "" && !strings.Contains(strings.ToLower(p.URL), search) {
+			continue
+		}
+
+		res = append(res, newPageJSON(p))
+	}
+
+	writeJSON(w, http.StatusOK, res)
+}
+
+// deletePage handles DELETE /api/users/{owner}/pages?url=<url>. The page is
+// moved to the trash like the delete button does.
+func (s *Server) deletePage(w http.ResponseWriter, r *http.Request) {
+	pageURL := r.URL.Query().Get("url")
+	if pageURL == "" {
+		writeError(w, http.StatusBadRequest, "url is required")
+		return
+	}
+
+	page, err := s.repository.Get(r.Context(), r.PathValue("owner"), pageURL)
+	if errors.Is(err, repository.ErrPageNotFound) {
+		writeError(w, http.StatusNotFound, "page not found")
+		return
+	}
+	if err != nil {
+		writeInternalError(w, err)
+		return
+	}
+
+	if err := s.repository.Remove(r.Context(), page); err != nil {
+		writeInternalError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// stats handles GET /api/users/{owner}/stats.
+func (s *Server) stats(w http.ResponseWriter, r *http.Request) {
+	stats, err := s.repository.Stats(r.Context(), r.PathValue("owner"), time.Now())
+	if err != nil {
+		writeInternalError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, newStatsJSON(stats))
+}
+
+func writeJSON(w http.ResponseWriter, code int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		slog.Error("admin api: can't write response", logging.Err(err))
+	}
+}
+
+func writeError(w http.ResponseWriter, code int, msg string) {
+	writeJSON(w, code, errorJSON{Error: msg})
+}
+
+func writeInternalError(w http.ResponseWriter, err error) {
+	slog.Error("admin api: request failed", logging.Err(err))
+
+	writeError(w, http.StatusInternalServerError, http.StatusText(http.StatusInternalServerError))
+}
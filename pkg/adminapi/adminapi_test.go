@@ -0,0 +1,238 @@
+package adminapi
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"telegrambot/pkg/repository"
+	"telegrambot/pkg/repository/memory"
+	"testing"
+	"time"
+)
+
+const token = "secret"
+
+func newTestServer(t *testing.T) (*Server, repository.Repository) {
+	t.Helper()
+
+	ctx := context.Background()
+	repo := memory.New()
+	now := time.Now()
+
+	if err := repo.TouchUser(ctx, &repository.User{ID: 1, Username: "alice", FirstName: "Alice", LastSeen: now}); err != nil {
+		t.Fatalf("TouchUser: %v", err)
+	}
+
+	pages := []*repository.Page{
+		{URL: "https://example.com/go", Username: "alice", Tags: []string{"go"}, CreatedAt: now.Add(-time.Hour)},
+		{URL: "https://blog.example.org/db", Username: "alice", Tags: []string{"db"}, CreatedAt: now.Add(-2 * time.Hour)},
+		{URL: "https://example.com/read", Username: "alice", CreatedAt: now.Add(-3 * time.Hour), ReadAt: now.Add(-time.Minute)},
+	}
+	for _, p := range pages {
+		if err := repo.Save(ctx, p); err != nil {
+			t.Fatalf("Save: %v", err)
+		}
+	}
+
+	return New(repo, token), repo
+}
+
+func do(t *testing.T, s *Server, method, target, auth string) *httptest.ResponseRecorder {
+	t.Helper()
+
+	req := httptest.NewRequest(method, target, nil)
+	if auth != "" {
+		req.Header.Set("Authorization", auth)
+	}
+
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, req)
+
+	return rec
+}
+
+func TestUnauthorized(t *testing.T) {
+	s, _ := newTestServer(t)
+
+	routes := []struct {
+		method string
+		target string
+	}{
+		{http.MethodGet, "/api/users"},
+		{http.MethodGet, "/api/users/alice/pages"},
+		{http.MethodDelete, "/api/users/alice/pages?url=https://example.com/go"},
+		{http.MethodGet, "/api/users/alice/stats"},
+	}
+
+	auths := []struct {
+		name   string
+		header string
+	}{
+		{"missing token", ""},
+		{"wrong bearer", "Bearer wrong"},
+		{"not a bearer", "Basic " + token},
+	}
+
+	for _, route := range routes {
+		for _, auth := range auths {
+			t.Run(route.method+" "+route.target+" "+auth.name, func(t *testing.T) {
+				rec := do(t, s, route.method, route.target, auth.header)
+
+				if rec.Code != http.StatusUnauthorized {
+					t.Fatalf("status = %d, want %d", rec.Code, http.StatusUnauthorized)
+				}
+				if got := rec.Header().Get("WWW-Authenticate"); got != "Bearer" {
+					t.Errorf("WWW-Authenticate = %q, want %q", got, "Bearer")
+				}
+
+				var body errorJSON
+				decode(t, rec, &body)
+				if body.Error != "invalid token" {
+					t.Errorf("error = %q, want %q", body.Error, "invalid token")
+				}
+			})
+		}
+	}
+
+	// nothing was deleted by the unauthorized requests
+	rec := do(t, s, http.MethodGet, "/api/users/alice/pages?status=deleted", "Bearer "+token)
+	var pages []pageJSON
+	decode(t, rec, &pages)
+	if len(pages) != 0 {
+		t.Errorf("deleted pages = %v, want none", pages)
+	}
+}
+
+func TestListUsers(t *testing.T) {
+	s, _ := newTestServer(t)
+
+	rec := do(t, s, http.MethodGet, "/api/users", "Bearer "+token)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusOK)
+	}
+
+	var users []userJSON
+	decode(t, rec, &users)
+
+	if len(users) != 1 {
+		t.Fatalf("users = %v, want one", users)
+	}
+	if u := users[0]; u.ID != 1 || u.Username != "alice" || u.Owner != "alice" || u.BlockedAt != nil {
+		t.Errorf("user = %+v, want alice with id 1 and not blocked", u)
+	}
+}
+
+func TestListPages(t *testing.T) {
+	s, _ := newTestServer(t)
+
+	tests := []struct {
+		name   string
+		target string
+		code   int
+		want   []string
+	}{
+		{"all", "/api/users/alice/pages", http.StatusOK, []string{
+			"https://example.com/go", "https://blog.example.org/db", "https://example.com/read",
+		}},
+		{"unread", "/api/users/alice/pages?status=unread", http.StatusOK, []string{
+			"https://example.com/go", "https://blog.example.org/db",
+		}},
+		{"read", "/api/users/alice/pages?status=read", http.StatusOK, []string{"https://example.com/read"}},
+		{"deleted", "/api/users/alice/pages?status=deleted", http.StatusOK, nil},
+		{"tag", "/api/users/alice/pages?tag=%23go", http.StatusOK, []string{"https://example.com/go"}},
+		{"search", "/api/users/alice/pages?q=BLOG", http.StatusOK, []string{"https://blog.example.org/db"}},
+		{"unknown owner", "/api/users/bob/pages", http.StatusOK, nil},
+		{"unknown status", "/api/users/alice/pages?status=archived", http.StatusBadRequest, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := do(t, s, http.MethodGet, tt.target, "Bearer "+token)
+			if rec.Code != tt.code {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.code)
+			}
+			if tt.code != http.StatusOK {
+				return
+			}
+
+			var pages []pageJSON
+			decode(t, rec, &pages)
+
+			got := make(map[string]bool)
+			for _, p := range pages {
+				got[p.URL] = true
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("pages = %v, want %v", got, tt.want)
+			}
+			for _, url := range tt.want {
+				if !got[url] {
+					t.Errorf("pages = %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestDeletePage(t *testing.T) {
+	s, repo := newTestServer(t)
+
+	tests := []struct {
+		name   string
+		target string
+		code   int
+	}{
+		{"no url", "/api/users/alice/pages", http.StatusBadRequest},
+		{"missing page", "/api/users/alice/pages?url=https://example.com/missing", http.StatusNotFound},
+		{"page of another owner", "/api/users/bob/pages?url=https://example.com/go", http.StatusNotFound},
+		{"page", "/api/users/alice/pages?url=https://example.com/go", http.StatusNoContent},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := do(t, s, http.MethodDelete, tt.target, "Bearer "+token)
+			if rec.Code != tt.code {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.code)
+			}
+		})
+	}
+
+	trash, err := repo.Trash(context.Background(), "alice")
+	if err != nil {
+		t.Fatalf("Trash: %v", err)
+	}
+	if len(trash) != 1 || trash[0].URL != "https://example.com/go" {
+		t.Errorf("trash = %v, want the deleted page", trash)
+	}
+}
+
+func TestStats(t *testing.T) {
+	s, _ := newTestServer(t)
+
+	rec := do(t, s, http.MethodGet, "/api/users/alice/stats", "Bearer "+token)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusOK)
+	}
+
+	var stats statsJSON
+	decode(t, rec, &stats)
+
+	if stats.Total != 3 || stats.Unread != 2 || stats.ReadThisWeek != 1 {
+		t.Errorf("stats = %+v, want 3 pages, 2 unread and 1 read this week", stats)
+	}
+	if len(stats.TopDomains) == 0 || stats.TopDomains[0] != (countJSON{Name: "example.com", Count: 2}) {
+		t.Errorf("top domains = %v, want example.com with 2 pages first", stats.TopDomains)
+	}
+}
+
+func decode(t *testing.T, rec *httptest.ResponseRecorder, v any) {
+	t.Helper()
+
+	if got := rec.Header().Get("Content-Type"); got != "application/json" {
+		t.Errorf("Content-Type = %q, want application/json", got)
+	}
+	if err := json.NewDecoder(rec.Body).Decode(v); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+}
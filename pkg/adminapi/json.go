@@ -0,0 +1,120 @@
+package adminapi
+
+import (
+	"telegrambot/pkg/repository"
+	"time"
+)
+
+const (
+	statusUnread  = "unread"
+	statusRead    = "read"
+	statusDeleted = "deleted"
+)
+
+type errorJSON struct {
+	Error string `json:"error"`
+}
+
+type userJSON struct {
+	ID        int        `json:"id"`
+	Username  string     `json:"username,omitempty"`
+	FirstName string     `json:"first_name,omitempty"`
+	Owner     string     `json:"owner"`
+	FirstSeen time.Time  `json:"first_seen"`
+	LastSeen  time.Time  `json:"last_seen"`
+	BlockedAt *time.Time `json:"blocked_at,omitempty"`
+}
+
+func newUserJSON(u *repository.User) userJSON {
+	return userJSON{
+		ID:        u.ID,
+		Username:  u.Username,
+		FirstName: u.FirstName,
+		Owner:     u.PagesOwner(),
+		FirstSeen: u.FirstSeen,
+		LastSeen:  u.LastSeen,
+		BlockedAt: optionalTime(u.BlockedAt),
+	}
+}
+
+type pageJSON struct {
+	URL         string     `json:"url"`
+	Domain      string     `json:"domain"`
+	Tags        []string   `json:"tags"`
+	Status      string     `json:"status"`
+	CreatedAt   time.Time  `json:"created_at"`
+	ReadAt      *time.Time `json:"read_at,omitempty"`
+	DeletedAt   *time.Time `json:"deleted_at,omitempty"`
+	ResurfaceAt *time.Time `json:"resurface_at,omitempty"`
+}
+
+func newPageJSON(p *repository.Page) pageJSON {
+	tags := p.Tags
+	if tags == nil {
+		tags = []string{}
+	}
+
+	return pageJSON{
+		URL:         p.URL,
+		Domain:      p.Domain(),
+		Tags:        tags,
+		Status:      pageStatus(p),
+		CreatedAt:   p.CreatedAt,
+		ReadAt:      optionalTime(p.ReadAt),
+		DeletedAt:   optionalTime(p.DeletedAt),
+		ResurfaceAt: optionalTime(p.ResurfaceAt),
+	}
+}
+
+func pageStatus(p *repository.Page) string {
+	switch {
+	case p.IsDeleted():
+		return statusDeleted
+	case p.IsRead():
+		return statusRead
+	default:
+		return statusUnread
+	}
+}
+
+type countJSON struct {
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+}
+
+type statsJSON struct {
+	Total        int         `json:"total"`
+	Unread       int         `json:"unread"`
+	ReadThisWeek int         `json:"read_this_week"`
+	AvgUnreadAge string      `json:"avg_unread_age"`
+	TopDomains   []countJSON `json:"top_domains"`
+	TopTags      []countJSON `json:"top_tags"`
+}
+
+func newStatsJSON(s *repository.Stats) statsJSON {
+	return statsJSON{
+		Total:        s.Total,
+		Unread:       s.Unread,
+		ReadThisWeek: s.ReadThisWeek,
+		AvgUnreadAge: s.AvgUnreadAge.Round(time.Second).String(),
+		TopDomains:   newCountsJSON(s.TopDomains),
+		TopTags:      newCountsJSON(s.TopTags),
+	}
+}
+
+func newCountsJSON(counts []repository.Count) []countJSON {
+	res := make([]countJSON, 0, len(counts))
+	for _, c := range counts {
+		res = append(res, countJSON{Name: c.Name, Count: c.Count})
+	}
+
+	return res
+}
+
+func optionalTime(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+
+	return &t
+}
@@ -0,0 +1,129 @@
+package telegram
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Error is returned when Telegram Bot API rejects a request.
+type Error struct {
+	Code        int
+	Description string
+	// RetryAfter is how long to wait before repeating a request rejected
+	// because of flood control.
+	RetryAfter time.Duration
+}
+
+func (err *Error) Error() string {
+	return fmt.Sprintf("telegram api error %d: %s", err.Code, err.Description)
+}
+
+// IsForbidden reports whether the request was rejected because the bot can't
+// write to the chat, e.g. the user has blocked the bot.
+func IsForbidden(err error) bool {
+	var apiErr *Error
+	return errors.As(err, &apiErr) && apiErr.Code == http.StatusForbidden
+}
+
+// IsNotModified reports whether an edit was rejected because the message
+// already has the same text and keyboard, which callers may ignore.
+func IsNotModified(err error) bool {
+	var apiErr *Error
+	return errors.As(err, &apiErr) && apiErr.Code == http.StatusBadRequest &&
+		strings.Contains(apiErr.Description, "message is not modified")
+}
+
+// RetryAfter returns how long to wait before repeating a request rejected
+// because of flood control, or zero for other errors.
+func RetryAfter(err error) time.Duration {
+	var apiErr *Error
+	if errors.As(err, &apiErr) && apiErr.Code == http.StatusTooManyRequests {
+		return apiErr.RetryAfter
+	}
+
+	return 0
+}
+
+// errorCode returns the code of the Bot API error, or 0 if err is not one.
+func errorCode(err error) int {
+	var apiErr *Error
+	if errors.As(err, &apiErr) {
+		return apiErr.Code
+	}
+
+	return 0
+}
+
+// requestError strips the request url from a transport error: it contains
+// the bot token and the message text.
+func requestError(err error) error {
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		return fmt.Errorf("%s request: %w", urlErr.Op, urlErr.Err)
+	}
+
+	return err
+}
+
+// tokenError hides the bot token in the message of err, which may contain
+// the request URL. The original error is still available with errors.As.
+type tokenError struct {
+	err   error
+	token string
+}
+
+func (t *tokenError) Error() string {
+	return strings.ReplaceAll(t.err.Error(), t.token, "[REDACTED]")
+}
+
+func (t *tokenError) Unwrap() error {
+	return t.err
+}
+
+func scrubToken(err error, token string) error {
+	if err == nil || token == "" || !strings.Contains(err.Error(), token) {
+		return err
+	}
+
+	return &tokenError{err: err, token: token}
+}
+
+type errorResponse struct {
+	Ok          bool   `json:"ok"`
+	ErrorCode   int    `json:"error_code"`
+	Description string `json:"description"`
+	Parameters  *struct {
+		RetryAfter int `json:"retry_after"`
+	} `json:"parameters,omitempty"`
+}
+
+// apiError returns the error described by the response body, or nil if the
+// request succeeded.
+func apiError(body []byte, statusCode int) error {
+	var res errorResponse
+	if err := json.Unmarshal(body, &res); err != nil {
+		if statusCode != http.StatusOK {
+			return &Error{Code: statusCode, Description: http.StatusText(statusCode)}
+		}
+		return err
+	}
+
+	if res.Ok {
+		return nil
+	}
+
+	apiErr := &Error{
+		Code:        res.ErrorCode,
+		Description: res.Description,
+	}
+	if res.Parameters != nil {
+		apiErr.RetryAfter = time.Duration(res.Parameters.RetryAfter) * time.Second
+	}
+
+	return apiErr
+}
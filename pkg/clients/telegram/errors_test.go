@@ -0,0 +1,81 @@
+package telegram
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+const token = "123456:secret-token"
+
+func TestRequestError(t *testing.T) {
+	refused := errors.New("connection refused")
+	err := requestError(&url.Error{
+		Op:  "Get",
+		URL: "https://api.telegram.org/bot" + token + "/sendMessage?text=hello",
+		Err: refused,
+	})
+
+	if got := err.Error(); got != "Get request: connection refused" {
+		t.Errorf("requestError = %q, want the url stripped", got)
+	}
+	if !errors.Is(err, refused) {
+		t.Errorf("requestError = %v, want it to wrap the cause", err)
+	}
+
+	other := errors.New("other")
+	if got := requestError(other); got != other {
+		t.Errorf("requestError of a non-url error = %v, want it as is", got)
+	}
+}
+
+func TestScrubToken(t *testing.T) {
+	urlErr := &url.Error{Op: "Get", URL: "https://api.telegram.org/bot" + token + "/getMe", Err: errors.New("EOF")}
+
+	err := scrubToken(urlErr, token)
+	if strings.Contains(err.Error(), token) || !strings.Contains(err.Error(), "bot[REDACTED]/getMe") {
+		t.Errorf("scrubToken = %q, want the token redacted", err)
+	}
+
+	var got *url.Error
+	if !errors.As(err, &got) || got != urlErr {
+		t.Errorf("scrubToken = %v, want the original error to be found", err)
+	}
+
+	other := errors.New("no token here")
+	if got := scrubToken(other, token); got != other {
+		t.Errorf("scrubToken of an error without the token = %v, want it as is", got)
+	}
+	if got := scrubToken(urlErr, ""); got != error(urlErr) {
+		t.Errorf("scrubToken with an empty token = %v, want the error as is", got)
+	}
+	if got := scrubToken(nil, token); got != nil {
+		t.Errorf("scrubToken(nil) = %v", got)
+	}
+}
+
+func TestClientErrorsHaveNoToken(t *testing.T) {
+	// nothing listens on the port once the listener is closed
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := l.Addr().String()
+	_ = l.Close()
+
+	c, err := NewWithURL("http://"+addr, token)
+	if err != nil {
+		t.Fatalf("NewWithURL: %v", err)
+	}
+
+	_, err = c.SendMessage(context.Background(), MessageConfig{ChatID: 1, Text: "hello"})
+	if err == nil {
+		t.Fatal("SendMessage to a closed port succeeded")
+	}
+	if strings.Contains(err.Error(), token) || strings.Contains(err.Error(), "hello") {
+		t.Errorf("error = %q, want neither the token nor the text", err)
+	}
+}
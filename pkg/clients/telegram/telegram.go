@@ -1,30 +1,69 @@
 package telegram
 
 import (
+	"context"
 	"encoding/json"
+	"fmt"
 	"io"
 	"net/http"
 	"net/url"
 	"path"
 	"strconv"
 	"telegrambot/internal/e"
+	"telegrambot/internal/logging"
+	"telegrambot/pkg/metrics"
+	"telegrambot/pkg/tracing"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
 )
 
 const (
-	getUpdatesMethod    = "getUpdates"
-	sendMessageMethod   = "sendMessage"
-	answerCallbackQuery = "answerCallbackQuery"
+	getMeMethod                  = "getMe"
+	getUpdatesMethod             = "getUpdates"
+	sendMessageMethod            = "sendMessage"
+	answerCallbackQuery          = "answerCallbackQuery"
+	editMessageTextMethod        = "editMessageText"
+	editMessageReplyMarkupMethod = "editMessageReplyMarkup"
+	deleteMessageMethod          = "deleteMessage"
+	deleteMessagesMethod         = "deleteMessages"
 )
 
+// maxDeleteMessages is how many messages deleteMessages takes at once.
+const maxDeleteMessages = 100
+
 type Client struct {
-	host     string
+	baseURL  url.URL
+	token    string
 	basePath string
 	client   http.Client
 }
 
+// New creates a client of the Bot API served over https at host, e.g.
+// api.telegram.org.
 func New(host string, token string) *Client {
+	return newClient(url.URL{Scheme: "https", Host: host}, token)
+}
+
+// NewWithURL creates a client of the Bot API served at baseURL, e.g. a local
+// Bot API server at http://localhost:8081 or a fake one in tests.
+func NewWithURL(baseURL string, token string) (*Client, error) {
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		return nil, e.Wrap("can't parse bot api url", err)
+	}
+
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("can't use bot api url %q: it must look like https://host[:port]", baseURL)
+	}
+
+	return newClient(*u, token), nil
+}
+
+func newClient(baseURL url.URL, token string) *Client {
 	return &Client{
-		host:     host,
+		baseURL:  baseURL,
+		token:    token,
 		basePath: newBasePath(token),
 		client:   http.Client{},
 	}
@@ -34,12 +73,27 @@ func newBasePath(token string) string {
 	return "bot" + token
 }
 
-func (c *Client) Updates(offset int, limit int) ([]Update, error) {
+func (c *Client) GetMe(ctx context.Context) (User, error) {
+	data, err := c.doRequest(ctx, getMeMethod, url.Values{})
+	if err != nil {
+		return User{}, e.Wrap("cannot get bot user", err)
+	}
+
+	var res UserResponse
+
+	if err = json.Unmarshal(data, &res); err != nil {
+		return User{}, e.Wrap("cannot get bot user", err)
+	}
+
+	return res.Result, nil
+}
+
+func (c *Client) Updates(ctx context.Context, offset int, limit int) ([]Update, error) {
 	query := url.Values{}
 	query.Add("offset", strconv.Itoa(offset))
 	query.Add("limit", strconv.Itoa(limit))
 
-	data, err := c.doRequest(getUpdatesMethod, query)
+	data, err := c.doRequest(ctx, getUpdatesMethod, query)
 	if err != nil {
 		return nil, err
 	}
@@ -53,36 +107,117 @@ func (c *Client) Updates(offset int, limit int) ([]Update, error) {
 	return res.Result, nil
 }
 
-func (c *Client) SendMessage(msg MessageConfig) (err error) {
+// SendMessage sends the message and returns it as it was sent.
+func (c *Client) SendMessage(ctx context.Context, msg MessageConfig) (Message, error) {
 	query := url.Values{}
 	query.Add("chat_id", strconv.Itoa(msg.ChatID))
 	query.Add("text", msg.Text)
+	if msg.ParseMode != "" {
+		query.Add("parse_mode", msg.ParseMode)
+	}
 
-	if msg.ReplyMarkup != nil {
-		replyMarkup, err := json.Marshal(msg.ReplyMarkup)
-		if err != nil {
-			return e.Wrap("can't marshal msg.ReplyMarkup", err)
-		}
-		query.Add("reply_markup", string(replyMarkup))
+	if err := addReplyMarkup(query, msg.ReplyMarkup); err != nil {
+		return Message{}, e.Wrap("cannot send message", err)
+	}
+
+	sent, err := c.doMessageRequest(ctx, sendMessageMethod, query)
+	if err != nil {
+		return Message{}, e.Wrap("cannot send message", err)
+	}
+
+	return sent, nil
+}
+
+// EditMessageText changes the text and the keyboard of a message sent by
+// the bot. Telegram rejects edits which change nothing, see IsNotModified.
+func (c *Client) EditMessageText(ctx context.Context, msg EditMessageTextConfig) (Message, error) {
+	query := url.Values{}
+	query.Add("chat_id", strconv.Itoa(msg.ChatID))
+	query.Add("message_id", strconv.Itoa(msg.MessageID))
+	query.Add("text", msg.Text)
+	if msg.ParseMode != "" {
+		query.Add("parse_mode", msg.ParseMode)
 	}
 
-	_, err = c.doRequest(sendMessageMethod, query)
+	if err := addReplyMarkup(query, msg.ReplyMarkup); err != nil {
+		return Message{}, e.Wrap("cannot edit message text", err)
+	}
+
+	edited, err := c.doMessageRequest(ctx, editMessageTextMethod, query)
 	if err != nil {
-		return e.Wrap("cannot send message", err)
+		return Message{}, e.Wrap("cannot edit message text", err)
+	}
+
+	return edited, nil
+}
+
+// EditMessageReplyMarkup changes only the keyboard of a message sent by the
+// bot.
+func (c *Client) EditMessageReplyMarkup(ctx context.Context, msg EditMessageReplyMarkupConfig) (Message, error) {
+	query := url.Values{}
+	query.Add("chat_id", strconv.Itoa(msg.ChatID))
+	query.Add("message_id", strconv.Itoa(msg.MessageID))
+
+	if err := addReplyMarkup(query, msg.ReplyMarkup); err != nil {
+		return Message{}, e.Wrap("cannot edit message reply markup", err)
+	}
+
+	edited, err := c.doMessageRequest(ctx, editMessageReplyMarkupMethod, query)
+	if err != nil {
+		return Message{}, e.Wrap("cannot edit message reply markup", err)
+	}
+
+	return edited, nil
+}
+
+// DeleteMessage deletes a message. The bot may delete its messages sent
+// less than 48 hours ago, and messages of others if it is a chat admin.
+func (c *Client) DeleteMessage(ctx context.Context, chatID int, messageID int) error {
+	query := url.Values{}
+	query.Add("chat_id", strconv.Itoa(chatID))
+	query.Add("message_id", strconv.Itoa(messageID))
+
+	if _, err := c.doRequest(ctx, deleteMessageMethod, query); err != nil {
+		return e.Wrap("cannot delete message", err)
+	}
+
+	return nil
+}
+
+// DeleteMessages deletes messages of the chat, skipping the ones which can't
+// be deleted. Any number of messages may be given, they are sent in batches.
+func (c *Client) DeleteMessages(ctx context.Context, chatID int, messageIDs []int) error {
+	for start := 0; start < len(messageIDs); start += maxDeleteMessages {
+		batch := messageIDs[start:min(start+maxDeleteMessages, len(messageIDs))]
+
+		ids, err := json.Marshal(batch)
+		if err != nil {
+			return e.Wrap("cannot delete messages", err)
+		}
+
+		query := url.Values{}
+		query.Add("chat_id", strconv.Itoa(chatID))
+		query.Add("message_ids", string(ids))
+
+		if _, err := c.doRequest(ctx, deleteMessagesMethod, query); err != nil {
+			return e.Wrap("cannot delete messages", err)
+		}
 	}
 
 	return nil
 }
 
-func (c *Client) AnswerCallbackQuery(ans CallbackQueryConfig) (err error) {
+func (c *Client) AnswerCallbackQuery(ctx context.Context, ans CallbackQueryConfig) (err error) {
 	query := url.Values{}
 	query.Add("callback_query_id", ans.CallbackQueryId)
-	query.Add("text", *ans.Text)
+	if ans.Text != nil {
+		query.Add("text", *ans.Text)
+	}
 	if ans.ShowAlert != nil && *ans.ShowAlert {
 		query.Add("show_alert", "true")
 	}
 
-	_, err = c.doRequest(answerCallbackQuery, query)
+	_, err = c.doRequest(ctx, answerCallbackQuery, query)
 	if err != nil {
 		return e.Wrap("cannot answer to callback_query", err)
 	}
@@ -90,37 +225,87 @@ func (c *Client) AnswerCallbackQuery(ans CallbackQueryConfig) (err error) {
 	return nil
 }
 
-func (c *Client) doRequest(method string, query url.Values) (data []byte, err error) {
+// doMessageRequest calls a method which returns a message.
+func (c *Client) doMessageRequest(ctx context.Context, method string, query url.Values) (Message, error) {
+	data, err := c.doRequest(ctx, method, query)
+	if err != nil {
+		return Message{}, err
+	}
+
+	var res MessageResponse
+
+	if err := json.Unmarshal(data, &res); err != nil {
+		return Message{}, err
+	}
+
+	return res.Result, nil
+}
+
+func addReplyMarkup(query url.Values, replyMarkup interface{}) error {
+	if replyMarkup == nil {
+		return nil
+	}
+
+	data, err := json.Marshal(replyMarkup)
+	if err != nil {
+		return e.Wrap("can't marshal reply markup", err)
+	}
+	query.Add("reply_markup", string(data))
+
+	return nil
+}
+
+func (c *Client) doRequest(ctx context.Context, method string, query url.Values) (data []byte, err error) {
+	ctx, span := tracing.Start(ctx, "telegram."+method, attribute.String("rpc.method", method))
 	defer func() {
+		err = scrubToken(err, c.token)
+		tracing.End(span, err)
 		err = e.WrapIfErr("cannot send http request", err)
 	}()
 
-	u := url.URL{
-		Scheme: "https",
-		Host:   c.host,
-		Path:   path.Join(c.basePath, method),
-	}
+	u := c.baseURL
+	u.Path = path.Join("/", c.baseURL.Path, c.basePath, method)
 
-	req, err := http.NewRequest(http.MethodGet, u.String(), nil)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
 	if err != nil {
 		return nil, err
 	}
 
 	req.URL.RawQuery = query.Encode()
 
+	start := time.Now()
+
 	resp, err := c.client.Do(req)
 	if err != nil {
+		metrics.ObserveTelegramNetworkError(method, start)
+		err = scrubToken(requestError(err), c.token)
+		logging.FromContext(ctx).Debug("telegram request failed", "method", method, "duration", time.Since(start), logging.Err(err))
 		return nil, err
 	}
 
+	span.SetAttributes(attribute.Int("http.response.status_code", resp.StatusCode))
+
 	defer func() {
-		err = e.WrapIfErr("can't close response body", resp.Body.Close())
+		if cErr := resp.Body.Close(); cErr != nil && err == nil {
+			err = e.Wrap("can't close response body", cErr)
+		}
 	}()
 
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
+		metrics.ObserveTelegramNetworkError(method, start)
 		return nil, err
 	}
 
+	if err := apiError(body, resp.StatusCode); err != nil {
+		metrics.ObserveTelegram(method, errorCode(err), start)
+		span.SetAttributes(attribute.Int("telegram.error_code", errorCode(err)))
+		logging.FromContext(ctx).Debug("telegram request rejected", "method", method, "duration", time.Since(start), logging.Err(err))
+		return nil, err
+	}
+
+	metrics.ObserveTelegram(method, http.StatusOK, start)
+	logging.FromContext(ctx).Debug("telegram request", "method", method, "duration", time.Since(start))
+
 	return body, nil
 }
@@ -0,0 +1,197 @@
+package telegram_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"telegrambot/pkg/clients/telegram"
+	"telegrambot/pkg/clients/telegram/telegramtest"
+	"testing"
+)
+
+const chatID = 100
+
+func newClient(t *testing.T) (*telegram.Client, *telegramtest.Server) {
+	t.Helper()
+
+	srv := telegramtest.NewServer()
+	t.Cleanup(srv.Close)
+
+	tg, err := telegram.NewWithURL(srv.URL, telegramtest.Token)
+	if err != nil {
+		t.Fatalf("NewWithURL: %v", err)
+	}
+
+	return tg, srv
+}
+
+func send(t *testing.T, tg *telegram.Client, text string, markup any) telegram.Message {
+	t.Helper()
+
+	m, err := tg.SendMessage(context.Background(), telegram.MessageConfig{ChatID: chatID, Text: text, ReplyMarkup: markup})
+	if err != nil {
+		t.Fatalf("SendMessage: %v", err)
+	}
+
+	return m
+}
+
+func keyboard(data string) telegram.InlineKeyboardMarkup {
+	return telegram.InlineKeyboardMarkup{
+		InlineKeyboard: [][]telegram.InlineKeyboardButton{{{Text: data, CallbackData: &data}}},
+	}
+}
+
+func wantCode(t *testing.T, what string, err error, code int) {
+	t.Helper()
+
+	var apiErr *telegram.Error
+	if !errors.As(err, &apiErr) || apiErr.Code != code {
+		t.Fatalf("%s: err = %v, want telegram api error %d", what, err, code)
+	}
+}
+
+func TestEditMessageText(t *testing.T) {
+	tg, srv := newClient(t)
+	ctx := context.Background()
+
+	sent := send(t, tg, "draft", keyboard("draft"))
+
+	edited, err := tg.EditMessageText(ctx, telegram.EditMessageTextConfig{
+		ChatID:      chatID,
+		MessageID:   sent.MessageID,
+		Text:        "<b>final</b>",
+		ParseMode:   telegram.ParseModeHTML,
+		ReplyMarkup: keyboard("final"),
+	})
+	if err != nil {
+		t.Fatalf("EditMessageText: %v", err)
+	}
+	if edited.MessageID != sent.MessageID || edited.Text != "<b>final</b>" {
+		t.Errorf("EditMessageText = %+v, want message %d with the new text", edited, sent.MessageID)
+	}
+
+	msgs := srv.Messages()
+	if len(msgs) != 1 || msgs[0].Text != "<b>final</b>" || msgs[0].ParseMode != telegram.ParseModeHTML {
+		t.Fatalf("messages = %+v, want the edited one", msgs)
+	}
+	if buttons := msgs[0].Buttons(); len(buttons) != 1 || *buttons[0].CallbackData != "final" {
+		t.Errorf("buttons = %+v, want the new keyboard", buttons)
+	}
+
+	_, err = tg.EditMessageText(ctx, telegram.EditMessageTextConfig{
+		ChatID:      chatID,
+		MessageID:   sent.MessageID,
+		Text:        "<b>final</b>",
+		ParseMode:   telegram.ParseModeHTML,
+		ReplyMarkup: keyboard("final"),
+	})
+	if !telegram.IsNotModified(err) {
+		t.Errorf("edit changing nothing: err = %v, want not modified", err)
+	}
+
+	_, err = tg.EditMessageText(ctx, telegram.EditMessageTextConfig{ChatID: chatID, MessageID: 999, Text: "lost"})
+	wantCode(t, "edit of an unknown message", err, http.StatusBadRequest)
+}
+
+func TestEditMessageReplyMarkup(t *testing.T) {
+	tg, srv := newClient(t)
+	ctx := context.Background()
+
+	sent := send(t, tg, "pick", keyboard("delete"))
+
+	edited, err := tg.EditMessageReplyMarkup(ctx, telegram.EditMessageReplyMarkupConfig{
+		ChatID:      chatID,
+		MessageID:   sent.MessageID,
+		ReplyMarkup: keyboard("restore"),
+	})
+	if err != nil {
+		t.Fatalf("EditMessageReplyMarkup: %v", err)
+	}
+	if edited.Text != "pick" {
+		t.Errorf("EditMessageReplyMarkup = %+v, want the text kept", edited)
+	}
+
+	if buttons := srv.Messages()[0].Buttons(); len(buttons) != 1 || *buttons[0].CallbackData != "restore" {
+		t.Errorf("buttons = %+v, want the new keyboard", buttons)
+	}
+
+	// a nil keyboard removes the buttons
+	_, err = tg.EditMessageReplyMarkup(ctx, telegram.EditMessageReplyMarkupConfig{ChatID: chatID, MessageID: sent.MessageID})
+	if err != nil {
+		t.Fatalf("EditMessageReplyMarkup without keyboard: %v", err)
+	}
+	if buttons := srv.Messages()[0].Buttons(); len(buttons) != 0 {
+		t.Errorf("buttons = %+v, want none", buttons)
+	}
+
+	if edits := srv.Edits(); len(edits) != 2 {
+		t.Errorf("edits = %+v, want two", edits)
+	}
+}
+
+func TestDeleteMessage(t *testing.T) {
+	tg, srv := newClient(t)
+	ctx := context.Background()
+
+	first := send(t, tg, "first", nil)
+	send(t, tg, "second", nil)
+
+	if err := tg.DeleteMessage(ctx, chatID, first.MessageID); err != nil {
+		t.Fatalf("DeleteMessage: %v", err)
+	}
+
+	if msgs := srv.Messages(); len(msgs) != 1 || msgs[0].Text != "second" {
+		t.Errorf("messages = %+v, want the second one left", msgs)
+	}
+
+	err := tg.DeleteMessage(ctx, chatID, first.MessageID)
+	wantCode(t, "second DeleteMessage", err, http.StatusBadRequest)
+}
+
+func TestDeleteMessagesBatches(t *testing.T) {
+	tests := []struct {
+		messages int
+		calls    int
+	}{
+		{0, 0},
+		{100, 1},
+		{101, 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(fmt.Sprint(tt.messages), func(t *testing.T) {
+			tg, srv := newClient(t)
+
+			ids := make([]int, 0, tt.messages)
+			for i := 0; i < tt.messages; i++ {
+				ids = append(ids, send(t, tg, fmt.Sprintf("message %d", i), nil).MessageID)
+			}
+
+			if err := tg.DeleteMessages(context.Background(), chatID, ids); err != nil {
+				t.Fatalf("DeleteMessages: %v", err)
+			}
+
+			if got := srv.Calls("deleteMessages"); got != tt.calls {
+				t.Errorf("deleteMessages is called %d times, want %d", got, tt.calls)
+			}
+			if msgs := srv.Messages(); len(msgs) != 0 {
+				t.Errorf("%d messages are left", len(msgs))
+			}
+		})
+	}
+}
+
+func TestAnswerCallbackQueryWithoutText(t *testing.T) {
+	tg, srv := newClient(t)
+
+	err := tg.AnswerCallbackQuery(context.Background(), telegram.CallbackQueryConfig{CallbackQueryId: "1"})
+	if err != nil {
+		t.Fatalf("AnswerCallbackQuery: %v", err)
+	}
+
+	if answers := srv.Answers(); len(answers) != 1 || answers[0].CallbackQueryID != "1" || answers[0].Text != "" {
+		t.Errorf("answers = %+v, want an answer without text", answers)
+	}
+}
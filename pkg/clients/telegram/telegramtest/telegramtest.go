@@ -0,0 +1,712 @@
+package telegramtest
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"telegrambot/pkg/clients/telegram"
+	"time"
+)
+
+// Token is the bot token the server accepts.
+const Token = "123456:test-token"
+
+// maxUploadSize limits files the bot may upload.
+const maxUploadSize = 10 << 20
+
+// maxDeleteMessages is how many messages deleteMessages takes at once.
+const maxDeleteMessages = 100
+
+// Server is an in-process fake of the Telegram Bot API. Updates queued by
+// a test are returned by getUpdates, and whatever the bot sends is recorded:
+//
+//	srv := telegramtest.NewServer()
+//	defer srv.Close()
+//
+//	tg, _ := telegram.NewWithURL(srv.URL, telegramtest.Token)
+//	// run a consumer with tg...
+//
+//	srv.SendText(alice, "/help")
+//	msgs, err := srv.WaitMessages(1, time.Second)
+type Server struct {
+	// URL is the base url of the Bot API, e.g. http://127.0.0.1:41234.
+	URL string
+	// Bot is returned by getMe.
+	Bot telegram.User
+
+	srv *httptest.Server
+
+	mu             sync.Mutex
+	changed        chan struct{}
+	updates        []telegram.Update
+	lastUpdateID   int
+	lastMessageID  int
+	lastCallbackID int
+	chats          map[int]telegram.Chat
+	messages       []*Message
+	answers        []CallbackAnswer
+	edits          []Edit
+	files          []File
+	failures       map[string][]*telegram.Error
+	calls          map[string]int
+}
+
+// Message is a message sent by the bot.
+type Message struct {
+	MessageID int
+	ChatID    int
+	Text      string
+	ParseMode string
+	// ReplyMarkup is the keyboard as the bot sent it, in JSON.
+	ReplyMarkup string
+	SentAt      time.Time
+}
+
+// Buttons returns the buttons of the inline keyboard of the message, row
+// after row.
+func (m *Message) Buttons() []telegram.InlineKeyboardButton {
+	var markup telegram.InlineKeyboardMarkup
+	if err := json.Unmarshal([]byte(m.ReplyMarkup), &markup); err != nil {
+		return nil
+	}
+
+	var buttons []telegram.InlineKeyboardButton
+	for _, row := range markup.InlineKeyboard {
+		buttons = append(buttons, row...)
+	}
+
+	return buttons
+}
+
+// CallbackAnswer is an answer of the bot to a pressed button.
+type CallbackAnswer struct {
+	CallbackQueryID string
+	Text            string
+	ShowAlert       bool
+}
+
+// Edit is a change of a message sent by the bot before.
+type Edit struct {
+	ChatID      int
+	MessageID   int
+	Text        string
+	ReplyMarkup string
+}
+
+// File is a file uploaded by the bot with sendDocument, sendPhoto and the
+// like.
+type File struct {
+	Method    string
+	ChatID    int
+	Name      string
+	Data      []byte
+	Caption   string
+	MessageID int
+}
+
+// NewServer starts the server. It must be closed when the test is done.
+func NewServer() *Server {
+	s := &Server{
+		Bot:      telegram.User{ID: 1, IsBot: true, FirstName: "Test Bot", Username: "test_bot"},
+		changed:  make(chan struct{}),
+		chats:    make(map[int]telegram.Chat),
+		failures: make(map[string][]*telegram.Error),
+		calls:    make(map[string]int),
+	}
+
+	s.srv = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+	s.URL = s.srv.URL
+
+	return s
+}
+
+func (s *Server) Close() {
+	s.srv.Close()
+}
+
+// Queue adds an update for getUpdates and returns its id. The update id and
+// ids and dates of messages are assigned if the update has none.
+func (s *Server) Queue(u telegram.Update) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if u.UpdateId == 0 {
+		u.UpdateId = s.lastUpdateID + 1
+	}
+	s.lastUpdateID = max(s.lastUpdateID, u.UpdateId)
+
+	for _, m := range []*telegram.Message{u.Message, u.ChannelPost} {
+		if m == nil {
+			continue
+		}
+
+		if m.MessageID == 0 {
+			s.lastMessageID++
+			m.MessageID = s.lastMessageID
+		}
+		if m.Date == 0 {
+			m.Date = int(time.Now().Unix())
+		}
+
+		s.chats[m.Chat.ID] = m.Chat
+	}
+	if u.CallbackQuery != nil {
+		s.chats[u.CallbackQuery.Message.Chat.ID] = u.CallbackQuery.Message.Chat
+	}
+
+	s.updates = append(s.updates, u)
+	s.notify()
+
+	return u.UpdateId
+}
+
+// SendText queues a message of the user to the bot in their private chat.
+func (s *Server) SendText(from telegram.User, text string) int {
+	return s.SendChatText(from, telegram.Chat{ID: from.ID, Type: telegram.ChatTypePrivate}, text)
+}
+
+// SendChatText queues a message of the user in a chat, e.g. a group.
+func (s *Server) SendChatText(from telegram.User, chat telegram.Chat, text string) int {
+	return s.Queue(telegram.Update{
+		Message: &telegram.Message{Text: text, From: from, Chat: chat},
+	})
+}
+
+// Press queues a press of a button of the message sent by the bot and
+// returns the id of the callback query, which the bot is expected to answer.
+func (s *Server) Press(from telegram.User, m Message, data string) string {
+	s.mu.Lock()
+	s.lastCallbackID++
+	id := strconv.Itoa(s.lastCallbackID)
+	chat := s.chat(m.ChatID)
+	s.mu.Unlock()
+
+	s.Queue(telegram.Update{
+		CallbackQuery: &telegram.CallbackQuery{
+			ID:   id,
+			From: from,
+			Message: telegram.Message{
+				MessageID: m.MessageID,
+				Date:      int(m.SentAt.Unix()),
+				Text:      m.Text,
+				From:      s.Bot,
+				Chat:      chat,
+			},
+			Data: &data,
+		},
+	})
+
+	return id
+}
+
+// Fail makes the next call of the method fail with err, e.g. with code 403
+// as if the user had blocked the bot. Failures of a method are used up in
+// the order they were added.
+func (s *Server) Fail(method string, err telegram.Error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.failures[method] = append(s.failures[method], &err)
+}
+
+// Pending returns the number of queued updates the bot hasn't confirmed yet.
+func (s *Server) Pending() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return len(s.updates)
+}
+
+// Calls returns how many times the bot has called the method.
+func (s *Server) Calls(method string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.calls[method]
+}
+
+// Messages returns the messages sent by the bot, with edits applied.
+func (s *Server) Messages() []Message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	res := make([]Message, 0, len(s.messages))
+	for _, m := range s.messages {
+		res = append(res, *m)
+	}
+
+	return res
+}
+
+// MessagesTo returns the messages sent by the bot to the chat.
+func (s *Server) MessagesTo(chatID int) []Message {
+	var res []Message
+	for _, m := range s.Messages() {
+		if m.ChatID == chatID {
+			res = append(res, m)
+		}
+	}
+
+	return res
+}
+
+func (s *Server) Answers() []CallbackAnswer {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]CallbackAnswer(nil), s.answers...)
+}
+
+func (s *Server) Edits() []Edit {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]Edit(nil), s.edits...)
+}
+
+func (s *Server) Files() []File {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]File(nil), s.files...)
+}
+
+// WaitMessages waits until the bot has sent at least n messages and returns
+// all of them.
+func (s *Server) WaitMessages(n int, timeout time.Duration) ([]Message, error) {
+	ok := s.wait(timeout, func() bool { return len(s.messages) >= n })
+	msgs := s.Messages()
+	if !ok {
+		return msgs, fmt.Errorf("got %d messages in %s, want %d", len(msgs), timeout, n)
+	}
+
+	return msgs, nil
+}
+
+// WaitAnswers waits until the bot has answered at least n callback queries
+// and returns all answers.
+func (s *Server) WaitAnswers(n int, timeout time.Duration) ([]CallbackAnswer, error) {
+	ok := s.wait(timeout, func() bool { return len(s.answers) >= n })
+	answers := s.Answers()
+	if !ok {
+		return answers, fmt.Errorf("got %d callback answers in %s, want %d", len(answers), timeout, n)
+	}
+
+	return answers, nil
+}
+
+// WaitIdle waits until the bot has confirmed all queued updates, which it
+// does by fetching the next ones: a bot which handles a batch before fetching
+// the next one has handled them all by then.
+func (s *Server) WaitIdle(timeout time.Duration) error {
+	if !s.wait(timeout, func() bool { return len(s.updates) == 0 }) {
+		return fmt.Errorf("%d updates are still queued after %s", s.Pending(), timeout)
+	}
+
+	return nil
+}
+
+// wait waits until done, which is called with the server locked, returns true.
+func (s *Server) wait(timeout time.Duration, done func() bool) bool {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	for {
+		s.mu.Lock()
+		ok := done()
+		changed := s.changed
+		s.mu.Unlock()
+
+		if ok {
+			return true
+		}
+
+		select {
+		case <-changed:
+		case <-timer.C:
+			return false
+		}
+	}
+}
+
+// notify wakes up waiters. The server must be locked.
+func (s *Server) notify() {
+	close(s.changed)
+	s.changed = make(chan struct{})
+}
+
+type response struct {
+	Ok          bool                `json:"ok"`
+	Result      any                 `json:"result,omitempty"`
+	ErrorCode   int                 `json:"error_code,omitempty"`
+	Description string              `json:"description,omitempty"`
+	Parameters  *responseParameters `json:"parameters,omitempty"`
+}
+
+type responseParameters struct {
+	RetryAfter int `json:"retry_after,omitempty"`
+}
+
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	token, method, ok := strings.Cut(strings.TrimPrefix(r.URL.Path, "/bot"), "/")
+	if !ok || !strings.HasPrefix(r.URL.Path, "/bot") {
+		writeError(w, &telegram.Error{Code: http.StatusNotFound, Description: "Not Found"})
+		return
+	}
+	if token != Token {
+		writeError(w, &telegram.Error{Code: http.StatusUnauthorized, Description: "Unauthorized"})
+		return
+	}
+
+	s.mu.Lock()
+	s.calls[method]++
+	s.mu.Unlock()
+
+	if err := parseForm(r); err != nil {
+		writeError(w, &telegram.Error{Code: http.StatusBadRequest, Description: "Bad Request: " + err.Error()})
+		return
+	}
+
+	if err := s.failure(method); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	var result any
+	var err *telegram.Error
+
+	switch method {
+	case "getMe":
+		result = s.Bot
+	case "getUpdates":
+		result, err = s.getUpdates(r)
+	case "sendMessage":
+		result, err = s.sendMessage(r)
+	case "answerCallbackQuery":
+		result, err = s.answerCallbackQuery(r)
+	case "editMessageText", "editMessageReplyMarkup":
+		result, err = s.editMessage(r, method == "editMessageText")
+	case "deleteMessage":
+		result, err = s.deleteMessages(r, []string{r.Form.Get("message_id")})
+	case "deleteMessages":
+		var ids []int
+		if jErr := json.Unmarshal([]byte(r.Form.Get("message_ids")), &ids); jErr != nil {
+			err = badRequest("message_ids must be a JSON array")
+			break
+		}
+		if len(ids) == 0 || len(ids) > maxDeleteMessages {
+			err = badRequest("too many message identifiers specified")
+			break
+		}
+		strIDs := make([]string, 0, len(ids))
+		for _, id := range ids {
+			strIDs = append(strIDs, strconv.Itoa(id))
+		}
+		result, err = s.deleteMessages(r, strIDs)
+	case "sendDocument", "sendPhoto", "sendAudio", "sendVideo", "sendVoice":
+		result, err = s.sendFile(r, method)
+	default:
+		err = &telegram.Error{Code: http.StatusNotFound, Description: "Not Found: method not found"}
+	}
+
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, response{Ok: true, Result: result})
+}
+
+// failure returns an error added by Fail for the method.
+func (s *Server) failure(method string) *telegram.Error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	failures := s.failures[method]
+	if len(failures) == 0 {
+		return nil
+	}
+
+	s.failures[method] = failures[1:]
+
+	return failures[0]
+}
+
+// getUpdates confirms updates before offset and returns the following
+// ones without waiting for new updates.
+func (s *Server) getUpdates(r *http.Request) (any, *telegram.Error) {
+	offset, _ := strconv.Atoi(r.Form.Get("offset"))
+	limit, _ := strconv.Atoi(r.Form.Get("limit"))
+	if limit <= 0 || limit > 100 {
+		limit = 100
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	confirmed := 0
+	for confirmed < len(s.updates) && s.updates[confirmed].UpdateId < offset {
+		confirmed++
+	}
+	if confirmed > 0 {
+		s.updates = s.updates[confirmed:]
+		s.notify()
+	}
+
+	updates := s.updates[:min(limit, len(s.updates))]
+
+	return append([]telegram.Update{}, updates...), nil
+}
+
+func (s *Server) sendMessage(r *http.Request) (any, *telegram.Error) {
+	chatID, err := chatID(r)
+	if err != nil {
+		return nil, err
+	}
+
+	text := r.Form.Get("text")
+	if text == "" {
+		return nil, badRequest("message text is empty")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	m := s.addMessage(chatID, text, r.Form.Get("parse_mode"), r.Form.Get("reply_markup"))
+
+	return s.sent(m.MessageID, chatID, text, ""), nil
+}
+
+func (s *Server) answerCallbackQuery(r *http.Request) (any, *telegram.Error) {
+	id := r.Form.Get("callback_query_id")
+	if id == "" {
+		return nil, badRequest("query is too old and response timeout expired or query ID is invalid")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.answers = append(s.answers, CallbackAnswer{
+		CallbackQueryID: id,
+		Text:            r.Form.Get("text"),
+		ShowAlert:       r.Form.Get("show_alert") == "true",
+	})
+	s.notify()
+
+	return true, nil
+}
+
+func (s *Server) editMessage(r *http.Request, withText bool) (any, *telegram.Error) {
+	chatID, err := chatID(r)
+	if err != nil {
+		return nil, err
+	}
+
+	messageID, _ := strconv.Atoi(r.Form.Get("message_id"))
+	text := r.Form.Get("text")
+	if withText && text == "" {
+		return nil, badRequest("message text is empty")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	m := s.message(chatID, messageID)
+	if m == nil {
+		return nil, badRequest("message to edit not found")
+	}
+
+	if !withText {
+		text = m.Text
+	}
+	if text == m.Text && r.Form.Get("reply_markup") == m.ReplyMarkup {
+		return nil, badRequest("message is not modified")
+	}
+
+	m.Text = text
+	m.ReplyMarkup = r.Form.Get("reply_markup")
+	if withText {
+		m.ParseMode = r.Form.Get("parse_mode")
+	}
+
+	s.edits = append(s.edits, Edit{ChatID: chatID, MessageID: messageID, Text: text, ReplyMarkup: m.ReplyMarkup})
+	s.notify()
+
+	return s.sent(messageID, chatID, text, ""), nil
+}
+
+func (s *Server) deleteMessages(r *http.Request, ids []string) (any, *telegram.Error) {
+	chatID, err := chatID(r)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	deleted := 0
+	for _, id := range ids {
+		messageID, _ := strconv.Atoi(id)
+
+		for i, m := range s.messages {
+			if m.ChatID == chatID && m.MessageID == messageID {
+				s.messages = append(s.messages[:i], s.messages[i+1:]...)
+				deleted++
+				break
+			}
+		}
+	}
+
+	if deleted == 0 && len(ids) == 1 {
+		return nil, badRequest("message to delete not found")
+	}
+
+	s.notify()
+
+	return true, nil
+}
+
+// sendFile takes a file uploaded as the multipart field named after the
+// method, e.g. document for sendDocument.
+func (s *Server) sendFile(r *http.Request, method string) (any, *telegram.Error) {
+	chatID, err := chatID(r)
+	if err != nil {
+		return nil, err
+	}
+
+	field := strings.ToLower(strings.TrimPrefix(method, "send"))
+
+	if r.MultipartForm == nil || len(r.MultipartForm.File[field]) == 0 {
+		return nil, badRequest("there is no " + field + " in the request")
+	}
+
+	header := r.MultipartForm.File[field][0]
+
+	f, fErr := header.Open()
+	if fErr != nil {
+		return nil, badRequest(fErr.Error())
+	}
+	defer func() { _ = f.Close() }()
+
+	data, fErr := io.ReadAll(f)
+	if fErr != nil {
+		return nil, badRequest(fErr.Error())
+	}
+
+	caption := r.Form.Get("caption")
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	m := s.addMessage(chatID, caption, r.Form.Get("parse_mode"), r.Form.Get("reply_markup"))
+
+	s.files = append(s.files, File{
+		Method:    method,
+		ChatID:    chatID,
+		Name:      header.Filename,
+		Data:      data,
+		Caption:   caption,
+		MessageID: m.MessageID,
+	})
+
+	return s.sent(m.MessageID, chatID, "", caption), nil
+}
+
+// addMessage records a message of the bot. The server must be locked.
+func (s *Server) addMessage(chatID int, text string, parseMode string, replyMarkup string) *Message {
+	s.lastMessageID++
+
+	m := &Message{
+		MessageID:   s.lastMessageID,
+		ChatID:      chatID,
+		Text:        text,
+		ParseMode:   parseMode,
+		ReplyMarkup: replyMarkup,
+		SentAt:      time.Now(),
+	}
+
+	s.messages = append(s.messages, m)
+	s.notify()
+
+	return m
+}
+
+// message returns a message of the bot. The server must be locked.
+func (s *Server) message(chatID int, messageID int) *Message {
+	for _, m := range s.messages {
+		if m.ChatID == chatID && m.MessageID == messageID {
+			return m
+		}
+	}
+
+	return nil
+}
+
+// sent returns the message as the Bot API does. The server must be locked.
+func (s *Server) sent(messageID int, chatID int, text string, caption string) telegram.Message {
+	return telegram.Message{
+		MessageID: messageID,
+		Date:      int(time.Now().Unix()),
+		From:      s.Bot,
+		Chat:      s.chat(chatID),
+		Text:      text,
+		Caption:   caption,
+	}
+}
+
+// chat returns the chat as it was seen in updates, or guesses it by the id.
+// The server must be locked.
+func (s *Server) chat(chatID int) telegram.Chat {
+	chat, ok := s.chats[chatID]
+	if !ok {
+		chat = telegram.Chat{ID: chatID, Type: telegram.ChatTypePrivate}
+		if chatID < 0 {
+			chat.Type = telegram.ChatTypeGroup
+		}
+	}
+
+	return chat
+}
+
+func parseForm(r *http.Request) error {
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		return r.ParseMultipartForm(maxUploadSize)
+	}
+
+	return r.ParseForm()
+}
+
+func chatID(r *http.Request) (int, *telegram.Error) {
+	id, err := strconv.Atoi(r.Form.Get("chat_id"))
+	if err != nil {
+		return 0, badRequest("chat not found")
+	}
+
+	return id, nil
+}
+
+func badRequest(description string) *telegram.Error {
+	return &telegram.Error{Code: http.StatusBadRequest, Description: "Bad Request: " + description}
+}
+
+func writeError(w http.ResponseWriter, err *telegram.Error) {
+	res := response{ErrorCode: err.Code, Description: err.Description}
+	if err.RetryAfter > 0 {
+		res.Parameters = &responseParameters{RetryAfter: int(err.RetryAfter / time.Second)}
+	}
+
+	writeJSON(w, err.Code, res)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+
+	// an error means the client has gone, there is nobody to tell
+	_ = json.NewEncoder(w).Encode(v)
+}
@@ -5,16 +5,34 @@ type UpdateResponse struct {
 	Result []Update `json:"result"`
 }
 
+type UserResponse struct {
+	Ok     bool `json:"ok"`
+	Result User `json:"result"`
+}
+
+type MessageResponse struct {
+	Ok     bool    `json:"ok"`
+	Result Message `json:"result"`
+}
+
 type Update struct {
 	UpdateId      int            `json:"update_id"`
 	Message       *Message       `json:"message,omitempty"`
+	ChannelPost   *Message       `json:"channel_post,omitempty"`
 	CallbackQuery *CallbackQuery `json:"callback_query,omitempty"`
 }
 
 type Message struct {
-	Text string `json:"text"`
-	From User   `json:"from"`
-	Chat Chat   `json:"chat"`
+	MessageID int    `json:"message_id"`
+	Text      string `json:"text"`
+	Caption   string `json:"caption,omitempty"`
+	From      User   `json:"from"`
+	Chat      Chat   `json:"chat"`
+	// Date is the unix time the message was sent at.
+	Date int `json:"date"`
+	// ReplyToMessage is the message this one replies to. Its own
+	// ReplyToMessage is always empty.
+	ReplyToMessage *Message `json:"reply_to_message,omitempty"`
 }
 
 type CallbackQuery struct {
@@ -25,11 +43,24 @@ type CallbackQuery struct {
 }
 
 type User struct {
-	Username string `json:"username"`
+	ID        int    `json:"id"`
+	IsBot     bool   `json:"is_bot"`
+	FirstName string `json:"first_name"`
+	Username  string `json:"username"`
 }
 
+const (
+	ChatTypePrivate    = "private"
+	ChatTypeGroup      = "group"
+	ChatTypeSupergroup = "supergroup"
+	ChatTypeChannel    = "channel"
+)
+
 type Chat struct {
-	ID int `json:"id"`
+	ID       int    `json:"id"`
+	Type     string `json:"type"`
+	Title    string `json:"title,omitempty"`
+	Username string `json:"username,omitempty"`
 }
 
 type KeyboardButton struct {
@@ -52,9 +83,28 @@ type InlineKeyboardMarkup struct {
 	InlineKeyboard [][]InlineKeyboardButton `json:"inline_keyboard"`
 }
 
+const ParseModeHTML = "HTML"
+
 type MessageConfig struct {
 	ChatID      int         `json:"chat_id"`
 	Text        string      `json:"text"`
+	ParseMode   string      `json:"parse_mode,omitempty"`
+	ReplyMarkup interface{} `json:"reply_markup,omitempty"`
+}
+
+type EditMessageTextConfig struct {
+	ChatID      int         `json:"chat_id"`
+	MessageID   int         `json:"message_id"`
+	Text        string      `json:"text"`
+	ParseMode   string      `json:"parse_mode,omitempty"`
+	ReplyMarkup interface{} `json:"reply_markup,omitempty"`
+}
+
+// EditMessageReplyMarkupConfig changes the keyboard of a message, a nil
+// ReplyMarkup removes it.
+type EditMessageReplyMarkupConfig struct {
+	ChatID      int         `json:"chat_id"`
+	MessageID   int         `json:"message_id"`
 	ReplyMarkup interface{} `json:"reply_markup,omitempty"`
 }
 
@@ -2,9 +2,11 @@ package event_consumer
 
 import (
 	"context"
-	"log"
+	"log/slog"
 	"sync"
+	"telegrambot/internal/logging"
 	"telegrambot/pkg/events"
+	"telegrambot/pkg/metrics"
 	"time"
 )
 
@@ -22,29 +24,41 @@ func New(fetcher events.Fetcher, processor events.Processor, batchSize int) Cons
 	}
 }
 
-func (c Consumer) Start() error {
-	for {
-		gotEvents, err := c.fetcher.Fetch(c.batchSize)
+// Start fetches and handles events until ctx is done. Fetched events are
+// handled to the end even then, so that none of them is lost.
+func (c Consumer) Start(ctx context.Context) error {
+	for ctx.Err() == nil {
+		gotEvents, err := c.fetcher.Fetch(ctx, c.batchSize)
+		if ctx.Err() != nil && len(gotEvents) == 0 {
+			break
+		}
 		if err != nil {
-			log.Printf("[ERR] consumer: %s", err.Error())
+			slog.Error("consumer: can't fetch events", logging.Err(err))
 			continue
 		}
 
+		metrics.UpdatesFetched.Add(float64(len(gotEvents)))
+
 		if len(gotEvents) == 0 {
-			time.Sleep(1 * time.Second)
+			select {
+			case <-ctx.Done():
+			case <-time.After(1 * time.Second):
+			}
 
 			continue
 		}
 
-		if err = c.handleEvents(gotEvents); err != nil {
-			log.Printf("[ERR] consumer: %s", err.Error())
+		if err = c.handleEvents(context.WithoutCancel(ctx), gotEvents); err != nil {
+			slog.Error("consumer: can't handle events", logging.Err(err))
 
 			continue
 		}
 	}
+
+	return nil
 }
 
-func (c Consumer) handleEvents(eventsArr []events.Event) error {
+func (c Consumer) handleEvents(ctx context.Context, eventsArr []events.Event) error {
 	semaphore := make(chan struct{}, 5)
 
 	var wg sync.WaitGroup
@@ -55,12 +69,24 @@ func (c Consumer) handleEvents(eventsArr []events.Event) error {
 			semaphore <- struct{}{}
 			defer func() { <-semaphore }()
 
-			log.Printf("got new event: %s", event.Text)
+			metrics.WorkersInFlight.Inc()
+			defer metrics.WorkersInFlight.Dec()
 
-			if err := c.processor.Process(context.TODO(), e); err != nil {
-				log.Printf("cant't handle event: %s", err.Error())
+			logger := slog.Default().With("event_type", e.Type.String()).With(e.LogAttrs...)
+			ctx := logging.WithLogger(ctx, logger)
+
+			logger.Debug("got new event")
+
+			start := time.Now()
+			err := c.processor.Process(ctx, e)
+			metrics.ObserveEvent(e.Type.String(), err, start)
+
+			if err != nil {
+				logger.Error("can't handle event", "duration", time.Since(start), logging.Err(err))
 				return
 			}
+
+			logger.Info("event handled", "duration", time.Since(start))
 		}(event)
 	}
 
@@ -0,0 +1,160 @@
+package deadletter
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"telegrambot/internal/e"
+	"telegrambot/pkg/clients/telegram"
+	"time"
+)
+
+// Queue keeps updates the bot failed to handle in a JSON lines file, so that
+// they can be replayed once the cause is fixed. Unlike recordings, updates
+// are kept as they are: replies to them go to the real chats.
+type Queue struct {
+	mu   sync.Mutex
+	path string
+}
+
+// Letter is a failed update with the reason it failed.
+type Letter struct {
+	Update   telegram.Update `json:"update"`
+	Error    string          `json:"error"`
+	FailedAt time.Time       `json:"failed_at"`
+}
+
+// Stats is the outcome of a replay.
+type Stats struct {
+	Replayed int
+	Failed   int
+}
+
+func New(path string) (*Queue, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0774); err != nil {
+		return nil, e.Wrap("can't create dead letter queue", err)
+	}
+
+	return &Queue{path: path}, nil
+}
+
+// Add appends the update. The file is opened for every update, so that a
+// replay may move it away while the bot is running.
+func (q *Queue) Add(u telegram.Update, reason error) error {
+	return e.WrapIfErr("can't add dead letter", q.append(Letter{
+		Update:   u,
+		Error:    reason.Error(),
+		FailedAt: time.Now(),
+	}))
+}
+
+func (q *Queue) append(letters ...Letter) (err error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	f, err := os.OpenFile(q.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if cErr := f.Close(); cErr != nil && err == nil {
+			err = cErr
+		}
+	}()
+
+	w := bufio.NewWriter(f)
+	enc := json.NewEncoder(w)
+	for _, l := range letters {
+		if err := enc.Encode(l); err != nil {
+			return err
+		}
+	}
+
+	return w.Flush()
+}
+
+// Replay calls fn for every kept update in the order they failed. Updates fn
+// fails for are kept with the new error, the rest are removed. The queue is
+// moved aside first, so updates failing meanwhile are kept as well. A replay
+// which is interrupted resumes from where it stopped, an update being
+// replayed then may be handled twice.
+func (q *Queue) Replay(ctx context.Context, fn func(ctx context.Context, u telegram.Update) error) (_ Stats, err error) {
+	defer func() { err = e.WrapIfErr("can't replay dead letters", err) }()
+
+	pending := q.path + ".replaying"
+
+	if _, err := os.Stat(pending); errors.Is(err, fs.ErrNotExist) {
+		err := os.Rename(q.path, pending)
+		if errors.Is(err, fs.ErrNotExist) {
+			return Stats{}, nil
+		}
+		if err != nil {
+			return Stats{}, err
+		}
+	} else if err != nil {
+		return Stats{}, err
+	}
+
+	letters, err := read(pending)
+	if err != nil {
+		return Stats{}, err
+	}
+
+	var stats Stats
+	for i, l := range letters {
+		if ctx.Err() != nil {
+			// the rest is kept as it was
+			if err := q.append(letters[i:]...); err != nil {
+				return stats, err
+			}
+			break
+		}
+
+		if fnErr := fn(ctx, l.Update); fnErr != nil {
+			stats.Failed++
+			if err := q.Add(l.Update, fnErr); err != nil {
+				return stats, err
+			}
+			continue
+		}
+
+		stats.Replayed++
+	}
+
+	if err := os.Remove(pending); err != nil {
+		return stats, err
+	}
+
+	return stats, ctx.Err()
+}
+
+func read(path string) ([]Letter, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = f.Close() }()
+
+	var letters []Letter
+
+	dec := json.NewDecoder(bufio.NewReader(f))
+	for {
+		var l Letter
+
+		err := dec.Decode(&l)
+		if errors.Is(err, io.EOF) {
+			return letters, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		letters = append(letters, l)
+	}
+}
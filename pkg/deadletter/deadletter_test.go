@@ -0,0 +1,142 @@
+package deadletter
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"telegrambot/pkg/clients/telegram"
+	"testing"
+)
+
+func newQueue(t *testing.T) *Queue {
+	t.Helper()
+
+	q, err := New(filepath.Join(t.TempDir(), "dlq", "failed.jsonl"))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	return q
+}
+
+func add(t *testing.T, q *Queue, ids ...int) {
+	t.Helper()
+
+	for _, id := range ids {
+		if err := q.Add(telegram.Update{UpdateId: id}, errors.New("boom")); err != nil {
+			t.Fatalf("Add: %v", err)
+		}
+	}
+}
+
+// replay replays the queue failing the given updates and returns the ids of
+// all replayed ones.
+func replay(t *testing.T, q *Queue, failing ...int) ([]int, Stats) {
+	t.Helper()
+
+	var ids []int
+	stats, err := q.Replay(context.Background(), func(_ context.Context, u telegram.Update) error {
+		ids = append(ids, u.UpdateId)
+		if slices.Contains(failing, u.UpdateId) {
+			return errors.New("still broken")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Replay: %v", err)
+	}
+
+	return ids, stats
+}
+
+func TestReplayKeepsFailedUpdates(t *testing.T) {
+	q := newQueue(t)
+	add(t, q, 1, 2, 3)
+
+	ids, stats := replay(t, q, 2)
+	if !slices.Equal(ids, []int{1, 2, 3}) {
+		t.Errorf("replayed %v, want 1, 2, 3", ids)
+	}
+	if stats != (Stats{Replayed: 2, Failed: 1}) {
+		t.Errorf("stats = %+v, want 2 replayed and 1 failed", stats)
+	}
+
+	letters, err := read(q.path)
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	if len(letters) != 1 || letters[0].Update.UpdateId != 2 || letters[0].Error != "still broken" {
+		t.Errorf("kept %+v, want update 2 with the new error", letters)
+	}
+
+	ids, _ = replay(t, q)
+	if !slices.Equal(ids, []int{2}) {
+		t.Errorf("second replay replayed %v, want 2", ids)
+	}
+
+	ids, stats = replay(t, q)
+	if len(ids) != 0 || stats != (Stats{}) {
+		t.Errorf("replay of an empty queue replayed %v with %+v", ids, stats)
+	}
+}
+
+func TestReplayKeepsUpdatesFailingMeanwhile(t *testing.T) {
+	q := newQueue(t)
+	add(t, q, 1)
+
+	_, err := q.Replay(context.Background(), func(_ context.Context, u telegram.Update) error {
+		add(t, q, 2)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Replay: %v", err)
+	}
+
+	ids, _ := replay(t, q)
+	if !slices.Equal(ids, []int{2}) {
+		t.Errorf("replayed %v, want the update added during the first replay", ids)
+	}
+}
+
+func TestReplayCancelled(t *testing.T) {
+	q := newQueue(t)
+	add(t, q, 1, 2, 3)
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	_, err := q.Replay(ctx, func(_ context.Context, u telegram.Update) error {
+		cancel()
+		return nil
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("Replay: err = %v, want %v", err, context.Canceled)
+	}
+
+	ids, _ := replay(t, q)
+	if !slices.Equal(ids, []int{2, 3}) {
+		t.Errorf("replayed %v, want the updates left after cancelling", ids)
+	}
+}
+
+func TestReplayResumesInterrupted(t *testing.T) {
+	q := newQueue(t)
+	add(t, q, 1)
+
+	// a replay which was killed leaves the moved queue behind
+	if err := os.Rename(q.path, q.path+".replaying"); err != nil {
+		t.Fatal(err)
+	}
+	add(t, q, 2)
+
+	ids, _ := replay(t, q)
+	if !slices.Equal(ids, []int{1}) {
+		t.Errorf("replayed %v, want the interrupted update first", ids)
+	}
+
+	ids, _ = replay(t, q)
+	if !slices.Equal(ids, []int{2}) {
+		t.Errorf("replayed %v, want the queued update next", ids)
+	}
+}
@@ -0,0 +1,158 @@
+package telegram
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"telegrambot/internal/e"
+	"telegrambot/internal/logging"
+	"telegrambot/pkg/access"
+	"telegrambot/pkg/clients/telegram"
+	"telegrambot/pkg/events"
+	"time"
+)
+
+const (
+	BanCmd   = "/ban"
+	UnbanCmd = "/unban"
+	UsersCmd = "/users"
+)
+
+// authorize reports whether the event comes from a user or a channel which
+// may use the bot. Other users are told they have no access.
+func (p *Processor) authorize(ctx context.Context, event events.Event, meta Meta) (bool, error) {
+	if p.opts.Access == nil {
+		return true, nil
+	}
+
+	// channel posts have no sender, so the channel itself is authorized
+	id := meta.UserID
+	if meta.ChatType == telegram.ChatTypeChannel {
+		id = meta.ChatID
+	}
+
+	err := p.opts.Access.Authorize(ctx, id)
+	if err == nil {
+		return true, nil
+	}
+	if !access.IsForbidden(err) {
+		return false, err
+	}
+
+	logging.FromContext(ctx).Info("rejected event", "reason", err.Error())
+
+	// denied users are not throttled, so they are told at most once a while
+	notify, err := p.limiter.Allow(ctx, "forbidden:"+meta.userKey(), 1, throttleNoticeInterval, time.Now())
+	if err != nil || !notify {
+		return false, err
+	}
+
+	switch {
+	case event.Type == events.CallbackQuery:
+		text := msgForbidden
+		return false, p.tg.AnswerCallbackQuery(ctx, telegram.CallbackQueryConfig{
+			CallbackQueryId: meta.CallbackQueryId,
+			Text:            &text,
+		})
+	case meta.IsGroup():
+		return false, nil
+	default:
+		return false, p.sendMessage(ctx, telegram.MessageConfig{
+			ChatID: meta.ChatID,
+			Text:   msgForbidden,
+		})
+	}
+}
+
+func (p *Processor) isAdmin(meta Meta) bool {
+	return p.opts.Access != nil && p.opts.Access.IsAdmin(meta.UserID)
+}
+
+// doAdminCmd handles commands which are only known to admins.
+func (p *Processor) doAdminCmd(ctx context.Context, cmd string, args string, meta Meta) (err error) {
+	defer func() {
+		err = e.WrapIfErr("can't do admin cmd", err)
+	}()
+
+	msg := telegram.MessageConfig{
+		ChatID: meta.ChatID,
+	}
+
+	if !p.isAdmin(meta) {
+		msg.Text = msgUnknownCommand
+		return p.sendMessage(ctx, msg)
+	}
+
+	if cmd == UsersCmd {
+		msg.Text, err = p.formatUsers(ctx)
+		if err != nil {
+			return err
+		}
+		return p.sendMessage(ctx, msg)
+	}
+
+	userID, err := strconv.Atoi(args)
+	if err != nil {
+		msg.Text = fmt.Sprintf(msgBanUsage, cmd)
+		return p.sendMessage(ctx, msg)
+	}
+
+	if cmd == BanCmd {
+		err = p.opts.Access.Ban(ctx, userID)
+		msg.Text = fmt.Sprintf(msgBanned, userID)
+	} else {
+		err = p.opts.Access.Unban(ctx, userID)
+		msg.Text = fmt.Sprintf(msgUnbanned, userID)
+	}
+	if err != nil {
+		return err
+	}
+
+	return p.sendMessage(ctx, msg)
+}
+
+func (p *Processor) formatUsers(ctx context.Context) (string, error) {
+	banned, err := p.opts.Access.Banned(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	users, err := p.repository.Users(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	blocked := 0
+	for _, u := range users {
+		if u.IsBlocked() {
+			blocked++
+		}
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, msgUsersKnown, len(users), blocked)
+	writeIDs(&b, msgUsersAdmins, p.opts.Access.Admins())
+	writeIDs(&b, msgUsersAllowed, p.opts.Access.Allowed())
+	writeIDs(&b, msgUsersDenied, p.opts.Access.Denied())
+	writeIDs(&b, msgUsersBanned, banned)
+
+	return b.String(), nil
+}
+
+func writeIDs(b *strings.Builder, title string, ids []int) {
+	b.WriteString(title)
+
+	if len(ids) == 0 {
+		b.WriteString(" —\n")
+		return
+	}
+
+	for i, id := range ids {
+		if i > 0 {
+			b.WriteString(",")
+		}
+		fmt.Fprintf(b, " %d", id)
+	}
+	b.WriteString("\n")
+}
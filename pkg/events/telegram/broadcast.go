@@ -0,0 +1,471 @@
+package telegram
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"telegrambot/internal/e"
+	"telegrambot/internal/logging"
+	"telegrambot/pkg/access"
+	"telegrambot/pkg/clients/telegram"
+	"telegrambot/pkg/repository"
+	"telegrambot/pkg/state"
+	"time"
+)
+
+const BroadcastCmd = "/broadcast"
+
+const (
+	broadcastCallbackPrefix = "broadcast:"
+	broadcastSend           = "send"
+	broadcastCancel         = "cancel"
+
+	broadcastStatusArg = "status"
+	broadcastCancelArg = "cancel"
+)
+
+const (
+	// DefaultBroadcastRate keeps delivery below the Telegram limit of 30
+	// messages per second.
+	DefaultBroadcastRate = 20
+	// broadcastReportInterval is how often the admin is told about progress.
+	broadcastReportInterval = time.Minute
+	// touchInterval is how often the last visit of a user who keeps writing
+	// is saved.
+	touchInterval = 10 * time.Minute
+)
+
+// broadcastProgress is the state of the latest broadcast.
+type broadcastProgress struct {
+	Total     int
+	Delivered int
+	Blocked   int
+	Failed    int
+	StartedAt time.Time
+	Done      bool
+	// Interrupted is set if the bot was stopped before the broadcast was done.
+	Interrupted bool
+}
+
+func (b broadcastProgress) sent() int {
+	return b.Delivered + b.Blocked + b.Failed
+}
+
+// broadcast handles /broadcast [text|status|cancel]. Without text the next
+// message of the admin becomes the text.
+func (p *Processor) broadcast(ctx context.Context, args string, meta Meta) (err error) {
+	defer func() {
+		err = e.WrapIfErr("can't do cmd broadcast", err)
+	}()
+
+	msg := telegram.MessageConfig{
+		ChatID: meta.ChatID,
+	}
+
+	switch {
+	case !p.isAdmin(meta):
+		msg.Text = msgUnknownCommand
+	case meta.IsGroup():
+		msg.Text = msgBroadcastPrivate
+	case args == broadcastStatusArg:
+		msg.Text = p.formatBroadcastProgress()
+	case args == broadcastCancelArg:
+		if err := p.cache.DeleteState(ctx, broadcastWaitKey(meta.UserID)); err != nil {
+			return err
+		}
+		if err := p.cache.DeleteState(ctx, broadcastDraftKey(meta.UserID)); err != nil {
+			return err
+		}
+		msg.Text = msgBroadcastCanceled
+	case args == "":
+		if err := p.cache.SetState(ctx, broadcastWaitKey(meta.UserID), "1"); err != nil {
+			return err
+		}
+		msg.Text = msgBroadcastAsk
+	default:
+		return p.previewBroadcast(ctx, args, meta)
+	}
+
+	return p.sendMessage(ctx, msg)
+}
+
+// captureBroadcast takes the text of a broadcast the admin was asked for and
+// reports whether the message was consumed. Commands cancel the broadcast.
+func (p *Processor) captureBroadcast(ctx context.Context, text string, meta Meta) (bool, error) {
+	_, err := p.cache.GetState(ctx, broadcastWaitKey(meta.UserID))
+	if errors.Is(err, state.ErrNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, e.Wrap("can't capture broadcast", err)
+	}
+
+	if err := p.cache.DeleteState(ctx, broadcastWaitKey(meta.UserID)); err != nil {
+		return false, e.Wrap("can't capture broadcast", err)
+	}
+
+	if isCommand(text) {
+		return false, nil
+	}
+
+	if err := p.previewBroadcast(ctx, text, meta); err != nil {
+		return false, e.Wrap("can't capture broadcast", err)
+	}
+
+	return true, nil
+}
+
+// previewBroadcast saves the draft and asks the admin to confirm it.
+func (p *Processor) previewBroadcast(ctx context.Context, text string, meta Meta) error {
+	recipients, err := p.broadcastRecipients(ctx)
+	if err != nil {
+		return err
+	}
+
+	if err := p.cache.SetState(ctx, broadcastDraftKey(meta.UserID), text); err != nil {
+		return err
+	}
+
+	return p.sendMessage(ctx, telegram.MessageConfig{
+		ChatID:      meta.ChatID,
+		Text:        fmt.Sprintf(msgBroadcastPreview, len(recipients), text),
+		ReplyMarkup: broadcastKeyboard(),
+	})
+}
+
+// broadcastCallback starts or cancels delivery of the draft.
+func (p *Processor) broadcastCallback(ctx context.Context, action string, meta Meta) (string, error) {
+	if !p.isAdmin(meta) {
+		return msgUnknownAction, nil
+	}
+
+	text, err := p.cache.GetState(ctx, broadcastDraftKey(meta.UserID))
+	if errors.Is(err, state.ErrNotFound) {
+		return msgBroadcastNoDraft, nil
+	}
+	if err != nil {
+		return "", e.Wrap("can't get broadcast draft", err)
+	}
+
+	switch action {
+	case broadcastCancel:
+		if err := p.cache.DeleteState(ctx, broadcastDraftKey(meta.UserID)); err != nil {
+			return "", err
+		}
+		return msgBroadcastCanceled, nil
+	case broadcastSend:
+	default:
+		return msgUnknownAction, nil
+	}
+
+	recipients, err := p.broadcastRecipients(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	// the draft is kept if it can't be sent now
+	if p.isBroadcastRunning() {
+		return msgBroadcastRunning, nil
+	}
+
+	if err := p.cache.DeleteState(ctx, broadcastDraftKey(meta.UserID)); err != nil {
+		return "", err
+	}
+
+	progress, ok := p.startBroadcast(len(recipients))
+	if !ok {
+		return msgBroadcastRunning, nil
+	}
+
+	logging.FromContext(ctx).Info("broadcast started", "recipients", len(recipients))
+
+	// delivery outlives the event, so it is stopped by Shutdown rather than
+	// with the event
+	deliveryCtx := logging.WithLogger(p.background, logging.FromContext(ctx))
+	go func() {
+		defer p.backgroundWG.Done()
+		p.deliver(deliveryCtx, meta.ChatID, text, recipients, progress)
+	}()
+
+	return fmt.Sprintf(msgBroadcastStarted, len(recipients)), nil
+}
+
+func (p *Processor) isBroadcastRunning() bool {
+	p.broadcastMu.Lock()
+	defer p.broadcastMu.Unlock()
+
+	return p.lastBroadcast != nil && !p.lastBroadcast.Done
+}
+
+// startBroadcast creates progress of a new broadcast unless one is running
+// or the bot is stopping. The delivery must call backgroundWG.Done.
+func (p *Processor) startBroadcast(total int) (*broadcastProgress, bool) {
+	p.broadcastMu.Lock()
+	defer p.broadcastMu.Unlock()
+
+	if p.background.Err() != nil || p.lastBroadcast != nil && !p.lastBroadcast.Done {
+		return nil, false
+	}
+
+	p.lastBroadcast = &broadcastProgress{
+		Total:     total,
+		StartedAt: time.Now(),
+	}
+	p.backgroundWG.Add(1)
+
+	return p.lastBroadcast, true
+}
+
+// deliver sends text to the recipients at no more than BroadcastRate
+// messages per second and reports progress to the admin chat. It stops
+// when ctx is done.
+func (p *Processor) deliver(ctx context.Context, adminChatID int, text string, recipients []*repository.User, progress *broadcastProgress) {
+	rate := p.opts.BroadcastRate
+	if rate <= 0 {
+		rate = DefaultBroadcastRate
+	}
+
+	ticker := time.NewTicker(time.Second / time.Duration(rate))
+	defer ticker.Stop()
+
+	lastReport := time.Now()
+
+	for _, u := range recipients {
+		select {
+		case <-ctx.Done():
+		case <-ticker.C:
+		}
+		if ctx.Err() != nil {
+			break
+		}
+
+		err := p.sendBroadcast(ctx, u.ChatID, text)
+		if ctx.Err() != nil {
+			break
+		}
+
+		p.broadcastMu.Lock()
+		switch {
+		case err == nil:
+			progress.Delivered++
+		case telegram.IsForbidden(err):
+			progress.Blocked++
+		default:
+			progress.Failed++
+		}
+		p.broadcastMu.Unlock()
+
+		if telegram.IsForbidden(err) {
+			// the user is saved again as soon as they unblock the bot
+			p.forgetUser(u.ID)
+
+			if err := p.repository.MarkBlocked(ctx, u.ID, time.Now()); err != nil {
+				logging.FromContext(ctx).Error("broadcast: can't mark user as blocked", "recipient_id", u.ID, logging.Err(err))
+			}
+		} else if err != nil {
+			logging.FromContext(ctx).Warn("broadcast: can't deliver message", "recipient_id", u.ID, logging.Err(err))
+		}
+
+		if time.Since(lastReport) >= broadcastReportInterval {
+			lastReport = time.Now()
+			p.reportBroadcast(ctx, adminChatID)
+		}
+	}
+
+	p.broadcastMu.Lock()
+	progress.Done = true
+	progress.Interrupted = ctx.Err() != nil
+	p.broadcastMu.Unlock()
+
+	msg := "broadcast finished"
+	if progress.Interrupted {
+		msg = "broadcast interrupted"
+	}
+	logging.FromContext(ctx).Info(msg,
+		"total", progress.Total, "delivered", progress.Delivered, "blocked", progress.Blocked, "failed", progress.Failed)
+
+	// the admin is told about the interruption while the bot is stopping
+	p.reportBroadcast(context.WithoutCancel(ctx), adminChatID)
+}
+
+// sendBroadcast sends text to the chat, waiting once if Telegram asks to.
+func (p *Processor) sendBroadcast(ctx context.Context, chatID int, text string) error {
+	msg := telegram.MessageConfig{
+		ChatID: chatID,
+		Text:   text,
+	}
+
+	err := p.sendMessage(ctx, msg)
+	if wait := telegram.RetryAfter(err); wait > 0 {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(wait):
+		}
+		err = p.sendMessage(ctx, msg)
+	}
+
+	return err
+}
+
+func (p *Processor) reportBroadcast(ctx context.Context, chatID int) {
+	err := p.sendMessage(ctx, telegram.MessageConfig{
+		ChatID: chatID,
+		Text:   p.formatBroadcastProgress(),
+	})
+	if err != nil {
+		logging.FromContext(ctx).Error("can't report broadcast progress", logging.Err(err))
+	}
+}
+
+// broadcastRecipients returns users with a private chat who haven't blocked
+// the bot and still may use it.
+func (p *Processor) broadcastRecipients(ctx context.Context) ([]*repository.User, error) {
+	users, err := p.repository.Users(ctx)
+	if err != nil {
+		return nil, e.Wrap("can't get broadcast recipients", err)
+	}
+
+	recipients := make([]*repository.User, 0, len(users))
+	for _, u := range users {
+		if u.ChatID == 0 || u.IsBlocked() {
+			continue
+		}
+
+		if p.opts.Access != nil {
+			err := p.opts.Access.Authorize(ctx, u.ID)
+			if access.IsForbidden(err) {
+				continue
+			}
+			if err != nil {
+				return nil, e.Wrap("can't get broadcast recipients", err)
+			}
+		}
+
+		recipients = append(recipients, u)
+	}
+
+	return recipients, nil
+}
+
+func (p *Processor) formatBroadcastProgress() string {
+	p.broadcastMu.Lock()
+	defer p.broadcastMu.Unlock()
+
+	if p.lastBroadcast == nil {
+		return msgBroadcastNone
+	}
+
+	b := *p.lastBroadcast
+
+	var s strings.Builder
+	switch {
+	case b.Interrupted:
+		fmt.Fprintf(&s, msgBroadcastInterrupted, b.sent(), b.Total)
+	case b.Done:
+		s.WriteString(msgBroadcastDone)
+	default:
+		fmt.Fprintf(&s, msgBroadcastProgress, b.sent(), b.Total)
+	}
+	fmt.Fprintf(&s, msgBroadcastCounts, b.Delivered, b.Blocked, b.Failed, time.Since(b.StartedAt).Round(time.Second))
+
+	return s.String()
+}
+
+// registerUser remembers the sender, so that broadcasts can reach them. A
+// user who keeps writing is saved once per touchInterval unless they change.
+func (p *Processor) registerUser(ctx context.Context, meta Meta) error {
+	if meta.UserID == 0 {
+		return nil
+	}
+
+	u := &repository.User{
+		ID:        meta.UserID,
+		Username:  meta.Username,
+		FirstName: meta.FirstName,
+		LastSeen:  time.Now(),
+	}
+	if meta.ChatType == telegram.ChatTypePrivate {
+		u.ChatID = meta.ChatID
+	}
+
+	if !p.needsTouch(u) {
+		return nil
+	}
+
+	if err := p.repository.TouchUser(ctx, u); err != nil {
+		return err
+	}
+
+	p.rememberUser(u)
+
+	return nil
+}
+
+func (p *Processor) needsTouch(u *repository.User) bool {
+	p.touchedMu.Lock()
+	defer p.touchedMu.Unlock()
+
+	saved, ok := p.touched[u.ID]
+
+	return !ok ||
+		saved.Username != u.Username ||
+		saved.FirstName != u.FirstName ||
+		u.ChatID != 0 && saved.ChatID != u.ChatID ||
+		u.LastSeen.Sub(saved.LastSeen) >= touchInterval
+}
+
+func (p *Processor) rememberUser(u *repository.User) {
+	p.touchedMu.Lock()
+	defer p.touchedMu.Unlock()
+
+	if p.touched == nil {
+		p.touched = make(map[int]repository.User)
+	}
+
+	saved := *u
+	if saved.ChatID == 0 {
+		saved.ChatID = p.touched[u.ID].ChatID
+	}
+	p.touched[u.ID] = saved
+
+	// users who stopped writing are forgotten, so that the map doesn't grow
+	if u.LastSeen.Sub(p.lastTouchSweep) >= touchInterval {
+		p.lastTouchSweep = u.LastSeen
+		for id, user := range p.touched {
+			if u.LastSeen.Sub(user.LastSeen) >= touchInterval {
+				delete(p.touched, id)
+			}
+		}
+	}
+}
+
+func (p *Processor) forgetUser(userID int) {
+	p.touchedMu.Lock()
+	defer p.touchedMu.Unlock()
+
+	delete(p.touched, userID)
+}
+
+func broadcastKeyboard() telegram.InlineKeyboardMarkup {
+	button := func(text string, action string) telegram.InlineKeyboardButton {
+		data := broadcastCallbackPrefix + action
+		return telegram.InlineKeyboardButton{Text: text, CallbackData: &data}
+	}
+
+	return telegram.InlineKeyboardMarkup{
+		InlineKeyboard: [][]telegram.InlineKeyboardButton{{
+			button(btnBroadcastSend, broadcastSend),
+			button(btnBroadcastCancel, broadcastCancel),
+		}},
+	}
+}
+
+func broadcastWaitKey(userID int) string {
+	return fmt.Sprintf("broadcast_wait:%d", userID)
+}
+
+func broadcastDraftKey(userID int) string {
+	return fmt.Sprintf("broadcast_draft:%d", userID)
+}
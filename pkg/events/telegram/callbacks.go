@@ -0,0 +1,37 @@
+package telegram
+
+import (
+	"context"
+	"strings"
+	"telegrambot/internal/logging"
+)
+
+// doCallback handles callback query data and returns the text shown to the user.
+func (p *Processor) doCallback(ctx context.Context, data string, meta Meta) (string, error) {
+	logging.FromContext(ctx).Debug("got new callback", "data", data, "owner", meta.Owner())
+
+	if strings.HasPrefix(data, broadcastCallbackPrefix) {
+		return p.broadcastCallback(ctx, strings.TrimPrefix(data, broadcastCallbackPrefix), meta)
+	}
+
+	list, err := p.currentList(ctx, meta)
+	if err != nil {
+		return "", err
+	}
+
+	// all page actions change the list
+	if !list.role.CanEdit() {
+		return msgReadOnly, nil
+	}
+
+	switch {
+	case data == deleteCallback:
+		return p.deleteCallback(ctx, meta, list.owner)
+	case strings.HasPrefix(data, snoozeCallbackPrefix):
+		return p.snoozeCallback(ctx, strings.TrimPrefix(data, snoozeCallbackPrefix), meta, list.owner)
+	case strings.HasPrefix(data, reviewCallbackPrefix):
+		return p.reviewCallback(ctx, strings.TrimPrefix(data, reviewCallbackPrefix), meta, list.owner)
+	default:
+		return msgUnknownAction, nil
+	}
+}
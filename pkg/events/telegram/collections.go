@@ -0,0 +1,257 @@
+package telegram
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"telegrambot/internal/e"
+	"telegrambot/pkg/clients/telegram"
+	"telegrambot/pkg/repository"
+	"telegrambot/pkg/state"
+)
+
+// pageList is the list of pages commands operate on: either the personal
+// list of the user (or the group chat) or a shared collection.
+type pageList struct {
+	owner      string
+	role       repository.Role
+	collection *repository.Collection
+}
+
+func (l pageList) isShared() bool {
+	return l.collection != nil
+}
+
+// currentList returns the list selected with /use or /join.
+func (p *Processor) currentList(ctx context.Context, meta Meta) (pageList, error) {
+	personal := pageList{owner: meta.Owner(), role: repository.RoleOwner}
+
+	collectionID, err := p.cache.GetState(ctx, collectionKey(meta.Owner()))
+	if errors.Is(err, state.ErrNotFound) {
+		return personal, nil
+	}
+	if err != nil {
+		return pageList{}, e.Wrap("can't get current collection", err)
+	}
+
+	m, err := p.repository.Membership(ctx, collectionID, meta.Owner())
+	if errors.Is(err, repository.ErrNotMember) {
+		return personal, nil
+	}
+	if err != nil {
+		return pageList{}, e.Wrap("can't get current collection", err)
+	}
+
+	return pageList{owner: m.Collection.PagesOwner(), role: m.Role, collection: m.Collection}, nil
+}
+
+// share creates a collection with the name unless the user already owns one
+// and sends an invite code for the role.
+func (p *Processor) share(ctx context.Context, args string, meta Meta) (err error) {
+	defer func() {
+		err = e.WrapIfErr("can't do cmd share", err)
+	}()
+
+	msg := telegram.MessageConfig{
+		ChatID: meta.ChatID,
+	}
+
+	name, roleName, _ := strings.Cut(args, " ")
+	role := repository.RoleEditor
+	if roleName = strings.TrimSpace(roleName); roleName != "" {
+		role, err = repository.ParseRole(strings.ToLower(roleName))
+	}
+	if name == "" || err != nil || role == repository.RoleOwner {
+		msg.Text = msgShareUsage
+		return p.sendMessage(ctx, msg)
+	}
+
+	collection, err := p.ownCollection(ctx, name, meta.Owner())
+	if errors.Is(err, repository.ErrNotMember) {
+		msg.Text = msgNotCollectionOwner
+		return p.sendMessage(ctx, msg)
+	}
+	if err != nil {
+		return err
+	}
+
+	code, err := p.repository.CreateInvite(ctx, collection.ID, role)
+	if err != nil {
+		return err
+	}
+
+	msg.Text = fmt.Sprintf(msgInvite, collection.Name, role, JoinCmd, code)
+
+	return p.sendMessage(ctx, msg)
+}
+
+// ownCollection returns the user's collection with the name, creating it if
+// necessary. It returns ErrNotMember if the user is not the owner of the
+// collection with the name.
+func (p *Processor) ownCollection(ctx context.Context, name string, username string) (*repository.Collection, error) {
+	m, err := p.membershipByName(ctx, name, username)
+	switch {
+	case err == nil && m.Role != repository.RoleOwner:
+		return nil, repository.ErrNotMember
+	case err == nil:
+		return m.Collection, nil
+	case !errors.Is(err, repository.ErrNotMember):
+		return nil, err
+	}
+
+	id, err := repository.NewID()
+	if err != nil {
+		return nil, err
+	}
+
+	collection := &repository.Collection{ID: id, Name: name, Owner: username}
+	if err = p.repository.CreateCollection(ctx, collection); err != nil {
+		return nil, err
+	}
+
+	return collection, nil
+}
+
+func (p *Processor) join(ctx context.Context, code string, meta Meta) (err error) {
+	defer func() {
+		err = e.WrapIfErr("can't do cmd join", err)
+	}()
+
+	msg := telegram.MessageConfig{
+		ChatID: meta.ChatID,
+	}
+
+	m, err := p.repository.Join(ctx, code, meta.Owner())
+	if errors.Is(err, repository.ErrInviteNotFound) {
+		msg.Text = msgInviteNotFound
+		return p.sendMessage(ctx, msg)
+	}
+	if err != nil {
+		return err
+	}
+
+	if err = p.cache.SetState(ctx, collectionKey(meta.Owner()), m.Collection.ID); err != nil {
+		return err
+	}
+
+	msg.Text = fmt.Sprintf(msgJoined, m.Collection.Name, m.Role)
+
+	return p.sendMessage(ctx, msg)
+}
+
+// use selects the collection with the name or the personal list if the name is empty.
+func (p *Processor) use(ctx context.Context, name string, meta Meta) (err error) {
+	defer func() {
+		err = e.WrapIfErr("can't do cmd use", err)
+	}()
+
+	msg := telegram.MessageConfig{
+		ChatID: meta.ChatID,
+	}
+
+	if name == "" {
+		if err = p.cache.DeleteState(ctx, collectionKey(meta.Owner())); err != nil {
+			return err
+		}
+
+		msg.Text = msgUsePersonal
+		return p.sendMessage(ctx, msg)
+	}
+
+	m, err := p.membershipByName(ctx, name, meta.Owner())
+	if errors.Is(err, repository.ErrNotMember) {
+		msg.Text = msgCollectionNotFound
+		return p.sendMessage(ctx, msg)
+	}
+	if err != nil {
+		return err
+	}
+
+	if err = p.cache.SetState(ctx, collectionKey(meta.Owner()), m.Collection.ID); err != nil {
+		return err
+	}
+
+	msg.Text = fmt.Sprintf(msgUseCollection, m.Collection.Name, m.Role)
+
+	return p.sendMessage(ctx, msg)
+}
+
+func (p *Processor) sendCollections(ctx context.Context, meta Meta) (err error) {
+	defer func() {
+		err = e.WrapIfErr("can't do cmd collections", err)
+	}()
+
+	msg := telegram.MessageConfig{
+		ChatID: meta.ChatID,
+	}
+
+	memberships, err := p.repository.Memberships(ctx, meta.Owner())
+	if err != nil {
+		return err
+	}
+
+	if len(memberships) == 0 {
+		msg.Text = msgNoCollections
+		return p.sendMessage(ctx, msg)
+	}
+
+	list, err := p.currentList(ctx, meta)
+	if err != nil {
+		return err
+	}
+
+	var b strings.Builder
+	b.WriteString(msgCollectionsTitle)
+	for _, m := range memberships {
+		mark := ""
+		if list.isShared() && list.collection.ID == m.Collection.ID {
+			mark = " ✅"
+		}
+
+		fmt.Fprintf(&b, "%s (%s)%s\n", m.Collection.Name, m.Role, mark)
+	}
+
+	msg.Text = b.String()
+
+	return p.sendMessage(ctx, msg)
+}
+
+// membershipByName finds the user's collection by name. Collections owned by
+// the user take precedence over the ones the user has joined.
+func (p *Processor) membershipByName(ctx context.Context, name string, username string) (*repository.Membership, error) {
+	memberships, err := p.repository.Memberships(ctx, username)
+	if err != nil {
+		return nil, err
+	}
+
+	var res *repository.Membership
+	for _, m := range memberships {
+		if m.Collection.Name != name {
+			continue
+		}
+
+		if res == nil || m.Role == repository.RoleOwner {
+			res = m
+		}
+	}
+
+	if res == nil {
+		return nil, repository.ErrNotMember
+	}
+
+	return res, nil
+}
+
+func (p *Processor) sendReadOnly(ctx context.Context, chatID int) error {
+	msg := telegram.MessageConfig{
+		ChatID: chatID,
+		Text:   msgReadOnly,
+	}
+
+	return p.sendMessage(ctx, msg)
+}
+
+func collectionKey(username string) string {
+	return "collection:" + username
+}
@@ -3,58 +3,206 @@ package telegram
 import (
 	"context"
 	"errors"
-	"log"
 	"net/url"
 	"strings"
 	"telegrambot/internal/e"
+	"telegrambot/internal/logging"
 	"telegrambot/pkg/clients/telegram"
+	"telegrambot/pkg/events"
 	"telegrambot/pkg/repository"
+	"time"
 )
 
 const (
-	RndCmd   = "/rnd"
-	HelpCmd  = "/help"
-	StartCmd = "/start"
+	RndCmd    = "/rnd"
+	HelpCmd   = "/help"
+	StartCmd  = "/start"
+	SnoozeCmd = "/snooze"
+	ModeCmd   = "/mode"
+	ReviewCmd = "/review"
+	StatsCmd  = "/stats"
+	UndoCmd   = "/undo"
+	TrashCmd  = "/trash"
+
+	ShareCmd       = "/share"
+	JoinCmd        = "/join"
+	UseCmd         = "/use"
+	CollectionsCmd = "/collections"
 )
 
-func (p *Processor) doCmd(ctx context.Context, text string, chatID int, username string) error {
+func (p *Processor) doCmd(ctx context.Context, text string, meta Meta) error {
 	text = strings.TrimSpace(text)
+	chatID := meta.ChatID
+	username := meta.Owner()
+
+	// the text itself is not logged: it is the user's content
+	logging.FromContext(ctx).Debug("got new message", "command", commandName(text), "owner", username)
+
+	list, err := p.currentList(ctx, meta)
+	if err != nil {
+		return err
+	}
 
-	log.Printf("got new command '%s' from '%s'", text, username)
+	if p.isAdmin(meta) && !meta.IsGroup() {
+		captured, err := p.captureBroadcast(ctx, text, meta)
+		if err != nil || captured {
+			return err
+		}
+	}
 
 	if isAddCmd(text) {
-		return p.savePage(ctx, text, chatID, username)
+		if !list.role.CanEdit() {
+			return p.sendReadOnly(ctx, chatID)
+		}
+		return p.savePage(ctx, text, meta, list.owner)
+	}
+
+	cmd, args, _ := p.parseCmd(text)
+
+	if (cmd == SnoozeCmd || cmd == UndoCmd) && !list.role.CanEdit() {
+		return p.sendReadOnly(ctx, chatID)
 	}
 
-	switch text {
+	switch cmd {
 	case RndCmd:
-		return p.sendRandom(ctx, chatID, username)
+		return p.sendRandom(ctx, meta, list)
+	case SnoozeCmd:
+		return p.snoozePage(ctx, args, chatID, list.owner)
+	case ModeCmd:
+		return p.setMode(ctx, args, chatID, username)
+	case ReviewCmd:
+		return p.sendDue(ctx, chatID, list.owner)
+	case StatsCmd:
+		return p.sendStats(ctx, chatID, list.owner)
+	case UndoCmd:
+		return p.undo(ctx, chatID, list.owner)
+	case TrashCmd:
+		return p.sendTrash(ctx, chatID, list.owner)
+	case ShareCmd:
+		return p.share(ctx, args, meta)
+	case JoinCmd:
+		return p.join(ctx, args, meta)
+	case UseCmd:
+		return p.use(ctx, args, meta)
+	case CollectionsCmd:
+		return p.sendCollections(ctx, meta)
+	case BanCmd, UnbanCmd, UsersCmd:
+		return p.doAdminCmd(ctx, cmd, args, meta)
+	case BroadcastCmd:
+		return p.broadcast(ctx, args, meta)
 	case HelpCmd:
-		return p.sendHelp(chatID)
+		return p.sendHelp(ctx, chatID)
 	case StartCmd:
-		return p.sendHello(chatID)
+		return p.sendHello(ctx, chatID)
 	default:
+		if meta.IsGroup() && !strings.Contains(text, "@") {
+			return nil
+		}
+
 		msg := telegram.MessageConfig{
 			ChatID: chatID,
 			Text:   msgUnknownCommand,
 		}
-		return p.tg.SendMessage(msg)
+		return p.sendMessage(ctx, msg)
 	}
 
 }
 
-func (p *Processor) savePage(ctx context.Context, pageURL string, chatID int, username string) (err error) {
+// parseCmd splits text into the command and its arguments. The command is
+// addressed to the bot unless it has a suffix with another bot's username.
+// Text which is not a command is never addressed to the bot.
+func (p *Processor) parseCmd(text string) (cmd string, args string, addressed bool) {
+	cmd, args, _ = strings.Cut(text, " ")
+	args = strings.TrimSpace(args)
+
+	if !isCommand(cmd) {
+		return cmd, args, false
+	}
+
+	cmd, botUsername, found := strings.Cut(cmd, "@")
+	if found && !strings.EqualFold(botUsername, p.opts.BotUsername) {
+		return cmd, args, false
+	}
+
+	return cmd, args, true
+}
+
+// isAddressed reports whether the event is meant for the bot: any event in
+// private chats and channels, but only links and commands to the bot in
+// groups.
+func (p *Processor) isAddressed(event events.Event, meta Meta) bool {
+	if event.Type != events.Message || !meta.IsGroup() || meta.ChatType == telegram.ChatTypeChannel {
+		return true
+	}
+
+	text := strings.TrimSpace(event.Text)
+	if isAddCmd(text) {
+		return true
+	}
+
+	_, _, addressed := p.parseCmd(text)
+
+	return addressed
+}
+
+// collectPages silently saves all new links from a channel post.
+func (p *Processor) collectPages(ctx context.Context, text string, meta Meta) (err error) {
+	defer func() {
+		err = e.WrapIfErr("can't collect pages", err)
+	}()
+
+	for _, field := range strings.Fields(text) {
+		if !isURL(field) {
+			continue
+		}
+
+		page := &repository.Page{
+			URL:      field,
+			Username: meta.Owner(),
+			ChatID:   meta.ChatID,
+		}
+
+		isExists, err := p.repository.IsExists(ctx, page)
+		if err != nil {
+			return err
+		}
+		if isExists {
+			continue
+		}
+
+		rejection, err := p.checkQuota(ctx, meta, page.Username)
+		if err != nil {
+			return err
+		}
+		if rejection != "" {
+			logging.FromContext(ctx).Info("can't collect page", "owner", meta.Owner(), "reason", rejection)
+			return nil
+		}
+
+		if err = p.repository.Save(ctx, page); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (p *Processor) savePage(ctx context.Context, text string, meta Meta, username string) (err error) {
 	defer func() {
 		err = e.WrapIfErr("can't do cmd save page", err)
 	}()
 
 	msg := telegram.MessageConfig{
-		ChatID: chatID,
+		ChatID: meta.ChatID,
 	}
 
+	pageURL, tags := parseAddCmd(text)
+
 	page := &repository.Page{
 		URL:      pageURL,
 		Username: username,
+		Tags:     tags,
+		ChatID:   meta.ChatID,
 	}
 
 	isExists, err := p.repository.IsExists(ctx, page)
@@ -64,7 +212,16 @@ func (p *Processor) savePage(ctx context.Context, pageURL string, chatID int, us
 
 	if isExists {
 		msg.Text = msgAlreadyExists
-		return p.tg.SendMessage(msg)
+		return p.sendMessage(ctx, msg)
+	}
+
+	rejection, err := p.checkQuota(ctx, meta, username)
+	if err != nil {
+		return err
+	}
+	if rejection != "" {
+		msg.Text = rejection
+		return p.sendMessage(ctx, msg)
 	}
 
 	if err = p.repository.Save(ctx, page); err != nil {
@@ -72,60 +229,127 @@ func (p *Processor) savePage(ctx context.Context, pageURL string, chatID int, us
 	}
 
 	msg.Text = msgSaved
-	if err = p.tg.SendMessage(msg); err != nil {
+	if err = p.sendMessage(ctx, msg); err != nil {
 		return err
 	}
 
 	return nil
 }
 
-func (p *Processor) sendRandom(ctx context.Context, chatID int, username string) (err error) {
+func (p *Processor) sendRandom(ctx context.Context, meta Meta, list pageList) (err error) {
 	defer func() {
-		err = e.WrapIfErr("can't do cmd save page", err)
+		err = e.WrapIfErr("can't do cmd send random", err)
 	}()
 
 	msg := telegram.MessageConfig{
-		ChatID: chatID,
+		ChatID: meta.ChatID,
+	}
+
+	opts, err := p.pickOptions(ctx, meta.Owner(), list.owner)
+	if err != nil {
+		return err
 	}
 
-	page, err := p.repository.PickRandom(ctx, username)
+	page, err := p.repository.PickRandom(ctx, list.owner, opts)
 	if err != nil && !errors.Is(err, repository.ErrNoSavedPages) {
 		return err
 	}
 	if errors.Is(err, repository.ErrNoSavedPages) {
 		msg.Text = msgNoSavedPages
-		return p.tg.SendMessage(msg)
+		return p.sendMessage(ctx, msg)
+	}
+
+	if err = p.cache.SetState(ctx, lastPageKey(list.owner), page.URL); err != nil {
+		return err
 	}
 
 	msg.Text = page.URL
-	if err = p.tg.SendMessage(msg); err != nil {
+
+	// pages in review stay in the list until they are graded
+	if opts.Strategy == repository.StrategyReview {
+		msg.ReplyMarkup = reviewKeyboard()
+		return p.sendMessage(ctx, msg)
+	}
+
+	msg.ReplyMarkup = pageKeyboard()
+
+	if err = p.sendMessage(ctx, msg); err != nil {
 		return err
 	}
 
-	return p.repository.Remove(ctx, page)
+	// a shared collection stays intact for other members
+	if list.isShared() {
+		return nil
+	}
+
+	return p.repository.MarkRead(ctx, page, time.Now())
 }
 
-func (p *Processor) sendHelp(chatID int) error {
+func (p *Processor) sendHelp(ctx context.Context, chatID int) error {
 	msg := telegram.MessageConfig{
 		ChatID: chatID,
 		Text:   msgHelp,
 	}
-	return p.tg.SendMessage(msg)
+	return p.sendMessage(ctx, msg)
 }
 
-func (p *Processor) sendHello(chatID int) error {
+func (p *Processor) sendHello(ctx context.Context, chatID int) error {
 
 	msg := telegram.MessageConfig{
 		ChatID: chatID,
 		Text:   msgHello,
 	}
 
-	return p.tg.SendMessage(msg)
+	return p.sendMessage(ctx, msg)
 
 }
 
 func isAddCmd(text string) bool {
-	return isURL(text)
+	pageURL, _ := parseAddCmd(text)
+
+	return isURL(pageURL)
+}
+
+// parseAddCmd splits "<url> #tag1 #tag2" into the url and normalized tags.
+func parseAddCmd(text string) (pageURL string, tags []string) {
+	fields := strings.Fields(text)
+	if len(fields) == 0 {
+		return "", nil
+	}
+
+	for _, f := range fields[1:] {
+		tag, ok := parseTag(f)
+		if !ok {
+			return "", nil
+		}
+
+		tags = append(tags, tag)
+	}
+
+	return fields[0], tags
+}
+
+func parseTag(text string) (string, bool) {
+	tag := strings.ToLower(strings.TrimPrefix(text, "#"))
+	if !strings.HasPrefix(text, "#") || tag == "" {
+		return "", false
+	}
+
+	return tag, true
+}
+
+// commandName returns the command the text starts with, or an empty string.
+func commandName(text string) string {
+	cmd, _, _ := strings.Cut(text, " ")
+	if !isCommand(cmd) {
+		return ""
+	}
+
+	return cmd
+}
+
+func isCommand(text string) bool {
+	return strings.HasPrefix(text, "/")
 }
 
 func isURL(text string) bool {
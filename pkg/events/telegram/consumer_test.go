@@ -0,0 +1,248 @@
+package telegram
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"telegrambot/pkg/clients/telegram"
+	"telegrambot/pkg/clients/telegram/telegramtest"
+	eventConsumer "telegrambot/pkg/consumer/event-consumer"
+	"testing"
+	"time"
+)
+
+// waitTimeout is long enough for the consumer to sleep after an empty fetch.
+const waitTimeout = 5 * time.Second
+
+// running is the bot run by the consumer, as it is run in production.
+type running struct {
+	*bot
+	t *testing.T
+	// seen is the number of messages of the bot already checked.
+	seen int
+	// done gets what Start returned.
+	done chan error
+	stop context.CancelFunc
+}
+
+func (b *bot) run(t *testing.T) *running {
+	t.Helper()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	r := &running{bot: b, t: t, done: make(chan error, 1), stop: cancel}
+
+	go func() {
+		r.done <- eventConsumer.New(b.p, b.p, 100).Start(ctx)
+	}()
+	t.Cleanup(r.shutdown)
+
+	return r
+}
+
+// shutdown stops the consumer and waits until it returns.
+func (r *running) shutdown() {
+	r.t.Helper()
+
+	r.stop()
+
+	select {
+	case err, ok := <-r.done:
+		if ok && err != nil {
+			r.t.Errorf("Start: %v", err)
+		}
+		if ok {
+			close(r.done)
+		}
+	case <-time.After(waitTimeout):
+		r.t.Fatal("consumer didn't stop")
+	}
+}
+
+// ask sends the text to the bot in a private chat and returns the reply.
+func (r *running) ask(from telegram.User, text string) telegramtest.Message {
+	r.t.Helper()
+
+	r.srv.SendText(from, text)
+
+	return r.reply(text)
+}
+
+// press presses the button of the message and returns the answer.
+func (r *running) press(from telegram.User, m telegramtest.Message, data string) string {
+	r.t.Helper()
+
+	id := r.srv.Press(from, m, data)
+
+	answers, err := r.srv.WaitAnswers(len(r.srv.Answers())+1, waitTimeout)
+	if err != nil {
+		r.t.Fatalf("pressing %s: %v", data, err)
+	}
+
+	for _, a := range answers {
+		if a.CallbackQueryID == id {
+			return a.Text
+		}
+	}
+
+	r.t.Fatalf("query %s is not answered: %+v", id, answers)
+	return ""
+}
+
+func (r *running) reply(about string) telegramtest.Message {
+	r.t.Helper()
+
+	msgs, err := r.srv.WaitMessages(r.seen+1, waitTimeout)
+	if err != nil {
+		r.t.Fatalf("waiting for the reply to %q: %v", about, err)
+	}
+	r.seen++
+
+	return msgs[r.seen-1]
+}
+
+// quiet checks that the bot has sent nothing but the checked messages. The
+// consumer fetches again only after it has handled the fetched events, so
+// all of them are handled once the server has no updates left.
+func (r *running) quiet() {
+	r.t.Helper()
+
+	if err := r.srv.WaitIdle(waitTimeout); err != nil {
+		r.t.Fatal(err)
+	}
+
+	if msgs := r.srv.Messages(); len(msgs) != r.seen {
+		r.t.Errorf("bot sent %d unexpected messages: %+v", len(msgs)-r.seen, msgs[r.seen:])
+	}
+}
+
+func TestConsumerReadingList(t *testing.T) {
+	t.Parallel()
+
+	r := newBot(t, Options{}).run(t)
+	const url = "https://example.com/article"
+
+	if got := r.ask(alice, "/start"); got.Text != msgHello {
+		t.Errorf("/start = %q, want the greeting", got.Text)
+	}
+
+	if got := r.ask(alice, url+" #go"); got.Text != msgSaved {
+		t.Errorf("saving = %q, want %q", got.Text, msgSaved)
+	}
+
+	picked := r.ask(alice, "/rnd")
+	if picked.Text != url {
+		t.Fatalf("/rnd = %q, want %s", picked.Text, url)
+	}
+
+	if answer := r.press(alice, picked, deleteCallback); answer != msgDeleted {
+		t.Errorf("delete answer = %q, want %q", answer, msgDeleted)
+	}
+
+	if got := r.ask(alice, "/trash"); !strings.Contains(got.Text, url) {
+		t.Errorf("/trash = %q, want the deleted page", got.Text)
+	}
+
+	if got := r.ask(alice, "/undo"); got.Text != msgRestored+url {
+		t.Errorf("/undo = %q, want %q", got.Text, msgRestored+url)
+	}
+
+	if got := r.ask(alice, "/rnd"); got.Text != url {
+		t.Errorf("/rnd after /undo = %q, want %s", got.Text, url)
+	}
+
+	r.quiet()
+}
+
+func TestConsumerServesUsersConcurrently(t *testing.T) {
+	t.Parallel()
+
+	r := newBot(t, Options{}).run(t)
+	const users = 8
+
+	// all links arrive in one batch, which is handled by several workers
+	for i := 1; i <= users; i++ {
+		user := telegram.User{ID: i, Username: fmt.Sprintf("user%d", i)}
+		r.srv.SendText(user, fmt.Sprintf("https://example.com/%d", i))
+	}
+
+	for i := 1; i <= users; i++ {
+		r.reply("a link")
+	}
+
+	for i := 1; i <= users; i++ {
+		user := telegram.User{ID: i, Username: fmt.Sprintf("user%d", i)}
+		r.srv.SendText(user, "/rnd")
+	}
+
+	for i := 1; i <= users; i++ {
+		r.reply("/rnd")
+	}
+
+	for i := 1; i <= users; i++ {
+		msgs := r.srv.MessagesTo(i)
+		want := fmt.Sprintf("https://example.com/%d", i)
+
+		if len(msgs) != 2 || msgs[0].Text != msgSaved || msgs[1].Text != want {
+			t.Errorf("user %d got %+v, want their own link back", i, msgs)
+		}
+	}
+
+	r.quiet()
+}
+
+func TestConsumerGroupConversation(t *testing.T) {
+	t.Parallel()
+
+	r := newBot(t, Options{}).run(t)
+
+	r.srv.SendChatText(alice, group, "did you read it?")
+	r.srv.SendChatText(bob, group, "/rnd@another_bot")
+	r.quiet()
+
+	r.srv.SendChatText(alice, group, "https://example.com/shared")
+	if got := r.reply("a link in the group"); got.ChatID != group.ID || got.Text != msgSaved {
+		t.Errorf("saving in the group = %+v, want %q to the group", got, msgSaved)
+	}
+
+	r.srv.SendChatText(bob, group, "/rnd@"+r.srv.Bot.Username)
+	if got := r.reply("/rnd in the group"); got.ChatID != group.ID || got.Text != "https://example.com/shared" {
+		t.Errorf("/rnd in the group = %+v, want the link saved by alice", got)
+	}
+
+	r.quiet()
+}
+
+func TestConsumerGoesOnAfterFailedSend(t *testing.T) {
+	t.Parallel()
+
+	r := newBot(t, Options{}).run(t)
+
+	r.srv.Fail("sendMessage", telegram.Error{Code: 500, Description: "Internal Server Error"})
+	r.srv.SendText(alice, "/help")
+	r.quiet()
+
+	if got := r.ask(alice, "/help"); got.Text != msgHelp {
+		t.Errorf("/help after a failed send = %q, want the help", got.Text)
+	}
+
+	r.quiet()
+}
+
+func TestConsumerStop(t *testing.T) {
+	t.Parallel()
+
+	r := newBot(t, Options{}).run(t)
+
+	r.ask(alice, "/help")
+	r.shutdown()
+
+	// nothing fetches updates after the consumer has returned
+	r.srv.SendText(alice, "/help")
+
+	if pending := r.srv.Pending(); pending == 0 {
+		t.Error("update sent after stopping is fetched")
+	}
+	if msgs := r.srv.Messages(); len(msgs) != 1 {
+		t.Errorf("bot sent %d messages, want one", len(msgs))
+	}
+}
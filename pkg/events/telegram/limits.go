@@ -0,0 +1,84 @@
+package telegram
+
+import (
+	"context"
+	"fmt"
+	"telegrambot/internal/e"
+	"telegrambot/internal/logging"
+	"telegrambot/pkg/clients/telegram"
+	"telegrambot/pkg/events"
+	"time"
+)
+
+// throttleNoticeInterval is how often a throttled user is told about it.
+const throttleNoticeInterval = 10 * time.Second
+
+// Limits protect the bot from users who send too much. Zero means unlimited.
+type Limits struct {
+	// MaxPages is the maximum number of pages in a list.
+	MaxPages          int
+	SavesPerMinute    int
+	CommandsPerSecond int
+}
+
+// throttle reports whether the user may send one more event now. Throttled
+// users are told so at most once per throttleNoticeInterval, but never in
+// group chats, where the notice would bother everyone.
+func (p *Processor) throttle(ctx context.Context, event events.Event, meta Meta) (bool, error) {
+	now := time.Now()
+
+	ok, err := p.limiter.Allow(ctx, "commands:"+meta.userKey(), p.opts.Limits.CommandsPerSecond, time.Second, now)
+	if err != nil || ok {
+		return ok, err
+	}
+
+	logging.FromContext(ctx).Info("throttled event", "key", meta.userKey())
+
+	// an answer to a button is seen only by the user who pressed it
+	if event.Type != events.CallbackQuery && meta.IsGroup() {
+		return false, nil
+	}
+
+	notify, err := p.limiter.Allow(ctx, "throttled:"+meta.userKey(), 1, throttleNoticeInterval, now)
+	if err != nil || !notify {
+		return false, err
+	}
+
+	if event.Type == events.CallbackQuery {
+		text := msgTooManyRequests
+		return false, p.tg.AnswerCallbackQuery(ctx, telegram.CallbackQueryConfig{
+			CallbackQueryId: meta.CallbackQueryId,
+			Text:            &text,
+		})
+	}
+
+	return false, p.sendMessage(ctx, telegram.MessageConfig{
+		ChatID: meta.ChatID,
+		Text:   msgTooManyRequests,
+	})
+}
+
+// checkQuota returns a message for the user if saving one more page to the
+// list of the owner exceeds the limits, or an empty string otherwise.
+func (p *Processor) checkQuota(ctx context.Context, meta Meta, owner string) (string, error) {
+	if p.opts.Limits.MaxPages > 0 {
+		count, err := p.repository.Count(ctx, owner)
+		if err != nil {
+			return "", e.Wrap("can't check quota", err)
+		}
+
+		if count >= p.opts.Limits.MaxPages {
+			return fmt.Sprintf(msgTooManyPages, p.opts.Limits.MaxPages), nil
+		}
+	}
+
+	ok, err := p.limiter.Allow(ctx, "saves:"+meta.userKey(), p.opts.Limits.SavesPerMinute, time.Minute, time.Now())
+	if err != nil {
+		return "", e.Wrap("can't check quota", err)
+	}
+	if !ok {
+		return msgTooManySaves, nil
+	}
+
+	return "", nil
+}
@@ -2,16 +2,124 @@ package telegram
 
 const msgHelp = `Я бот для хранения ссылок. Могу сохранять ваши ссылки, а так же предлагать их для чтения
 
-Чтобы сохранить сообщение, отправьте мне его ссылку
+Чтобы сохранить сообщение, отправьте мне его ссылку. Можно добавить теги: https://example.com #go #книги
 
 Чтобы получить рандомную ссылку из вашего списка, отправьте мне команду /rnd.
-Предупреждение! После получения ссылки, эта ссылка будет удалена из вашего списка!`
+Полученная ссылка отмечается прочитанной: она больше не попадется в /rnd, но останется в /stats. Кнопка «Удалить» под ссылкой перемещает ее в корзину.
+
+Чтобы отложить ссылку, отправьте /snooze <ссылка> <срок>, например /snooze https://example.com 3d.
+Срок можно указать в часах (h), днях (d), неделях (w) или датой 2006-01-02. Отложенная ссылка не попадется в /rnd, пока я сам не напомню о ней.
+
+Чтобы выбрать, как /rnd подбирает ссылки, отправьте /mode <режим> [#тег]:
+random - случайная ссылка
+oldest - сначала старые
+newest - сначала новые
+weighted - чем старше ссылка, тем чаще она попадается
+norepeat - никогда не повторять ссылку дважды подряд
+review - режим повторения: ссылки не удаляются, а возвращаются по расписанию в зависимости от вашей оценки
+#тег - только ссылки с этим тегом
+
+Чтобы получить следующую ссылку для повторения, отправьте /review.
+
+Чтобы посмотреть статистику, отправьте /stats.
+
+Удаленные ссылки попадают в корзину: /trash покажет ее содержимое. Если ссылка прочитана или удалена по ошибке, отправьте /undo, и я верну ее в список. Из корзины ссылки со временем удаляются навсегда.
+
+Чтобы вести общий список с друзьями, создайте коллекцию: /share <название> [editor|viewer] пришлет код приглашения, а /join <код> добавит в коллекцию. /use <название> переключает на коллекцию, /use без названия — обратно на ваш список, /collections покажет все ваши коллекции.
+
+В групповом чате у всех участников общий список ссылок. Если добавить меня в администраторы канала, я буду собирать ссылки из его постов.`
 
 const msgHello = "Привет! \n\n" + msgHelp
 
 const (
-	msgUnknownCommand = "Неизвестная команда🤔"
-	msgNoSavedPages   = "У вас нет сохраненных ссылок🙈"
-	msgSaved          = "Ссылка сохранена!👌"
-	msgAlreadyExists  = "Эта ссылка уже есть в вашем списке🤗"
+	msgUnknownCommand  = "Неизвестная команда🤔"
+	msgUnknownAction   = "Неизвестное действие🤔"
+	msgNoSavedPages    = "У вас нет сохраненных ссылок🙈"
+	msgSaved           = "Ссылка сохранена!👌"
+	msgAlreadyExists   = "Эта ссылка уже есть в вашем списке🤗"
+	msgSnoozed         = "Напомню о ссылке %s⏰"
+	msgSnoozeUsage     = "Используйте: /snooze <ссылка> <срок>, например /snooze https://example.com 3d"
+	msgResurfaced      = "Вы просили напомнить об этой ссылке🔔\n"
+	msgCurrentMode     = "Текущий режим: %s"
+	msgModeChanged     = "Режим изменен на %s👌"
+	msgModeUsage       = "Используйте: /mode <random|oldest|newest|weighted|norepeat|review> [#тег]"
+	msgNothingToReview = "Нет ссылок для повторения🎉"
+	msgPageNotFound    = "Этой ссылки уже нет в вашем списке"
+	msgReviewScheduled = "Следующее повторение %s📅"
+	msgDeleted         = "Ссылка перемещена в корзину🗑"
+	msgNothingToUndo   = "Нечего отменять🤷"
+	msgRestored        = "Ссылка возвращена в ваш список↩️\n"
+	msgTrashEmpty      = "Корзина пуста"
+	msgTrashTitle      = "🗑 Корзина:\n\n"
+	msgTrashMore       = "...и еще %d\n"
+)
+
+const (
+	msgReadOnly           = "У вас есть права только на чтение этой коллекции👀"
+	msgShareUsage         = "Используйте: /share <название> [editor|viewer]"
+	msgNotCollectionOwner = "Делиться коллекцией может только ее владелец"
+	msgInvite             = "Коллекция «%s», роль %s. Чтобы присоединиться, отправьте мне:\n%s %s"
+	msgInviteNotFound     = "Приглашение не найдено🤔"
+	msgJoined             = "Вы присоединились к коллекции «%s» с ролью %s. Теперь команды работают с ней, вернуться к своему списку: /use"
+	msgUsePersonal        = "Теперь команды работают с вашим списком👌"
+	msgUseCollection      = "Теперь команды работают с коллекцией «%s» (%s)👌"
+	msgCollectionNotFound = "У вас нет такой коллекции🤔"
+	msgNoCollections      = "У вас нет коллекций. Создайте ее командой /share <название>"
+	msgCollectionsTitle   = "Ваши коллекции:\n\n"
+)
+
+const (
+	msgForbidden    = "Извините, у вас нет доступа к этому боту🔒"
+	msgBanUsage     = "Используйте: %s <id пользователя>"
+	msgBanned       = "Пользователь %d заблокирован"
+	msgUnbanned     = "Пользователь %d разблокирован"
+	msgUsersAdmins  = "Администраторы:"
+	msgUsersAllowed = "Разрешенные:"
+	msgUsersDenied  = "Запрещенные:"
+	msgUsersBanned  = "Заблокированные:"
+	msgUsersKnown   = "Пользователей: %d, заблокировали бота: %d\n"
+)
+
+const (
+	msgBroadcastPrivate     = "Рассылку можно запустить только в личном чате со мной"
+	msgBroadcastAsk         = "Отправьте текст рассылки. Отменить: /broadcast cancel"
+	msgBroadcastPreview     = "Сообщение получат %d пользователей:\n\n%s"
+	msgBroadcastNoDraft     = "Рассылка уже отправлена или отменена"
+	msgBroadcastCanceled    = "Рассылка отменена"
+	msgBroadcastRunning     = "Предыдущая рассылка еще не закончилась. Прогресс: /broadcast status"
+	msgBroadcastStarted     = "Рассылка на %d пользователей начата"
+	msgBroadcastNone        = "Рассылок еще не было"
+	msgBroadcastProgress    = "Рассылка идет: отправлено %d из %d\n"
+	msgBroadcastDone        = "Рассылка завершена\n"
+	msgBroadcastInterrupted = "Рассылка прервана остановкой бота: отправлено %d из %d\n"
+	msgBroadcastCounts      = "Доставлено: %d\nЗаблокировали бота: %d\nОшибки: %d\nВремя: %s"
+)
+
+const (
+	msgTooManyRequests = "Слишком много запросов, подождите немного🐢"
+	msgTooManyPages    = "В списке уже %d ссылок, это максимум. Прочитайте или удалите что-нибудь📚"
+	msgTooManySaves    = "Вы сохраняете ссылки слишком часто, попробуйте через минуту⏳"
+)
+
+const (
+	msgStatsTitle        = "<b>📊 Ваша статистика</b>\n\n"
+	msgStatsTotal        = "Всего сохранено: <b>%d</b>\n"
+	msgStatsUnread       = "Не прочитано: <b>%d</b>\n"
+	msgStatsReadThisWeek = "Прочитано за неделю: <b>%d</b>\n"
+	msgStatsAvgAge       = "Средний возраст непрочитанных: <b>%s</b>\n"
+	msgStatsTopDomains   = "\n<b>Популярные сайты</b>\n"
+	msgStatsTopTags      = "\n<b>Популярные теги</b>\n"
+)
+
+const (
+	btnSnoozeDay   = "⏰ День"
+	btnSnoozeWeek  = "⏰ Неделя"
+	btnSnoozeMonth = "⏰ Месяц"
+	btnReviewAgain = "🔁 Снова"
+	btnReviewGood  = "👍 Хорошо"
+	btnReviewEasy  = "🚀 Легко"
+	btnDelete      = "🗑 Удалить"
+
+	btnBroadcastSend   = "📣 Отправить"
+	btnBroadcastCancel = "✖️ Отмена"
 )
@@ -0,0 +1,112 @@
+package telegram
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"telegrambot/internal/e"
+	"telegrambot/pkg/clients/telegram"
+	"telegrambot/pkg/repository"
+	"telegrambot/pkg/state"
+)
+
+func (p *Processor) setMode(ctx context.Context, args string, chatID int, username string) (err error) {
+	defer func() {
+		err = e.WrapIfErr("can't do cmd mode", err)
+	}()
+
+	msg := telegram.MessageConfig{
+		ChatID: chatID,
+	}
+
+	if args == "" {
+		opts, err := p.pickOptions(ctx, username, username)
+		if err != nil {
+			return err
+		}
+
+		msg.Text = fmt.Sprintf(msgCurrentMode, formatMode(opts))
+		return p.sendMessage(ctx, msg)
+	}
+
+	opts, err := parseMode(args)
+	if err != nil {
+		msg.Text = msgModeUsage
+		return p.sendMessage(ctx, msg)
+	}
+
+	if err = p.cache.SetState(ctx, modeKey(username), formatMode(opts)); err != nil {
+		return err
+	}
+
+	msg.Text = fmt.Sprintf(msgModeChanged, formatMode(opts))
+
+	return p.sendMessage(ctx, msg)
+}
+
+// pickOptions returns pick options for the mode chosen by the user. The
+// mode applies to every list the user picks from, pages are not repeated
+// within the list of listOwner.
+func (p *Processor) pickOptions(ctx context.Context, username string, listOwner string) (repository.PickOptions, error) {
+	mode, err := p.cache.GetState(ctx, modeKey(username))
+	if errors.Is(err, state.ErrNotFound) {
+		return repository.PickOptions{Strategy: repository.StrategyRandom}, nil
+	}
+	if err != nil {
+		return repository.PickOptions{}, e.Wrap("can't get mode", err)
+	}
+
+	opts, err := parseMode(mode)
+	if err != nil {
+		return repository.PickOptions{}, e.Wrap("can't parse mode", err)
+	}
+
+	if opts.Strategy == repository.StrategyNoRepeat {
+		lastURL, err := p.cache.GetState(ctx, lastPageKey(listOwner))
+		if err != nil && !errors.Is(err, state.ErrNotFound) {
+			return repository.PickOptions{}, e.Wrap("can't get last page", err)
+		}
+
+		opts.LastURL = lastURL
+	}
+
+	return opts, nil
+}
+
+// parseMode parses "<strategy> [#tag]" or "#tag".
+func parseMode(text string) (repository.PickOptions, error) {
+	opts := repository.PickOptions{Strategy: repository.StrategyRandom}
+
+	for _, f := range strings.Fields(text) {
+		if tag, ok := parseTag(f); ok {
+			opts.Tag = tag
+			continue
+		}
+
+		strategy, err := repository.ParseStrategy(strings.ToLower(f))
+		if err != nil {
+			return repository.PickOptions{}, err
+		}
+
+		opts.Strategy = strategy
+	}
+
+	return opts, nil
+}
+
+func formatMode(opts repository.PickOptions) string {
+	if opts.Tag == "" {
+		return string(opts.Strategy)
+	}
+
+	return fmt.Sprintf("%s #%s", opts.Strategy, opts.Tag)
+}
+
+func modeKey(username string) string {
+	return "mode:" + username
+}
+
+func lastPageKey(owner string) string {
+	return "last_page:" + owner
+}
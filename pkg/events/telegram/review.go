@@ -0,0 +1,86 @@
+package telegram
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"telegrambot/internal/e"
+	"telegrambot/pkg/clients/telegram"
+	"telegrambot/pkg/repository"
+	"time"
+)
+
+const reviewCallbackPrefix = "review:"
+
+func (p *Processor) sendDue(ctx context.Context, chatID int, username string) (err error) {
+	defer func() {
+		err = e.WrapIfErr("can't do cmd review", err)
+	}()
+
+	msg := telegram.MessageConfig{
+		ChatID: chatID,
+	}
+
+	page, err := p.repository.PickDue(ctx, username, time.Now())
+	if errors.Is(err, repository.ErrNoSavedPages) {
+		msg.Text = msgNothingToReview
+		return p.sendMessage(ctx, msg)
+	}
+	if err != nil {
+		return err
+	}
+
+	msg.Text = page.URL
+	msg.ReplyMarkup = reviewKeyboard()
+
+	return p.sendMessage(ctx, msg)
+}
+
+// reviewCallback schedules the next review of the page sent in the message
+// the button is attached to.
+func (p *Processor) reviewCallback(ctx context.Context, gradeName string, meta Meta, owner string) (answer string, err error) {
+	defer func() {
+		err = e.WrapIfErr("can't review page from callback", err)
+	}()
+
+	grade, err := repository.ParseGrade(gradeName)
+	if errors.Is(err, repository.ErrUnknownGrade) {
+		return msgUnknownAction, nil
+	}
+
+	pageURL := urlFromText(meta.MessageText)
+	if pageURL == "" {
+		return msgUnknownAction, nil
+	}
+
+	page, err := p.repository.Get(ctx, owner, pageURL)
+	if errors.Is(err, repository.ErrPageNotFound) {
+		return msgPageNotFound, nil
+	}
+	if err != nil {
+		return "", err
+	}
+
+	page.Review = page.Review.Next(grade, time.Now())
+
+	if err = p.repository.UpdateReview(ctx, page); err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf(msgReviewScheduled, page.Review.DueAt.Format(resurfaceTimeLayout)), nil
+}
+
+func reviewKeyboard() telegram.InlineKeyboardMarkup {
+	button := func(text string, grade string) telegram.InlineKeyboardButton {
+		data := reviewCallbackPrefix + grade
+		return telegram.InlineKeyboardButton{Text: text, CallbackData: &data}
+	}
+
+	return telegram.InlineKeyboardMarkup{
+		InlineKeyboard: [][]telegram.InlineKeyboardButton{{
+			button(btnReviewAgain, "again"),
+			button(btnReviewGood, "good"),
+			button(btnReviewEasy, "easy"),
+		}},
+	}
+}
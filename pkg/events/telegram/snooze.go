@@ -0,0 +1,189 @@
+package telegram
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"telegrambot/internal/e"
+	"telegrambot/internal/logging"
+	"telegrambot/pkg/clients/telegram"
+	"telegrambot/pkg/repository"
+	"time"
+)
+
+const (
+	snoozeCallbackPrefix = "snooze:"
+	defaultSnoozePeriod  = "1w"
+	snoozeDateLayout     = "2006-01-02"
+	resurfaceTimeLayout  = "02.01.2006 15:04"
+	// resurfaceRetry is how long a page which couldn't be delivered waits
+	// before the next attempt.
+	resurfaceRetry = 10 * time.Minute
+)
+
+var ErrInvalidSnoozePeriod = errors.New("invalid snooze period")
+
+func (p *Processor) snoozePage(ctx context.Context, args string, chatID int, username string) (err error) {
+	defer func() {
+		err = e.WrapIfErr("can't do cmd snooze", err)
+	}()
+
+	msg := telegram.MessageConfig{
+		ChatID: chatID,
+	}
+
+	pageURL, period, _ := strings.Cut(args, " ")
+	if !isURL(pageURL) {
+		msg.Text = msgSnoozeUsage
+		return p.sendMessage(ctx, msg)
+	}
+
+	until, err := parseSnoozePeriod(strings.TrimSpace(period), time.Now())
+	if err != nil {
+		msg.Text = msgSnoozeUsage
+		return p.sendMessage(ctx, msg)
+	}
+
+	page := &repository.Page{
+		URL:      pageURL,
+		Username: username,
+		ChatID:   chatID,
+	}
+
+	if err = p.repository.Snooze(ctx, page, until); err != nil {
+		return err
+	}
+
+	msg.Text = fmt.Sprintf(msgSnoozed, until.Format(resurfaceTimeLayout))
+
+	return p.sendMessage(ctx, msg)
+}
+
+// snoozeCallback snoozes the page sent in the message the button is attached to.
+func (p *Processor) snoozeCallback(ctx context.Context, period string, meta Meta, owner string) (answer string, err error) {
+	defer func() {
+		err = e.WrapIfErr("can't snooze page from callback", err)
+	}()
+
+	pageURL := urlFromText(meta.MessageText)
+	if pageURL == "" {
+		return msgUnknownAction, nil
+	}
+
+	until, err := parseSnoozePeriod(period, time.Now())
+	if err != nil {
+		return msgUnknownAction, nil
+	}
+
+	page := &repository.Page{
+		URL:      pageURL,
+		Username: owner,
+		ChatID:   meta.ChatID,
+	}
+
+	if err = p.repository.Snooze(ctx, page, until); err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf(msgSnoozed, until.Format(resurfaceTimeLayout)), nil
+}
+
+// Resurface delivers snoozed pages which are due back to their chats. A page
+// stays snoozed until it is delivered, failed ones are retried later.
+func (p *Processor) Resurface(ctx context.Context) error {
+	now := time.Now()
+
+	pages, err := p.repository.DueSnoozed(ctx, now, now.Add(resurfaceRetry))
+	if err != nil {
+		return e.Wrap("can't resurface pages", err)
+	}
+
+	for _, page := range pages {
+		if err := p.resurfacePage(ctx, page); err != nil {
+			logging.FromContext(ctx).Warn("can't resurface page",
+				"owner", page.Username, "chat_id", page.ChatID, "retry_in", resurfaceRetry, logging.Err(err))
+		}
+	}
+
+	return nil
+}
+
+func (p *Processor) resurfacePage(ctx context.Context, page *repository.Page) error {
+	msg := telegram.MessageConfig{
+		ChatID:      page.ChatID,
+		Text:        msgResurfaced + page.URL,
+		ReplyMarkup: pageKeyboard(),
+	}
+
+	if err := p.sendMessage(ctx, msg); err != nil {
+		return err
+	}
+
+	return p.repository.Unsnooze(ctx, page)
+}
+
+// pageKeyboard is attached to pages sent to the user.
+func pageKeyboard() telegram.InlineKeyboardMarkup {
+	button := func(text string, data string) telegram.InlineKeyboardButton {
+		return telegram.InlineKeyboardButton{Text: text, CallbackData: &data}
+	}
+
+	return telegram.InlineKeyboardMarkup{
+		InlineKeyboard: [][]telegram.InlineKeyboardButton{
+			{
+				button(btnSnoozeDay, snoozeCallbackPrefix+"1d"),
+				button(btnSnoozeWeek, snoozeCallbackPrefix+"1w"),
+				button(btnSnoozeMonth, snoozeCallbackPrefix+"30d"),
+			},
+			{
+				button(btnDelete, deleteCallback),
+			},
+		},
+	}
+}
+
+// parseSnoozePeriod parses either a date (2006-01-02) or a period
+// like 3h, 2d or 1w relative to now.
+func parseSnoozePeriod(period string, now time.Time) (time.Time, error) {
+	if period == "" {
+		period = defaultSnoozePeriod
+	}
+
+	if t, err := time.ParseInLocation(snoozeDateLayout, period, now.Location()); err == nil {
+		if !t.After(now) {
+			return time.Time{}, ErrInvalidSnoozePeriod
+		}
+		return t, nil
+	}
+
+	units := map[byte]time.Duration{
+		'h': time.Hour,
+		'd': 24 * time.Hour,
+		'w': 7 * 24 * time.Hour,
+	}
+
+	unit, ok := units[period[len(period)-1]]
+	if !ok {
+		return time.Time{}, ErrInvalidSnoozePeriod
+	}
+
+	n, err := strconv.Atoi(period[:len(period)-1])
+	if err != nil || n <= 0 {
+		return time.Time{}, ErrInvalidSnoozePeriod
+	}
+
+	return now.Add(time.Duration(n) * unit), nil
+}
+
+// urlFromText returns the first URL found in text.
+func urlFromText(text string) string {
+	for _, field := range strings.Fields(text) {
+		if isURL(field) {
+			return field
+		}
+	}
+
+	return ""
+}
@@ -0,0 +1,66 @@
+package telegram
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"strings"
+	"telegrambot/internal/e"
+	"telegrambot/pkg/clients/telegram"
+	"telegrambot/pkg/repository"
+	"time"
+)
+
+func (p *Processor) sendStats(ctx context.Context, chatID int, username string) (err error) {
+	defer func() {
+		err = e.WrapIfErr("can't do cmd stats", err)
+	}()
+
+	stats, err := p.repository.Stats(ctx, username, time.Now())
+	if err != nil {
+		return err
+	}
+
+	msg := telegram.MessageConfig{
+		ChatID:    chatID,
+		Text:      formatStats(stats),
+		ParseMode: telegram.ParseModeHTML,
+	}
+
+	return p.sendMessage(ctx, msg)
+}
+
+func formatStats(stats *repository.Stats) string {
+	var b strings.Builder
+
+	b.WriteString(msgStatsTitle)
+	fmt.Fprintf(&b, msgStatsTotal, stats.Total)
+	fmt.Fprintf(&b, msgStatsUnread, stats.Unread)
+	fmt.Fprintf(&b, msgStatsReadThisWeek, stats.ReadThisWeek)
+	fmt.Fprintf(&b, msgStatsAvgAge, formatAge(stats.AvgUnreadAge))
+
+	writeCounts(&b, msgStatsTopDomains, stats.TopDomains, "")
+	writeCounts(&b, msgStatsTopTags, stats.TopTags, "#")
+
+	return b.String()
+}
+
+func writeCounts(b *strings.Builder, title string, counts []repository.Count, prefix string) {
+	if len(counts) == 0 {
+		return
+	}
+
+	b.WriteString(title)
+	for i, c := range counts {
+		fmt.Fprintf(b, "%d. %s%s — %d\n", i+1, prefix, html.EscapeString(c.Name), c.Count)
+	}
+}
+
+func formatAge(age time.Duration) string {
+	days := int(age / (24 * time.Hour))
+	if days > 0 {
+		return fmt.Sprintf("%d дн.", days)
+	}
+
+	return fmt.Sprintf("%d ч.", int(age/time.Hour))
+}
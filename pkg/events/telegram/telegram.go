@@ -4,11 +4,22 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"sync"
+	"sync/atomic"
 	"telegrambot/internal/e"
+	"telegrambot/internal/logging"
+	"telegrambot/pkg/access"
 	"telegrambot/pkg/clients/telegram"
+	"telegrambot/pkg/deadletter"
 	"telegrambot/pkg/events"
+	"telegrambot/pkg/ratelimit"
+	"telegrambot/pkg/recorder"
 	"telegrambot/pkg/repository"
 	"telegrambot/pkg/state"
+	"telegrambot/pkg/tracing"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
 )
 
 var (
@@ -21,24 +32,154 @@ type Processor struct {
 	offset     int
 	repository repository.Repository
 	cache      state.Cache
+	limiter    *ratelimit.Limiter
+	opts       Options
+
+	broadcastMu   sync.Mutex
+	lastBroadcast *broadcastProgress
+
+	// background is canceled by Shutdown to stop work which outlives the
+	// event it was started by, such as a broadcast.
+	background     context.Context
+	stopBackground context.CancelFunc
+	backgroundWG   sync.WaitGroup
+
+	touchedMu sync.Mutex
+	// touched is what was last saved about users who have written recently.
+	touched        map[int]repository.User
+	lastTouchSweep time.Time
+
+	// lastFetch is the unix time in nanoseconds of the last successful Fetch.
+	lastFetch atomic.Int64
+}
+
+type Options struct {
+	// UndoWindow is how long after removing a page it can be restored with /undo.
+	UndoWindow time.Duration
+	// BotUsername is used to recognize commands like /rnd@bot in group chats.
+	BotUsername string
+	// Access restricts who may use the bot. Everyone may if it is nil.
+	Access *access.Policy
+	Limits Limits
+	// BroadcastRate is how many messages per second a broadcast sends,
+	// DefaultBroadcastRate if it is not positive.
+	BroadcastRate int
+	// Recorder keeps fetched updates for replaying. Nothing is recorded if
+	// it is nil.
+	Recorder *recorder.Recorder
+	// DeadLetters keeps updates which failed to be processed. They are only
+	// logged if it is nil.
+	DeadLetters *deadletter.Queue
 }
 
 type Meta struct {
+	UpdateID        int    `json:"update_id"`
 	ChatID          int    `json:"chat_id"`
+	ChatType        string `json:"chat_type"`
+	ChatTitle       string `json:"chat_title"`
+	UserID          int    `json:"user_id"`
 	Username        string `json:"username"`
+	FirstName       string `json:"first_name"`
 	CallbackQueryId string `json:"callback_query_id"`
+	MessageText     string `json:"message_text"`
+
+	// update is what the event was made of, kept for the dead letter queue.
+	update telegram.Update
+}
+
+// userKey identifies the sender for rate limiting. Channel posts have no
+// sender, so the channel is used instead.
+func (m Meta) userKey() string {
+	if m.UserID == 0 {
+		return m.Owner()
+	}
+
+	return fmt.Sprintf("user-%d", m.UserID)
 }
 
-func New(client *telegram.Client, repository repository.Repository, cache state.Cache) *Processor {
-	return &Processor{tg: client, repository: repository, cache: cache}
+// IsGroup reports whether the event comes from a chat shared by several users.
+func (m Meta) IsGroup() bool {
+	return m.ChatType == telegram.ChatTypeGroup ||
+		m.ChatType == telegram.ChatTypeSupergroup ||
+		m.ChatType == telegram.ChatTypeChannel
 }
 
-func (p *Processor) Fetch(limit int) ([]events.Event, error) {
-	updates, err := p.tg.Updates(p.offset, limit)
+// Owner returns the key pages are stored under: in group chats and channels
+// the list is shared by the chat, otherwise it belongs to the user. Both
+// chat and user keys contain '-', which is not allowed in Telegram usernames.
+func (m Meta) Owner() string {
+	switch {
+	case m.IsGroup():
+		return fmt.Sprintf("chat%d", m.ChatID)
+	case m.Username != "":
+		return m.Username
+	default:
+		return fmt.Sprintf("user-%d", m.UserID)
+	}
+}
+
+func New(client *telegram.Client, repository repository.Repository, cache state.Cache, opts Options) *Processor {
+	p := &Processor{
+		tg:         client,
+		repository: repository,
+		cache:      cache,
+		limiter:    ratelimit.New(cache),
+		opts:       opts,
+	}
+	p.background, p.stopBackground = context.WithCancel(context.Background())
+
+	// give the first Fetch a chance before the processor is considered stuck
+	p.lastFetch.Store(time.Now().UnixNano())
+
+	return p
+}
+
+// LastFetch returns when updates were last fetched successfully.
+func (p *Processor) LastFetch() time.Time {
+	return time.Unix(0, p.lastFetch.Load())
+}
+
+// Shutdown stops work started by events which is still running, such as a
+// broadcast, and waits until it is finished or ctx is done.
+func (p *Processor) Shutdown(ctx context.Context) error {
+	// no work may start once Wait is called
+	p.broadcastMu.Lock()
+	p.stopBackground()
+	p.broadcastMu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		p.backgroundWG.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return e.Wrap("can't wait for background work", ctx.Err())
+	}
+}
+
+func (p *Processor) Fetch(ctx context.Context, limit int) (_ []events.Event, err error) {
+	ctx, span := tracing.Start(ctx, "telegram.Fetch", attribute.Int("telegram.offset", p.offset))
+	defer func() { tracing.End(span, err) }()
+
+	updates, err := p.tg.Updates(ctx, p.offset, limit)
 	if err != nil {
 		return nil, e.Wrap("can't get events", err)
 	}
 
+	p.lastFetch.Store(time.Now().UnixNano())
+	span.SetAttributes(attribute.Int("telegram.updates", len(updates)))
+
+	if p.opts.Recorder != nil && len(updates) > 0 {
+		// a broken recording must not stop the bot
+		if err := p.opts.Recorder.Record(updates); err != nil {
+			logging.FromContext(ctx).Error("can't record updates", logging.Err(err))
+		}
+	}
+
 	if len(updates) == 0 {
 		return nil, nil
 	}
@@ -54,19 +195,66 @@ func (p *Processor) Fetch(limit int) ([]events.Event, error) {
 	return res, nil
 }
 
-func (p *Processor) Process(ctx context.Context, event events.Event) error {
+// ProcessUpdate processes an update which was fetched before, such as one
+// kept in the dead letter queue.
+func (p *Processor) ProcessUpdate(ctx context.Context, u telegram.Update) error {
+	return p.Process(ctx, event(u))
+}
+
+func (p *Processor) Process(ctx context.Context, event events.Event) (err error) {
 	metaInfo, err := meta(event)
 	if err != nil {
 		return e.Wrap("can't process message", err)
 	}
+
+	if p.opts.DeadLetters != nil {
+		defer func() {
+			if err == nil {
+				return
+			}
+			if dErr := p.opts.DeadLetters.Add(metaInfo.update, err); dErr != nil {
+				logging.FromContext(ctx).Error("can't keep failed update", logging.Err(dErr))
+			}
+		}()
+	}
+
+	ctx, span := tracing.Start(ctx, "telegram.Process",
+		attribute.String("event.type", event.Type.String()),
+		attribute.Int("telegram.update_id", metaInfo.UpdateID),
+		attribute.Int("telegram.chat_id", metaInfo.ChatID),
+		attribute.String("telegram.chat_type", metaInfo.ChatType),
+		attribute.Int("telegram.user_id", metaInfo.UserID),
+	)
+	defer func() { tracing.End(span, err) }()
+
+	ok, err := p.authorize(ctx, event, metaInfo)
+	if err != nil {
+		return e.Wrap("can't authorize event", err)
+	}
+	if !ok {
+		return nil
+	}
+
+	// in group chats the bot sees messages meant for people and other bots,
+	// their senders are neither registered nor limited
+	if !p.isAddressed(event, metaInfo) {
+		return nil
+	}
+
+	if err := p.registerUser(ctx, metaInfo); err != nil {
+		return e.Wrap("can't register user", err)
+	}
+
+	ok, err = p.throttle(ctx, event, metaInfo)
+	if err != nil {
+		return e.Wrap("can't throttle event", err)
+	}
+	if !ok {
+		return nil
+	}
+
 	switch event.Type {
 	case events.Message:
-		//chatState, err := p.cache.GetState(ctx, fmt.Sprintf("%d", metaInfo.ChatID))
-		//if err != nil {
-		//	return e.Wrap("can't get state", err)
-		//}
-		//log.Println("chatState:", chatState)
-
 		return p.processMessage(ctx, event)
 	case events.CallbackQuery:
 		err = p.cache.SetState(ctx, fmt.Sprintf("%d", metaInfo.ChatID), "waiting_for_input")
@@ -86,7 +274,14 @@ func (p *Processor) processMessage(ctx context.Context, event events.Event) erro
 		return e.Wrap("can't process message", err)
 	}
 
-	if err := p.doCmd(ctx, event.Text, metaInfo.ChatID, metaInfo.Username); err != nil {
+	if metaInfo.ChatType == telegram.ChatTypeChannel && !isCommand(event.Text) {
+		if err := p.collectPages(ctx, event.Text, metaInfo); err != nil {
+			return e.Wrap("can't process channel post", err)
+		}
+		return nil
+	}
+
+	if err := p.doCmd(ctx, event.Text, metaInfo); err != nil {
 		return e.Wrap("can't process message", err)
 	}
 
@@ -99,12 +294,25 @@ func (p *Processor) processCallbackQuery(ctx context.Context, event events.Event
 		return e.Wrap("can't process message", err)
 	}
 
+	answer, err := p.doCallback(ctx, event.Text, metaInfo)
+	if err != nil {
+		return e.Wrap("can't process callback query", err)
+	}
+
 	ans := telegram.CallbackQueryConfig{
 		CallbackQueryId: metaInfo.CallbackQueryId,
-		Text:            &event.Text,
+		Text:            &answer,
 	}
 
-	return p.tg.AnswerCallbackQuery(ans)
+	return p.tg.AnswerCallbackQuery(ctx, ans)
+}
+
+// sendMessage sends a reply which is never changed afterwards, so the sent
+// message isn't needed.
+func (p *Processor) sendMessage(ctx context.Context, msg telegram.MessageConfig) error {
+	_, err := p.tg.SendMessage(ctx, msg)
+
+	return err
 }
 
 func meta(event events.Event) (Meta, error) {
@@ -120,37 +328,69 @@ func event(update telegram.Update) events.Event {
 	updateType := fetchType(update)
 
 	res := events.Event{
-		Type: updateType,
+		Type:     updateType,
+		LogAttrs: []any{"update_id", update.UpdateId},
 	}
 
 	if updateType == events.Message {
+		msg := fetchMessage(update)
+
 		res.Meta = Meta{
-			ChatID:   update.Message.Chat.ID,
-			Username: update.Message.From.Username,
+			UpdateID:  update.UpdateId,
+			ChatID:    msg.Chat.ID,
+			ChatType:  msg.Chat.Type,
+			ChatTitle: msg.Chat.Title,
+			UserID:    msg.From.ID,
+			Username:  msg.From.Username,
+			FirstName: msg.From.FirstName,
+			update:    update,
 		}
 
 		res.Text = fetchText(update)
+		res.LogAttrs = append(res.LogAttrs, "chat_id", msg.Chat.ID, "user_id", msg.From.ID)
 	}
 
 	if updateType == events.CallbackQuery {
 		res.Meta = Meta{
+			UpdateID:        update.UpdateId,
 			ChatID:          update.CallbackQuery.Message.Chat.ID,
+			ChatType:        update.CallbackQuery.Message.Chat.Type,
+			ChatTitle:       update.CallbackQuery.Message.Chat.Title,
+			UserID:          update.CallbackQuery.From.ID,
 			Username:        update.CallbackQuery.From.Username,
+			FirstName:       update.CallbackQuery.From.FirstName,
 			CallbackQueryId: update.CallbackQuery.ID,
+			MessageText:     update.CallbackQuery.Message.Text,
+			update:          update,
 		}
 
 		res.Text = fetchCallbackQueryData(update)
+		res.LogAttrs = append(res.LogAttrs, "chat_id", update.CallbackQuery.Message.Chat.ID, "user_id", update.CallbackQuery.From.ID)
 	}
 
 	return res
 }
 
+// fetchMessage returns either a message or a channel post.
+func fetchMessage(update telegram.Update) *telegram.Message {
+	if update.Message != nil {
+		return update.Message
+	}
+
+	return update.ChannelPost
+}
+
 func fetchText(update telegram.Update) string {
-	if update.Message == nil {
+	msg := fetchMessage(update)
+	if msg == nil {
 		return ""
 	}
 
-	return update.Message.Text
+	if msg.Text == "" {
+		return msg.Caption
+	}
+
+	return msg.Text
 }
 
 func fetchCallbackQueryData(update telegram.Update) string {
@@ -162,7 +402,7 @@ func fetchCallbackQueryData(update telegram.Update) string {
 }
 
 func fetchType(update telegram.Update) events.Type {
-	if update.Message != nil {
+	if update.Message != nil || update.ChannelPost != nil {
 		return events.Message
 	}
 	if update.CallbackQuery != nil {
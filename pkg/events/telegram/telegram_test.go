@@ -0,0 +1,302 @@
+package telegram
+
+import (
+	"context"
+	"strings"
+	"telegrambot/pkg/access"
+	"telegrambot/pkg/clients/telegram"
+	"telegrambot/pkg/clients/telegram/telegramtest"
+	"telegrambot/pkg/repository"
+	"telegrambot/pkg/repository/memory"
+	stateMemory "telegrambot/pkg/state/memory"
+	"testing"
+	"time"
+)
+
+var (
+	alice = telegram.User{ID: 100, Username: "alice", FirstName: "Alice"}
+	bob   = telegram.User{ID: 200, Username: "bob", FirstName: "Bob"}
+	group = telegram.Chat{ID: -300, Type: telegram.ChatTypeGroup, Title: "Readers"}
+)
+
+// bot is a processor talking to a fake Telegram and keeping pages in memory.
+type bot struct {
+	srv  *telegramtest.Server
+	repo *memory.RepositoryMemory
+	p    *Processor
+}
+
+func newBot(t *testing.T, opts Options) *bot {
+	t.Helper()
+
+	srv := telegramtest.NewServer()
+	t.Cleanup(srv.Close)
+
+	tg, err := telegram.NewWithURL(srv.URL, telegramtest.Token)
+	if err != nil {
+		t.Fatalf("NewWithURL: %v", err)
+	}
+
+	repo := memory.New()
+	opts.BotUsername = srv.Bot.Username
+	if opts.UndoWindow == 0 {
+		opts.UndoWindow = time.Hour
+	}
+
+	return &bot{srv: srv, repo: repo, p: New(tg, repo, stateMemory.New(), opts)}
+}
+
+// handle processes all queued updates and returns the messages the bot has
+// sent meanwhile.
+func (b *bot) handle(t *testing.T) []telegramtest.Message {
+	t.Helper()
+
+	ctx := context.Background()
+	sent := len(b.srv.Messages())
+
+	for {
+		got, err := b.p.Fetch(ctx, 100)
+		if err != nil {
+			t.Fatalf("Fetch: %v", err)
+		}
+		if len(got) == 0 {
+			return b.srv.Messages()[sent:]
+		}
+
+		for _, event := range got {
+			if err := b.p.Process(ctx, event); err != nil {
+				t.Fatalf("Process(%q): %v", event.Text, err)
+			}
+		}
+	}
+}
+
+// say sends the text to the bot in a private chat and returns the only reply.
+func (b *bot) say(t *testing.T, from telegram.User, text string) telegramtest.Message {
+	t.Helper()
+
+	b.srv.SendText(from, text)
+
+	replies := b.handle(t)
+	if len(replies) != 1 {
+		t.Fatalf("%s got %d replies, want one: %+v", text, len(replies), replies)
+	}
+
+	return replies[0]
+}
+
+func (b *bot) page(t *testing.T, owner string, url string) *repository.Page {
+	t.Helper()
+
+	p, err := b.repo.Get(context.Background(), owner, url)
+	if err != nil {
+		t.Fatalf("Get(%s, %s): %v", owner, url, err)
+	}
+
+	return p
+}
+
+func button(t *testing.T, m telegramtest.Message, data string) {
+	t.Helper()
+
+	for _, btn := range m.Buttons() {
+		if btn.CallbackData != nil && *btn.CallbackData == data {
+			return
+		}
+	}
+
+	t.Fatalf("message %q has no %q button", m.Text, data)
+}
+
+func TestSaveAndPick(t *testing.T) {
+	b := newBot(t, Options{})
+	const url = "https://example.com/article"
+
+	if got := b.say(t, alice, "/start"); !strings.HasPrefix(got.Text, "Привет!") {
+		t.Errorf("/start = %q, want the greeting", got.Text)
+	}
+
+	if got := b.say(t, alice, url+" #go #Книги"); got.Text != msgSaved {
+		t.Errorf("saving = %q, want %q", got.Text, msgSaved)
+	}
+	if tags := b.page(t, "alice", url).Tags; len(tags) != 2 || tags[0] != "go" || tags[1] != "книги" {
+		t.Errorf("tags = %v, want go and книги", tags)
+	}
+
+	if got := b.say(t, alice, url); got.Text != msgAlreadyExists {
+		t.Errorf("saving again = %q, want %q", got.Text, msgAlreadyExists)
+	}
+
+	// the list of bob is separate
+	if got := b.say(t, bob, "/rnd"); got.Text != msgNoSavedPages {
+		t.Errorf("/rnd of bob = %q, want %q", got.Text, msgNoSavedPages)
+	}
+
+	got := b.say(t, alice, "/rnd")
+	if got.Text != url {
+		t.Fatalf("/rnd = %q, want %s", got.Text, url)
+	}
+	button(t, got, deleteCallback)
+
+	if b.page(t, "alice", url).ReadAt.IsZero() {
+		t.Error("picked page is not marked read")
+	}
+
+	if got := b.say(t, alice, "/rnd"); got.Text != msgNoSavedPages {
+		t.Errorf("/rnd after reading = %q, want %q", got.Text, msgNoSavedPages)
+	}
+
+	if got := b.say(t, alice, "/undo"); got.Text != msgRestored+url {
+		t.Errorf("/undo = %q, want %q", got.Text, msgRestored+url)
+	}
+	if got := b.say(t, alice, "/rnd"); got.Text != url {
+		t.Errorf("/rnd after /undo = %q, want %s", got.Text, url)
+	}
+}
+
+func TestDeleteButton(t *testing.T) {
+	b := newBot(t, Options{})
+	const url = "https://example.com/article"
+
+	b.say(t, alice, url)
+	picked := b.say(t, alice, "/rnd")
+
+	id := b.srv.Press(alice, picked, deleteCallback)
+	b.handle(t)
+
+	answers := b.srv.Answers()
+	if len(answers) != 1 || answers[0].CallbackQueryID != id || answers[0].Text != msgDeleted {
+		t.Fatalf("answers = %+v, want %q to query %s", answers, msgDeleted, id)
+	}
+
+	if got := b.say(t, alice, "/trash"); !strings.HasPrefix(got.Text, msgTrashTitle) || !strings.Contains(got.Text, url) {
+		t.Errorf("/trash = %q, want the deleted page", got.Text)
+	}
+
+	if got := b.say(t, alice, "/undo"); got.Text != msgRestored+url {
+		t.Errorf("/undo = %q, want %q", got.Text, msgRestored+url)
+	}
+	if got := b.say(t, alice, "/trash"); got.Text != msgTrashEmpty {
+		t.Errorf("/trash after /undo = %q, want %q", got.Text, msgTrashEmpty)
+	}
+	if got := b.say(t, alice, "/undo"); got.Text != msgNothingToUndo {
+		t.Errorf("second /undo = %q, want %q", got.Text, msgNothingToUndo)
+	}
+}
+
+func TestGroupChat(t *testing.T) {
+	b := newBot(t, Options{})
+	ctx := context.Background()
+
+	// talk between people is none of the bot's business
+	b.srv.SendChatText(alice, group, "hi everyone")
+	b.srv.SendChatText(bob, group, "/rnd@other_bot")
+	if replies := b.handle(t); len(replies) != 0 {
+		t.Errorf("bot replied to messages not meant for it: %+v", replies)
+	}
+
+	users, err := b.repo.Users(ctx)
+	if err != nil {
+		t.Fatalf("Users: %v", err)
+	}
+	if len(users) != 0 {
+		t.Errorf("users who didn't address the bot are registered: %+v", users)
+	}
+
+	// an unknown command may be meant for another bot in the group
+	b.srv.SendChatText(bob, group, "/unknown")
+	if replies := b.handle(t); len(replies) != 0 {
+		t.Errorf("bot replied to an unknown command in the group: %+v", replies)
+	}
+
+	// the list is shared by the chat
+	b.srv.SendChatText(alice, group, "https://example.com/shared")
+	if replies := b.handle(t); len(replies) != 1 || replies[0].Text != msgSaved {
+		t.Fatalf("saving in the group = %+v, want %q", replies, msgSaved)
+	}
+
+	b.srv.SendChatText(bob, group, "/rnd@"+b.srv.Bot.Username)
+	replies := b.handle(t)
+	if len(replies) != 1 || replies[0].Text != "https://example.com/shared" || replies[0].ChatID != group.ID {
+		t.Fatalf("/rnd in the group = %+v, want the shared page", replies)
+	}
+
+	if got := b.say(t, alice, "/rnd"); got.Text != msgNoSavedPages {
+		t.Errorf("/rnd in private = %q, want the personal list to be empty", got.Text)
+	}
+}
+
+func TestThrottle(t *testing.T) {
+	b := newBot(t, Options{Limits: Limits{CommandsPerSecond: 1}})
+
+	b.srv.SendText(alice, "/help")
+	b.srv.SendText(alice, "/help")
+	b.srv.SendText(alice, "/help")
+
+	replies := b.handle(t)
+	if len(replies) != 2 || replies[0].Text != msgHelp || replies[1].Text != msgTooManyRequests {
+		t.Errorf("replies = %+v, want help and one notice", replies)
+	}
+
+	// nobody in a group is bothered with the notice
+	b.srv.SendChatText(bob, group, "/help")
+	b.srv.SendChatText(bob, group, "/help")
+	b.srv.SendChatText(bob, group, "chatting")
+
+	replies = b.handle(t)
+	if len(replies) != 1 || replies[0].Text != msgHelp {
+		t.Errorf("replies in the group = %+v, want help only", replies)
+	}
+}
+
+func TestAccess(t *testing.T) {
+	channel := telegram.Chat{ID: -1001, Type: telegram.ChatTypeChannel, Title: "Links"}
+	stranger := telegram.Chat{ID: -1002, Type: telegram.ChatTypeChannel, Title: "Spam"}
+
+	b := newBot(t, Options{
+		Access: access.New([]int{alice.ID, channel.ID}, nil, nil, memory.New()),
+	})
+	ctx := context.Background()
+
+	if got := b.say(t, alice, "/help"); got.Text != msgHelp {
+		t.Errorf("/help of alice = %q, want %q", got.Text, msgHelp)
+	}
+
+	// channel posts have no sender and are authorized by the channel
+	b.srv.Queue(telegram.Update{ChannelPost: &telegram.Message{Text: "https://example.com/posted", Chat: channel}})
+	b.srv.Queue(telegram.Update{ChannelPost: &telegram.Message{Text: "https://example.com/spam", Chat: stranger}})
+	if replies := b.handle(t); len(replies) != 0 {
+		t.Errorf("bot replied to channel posts: %+v", replies)
+	}
+
+	for owner, want := range map[string]int{"chat-1001": 1, "chat-1002": 0} {
+		count, err := b.repo.Count(ctx, owner)
+		if err != nil {
+			t.Fatalf("Count(%s): %v", owner, err)
+		}
+		if count != want {
+			t.Errorf("%s has %d pages, want %d", owner, count, want)
+		}
+	}
+
+	// denied users are told once in a while, not on every message
+	b.srv.SendText(bob, "/help")
+	b.srv.SendText(bob, "/help")
+	b.srv.SendText(bob, "https://example.com")
+
+	replies := b.handle(t)
+	if len(replies) != 1 || replies[0].Text != msgForbidden {
+		t.Errorf("replies to bob = %+v, want one %q", replies, msgForbidden)
+	}
+}
+
+func TestShutdownWithoutBackgroundWork(t *testing.T) {
+	b := newBot(t, Options{})
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	if err := b.p.Shutdown(ctx); err != nil {
+		t.Fatalf("Shutdown: %v", err)
+	}
+}
@@ -0,0 +1,98 @@
+package telegram
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"telegrambot/internal/e"
+	"telegrambot/pkg/clients/telegram"
+	"telegrambot/pkg/repository"
+	"time"
+)
+
+const (
+	deleteCallback = "delete"
+	trashLimit     = 20
+)
+
+func (p *Processor) undo(ctx context.Context, chatID int, username string) (err error) {
+	defer func() {
+		err = e.WrapIfErr("can't do cmd undo", err)
+	}()
+
+	msg := telegram.MessageConfig{
+		ChatID: chatID,
+	}
+
+	page, err := p.repository.LastRemoved(ctx, username, time.Now().Add(-p.opts.UndoWindow))
+	if errors.Is(err, repository.ErrPageNotFound) {
+		msg.Text = msgNothingToUndo
+		return p.sendMessage(ctx, msg)
+	}
+	if err != nil {
+		return err
+	}
+
+	if err = p.repository.Restore(ctx, page); err != nil {
+		return err
+	}
+
+	msg.Text = msgRestored + page.URL
+
+	return p.sendMessage(ctx, msg)
+}
+
+func (p *Processor) sendTrash(ctx context.Context, chatID int, username string) (err error) {
+	defer func() {
+		err = e.WrapIfErr("can't do cmd trash", err)
+	}()
+
+	msg := telegram.MessageConfig{
+		ChatID: chatID,
+	}
+
+	pages, err := p.repository.Trash(ctx, username)
+	if err != nil {
+		return err
+	}
+
+	if len(pages) == 0 {
+		msg.Text = msgTrashEmpty
+		return p.sendMessage(ctx, msg)
+	}
+
+	var b strings.Builder
+	b.WriteString(msgTrashTitle)
+	for i, page := range pages {
+		if i == trashLimit {
+			fmt.Fprintf(&b, msgTrashMore, len(pages)-trashLimit)
+			break
+		}
+
+		fmt.Fprintf(&b, "%s — %s\n", page.DeletedAt.Format(resurfaceTimeLayout), page.URL)
+	}
+
+	msg.Text = b.String()
+
+	return p.sendMessage(ctx, msg)
+}
+
+// deleteCallback moves the page sent in the message the button is attached to the trash.
+func (p *Processor) deleteCallback(ctx context.Context, meta Meta, owner string) (answer string, err error) {
+	pageURL := urlFromText(meta.MessageText)
+	if pageURL == "" {
+		return msgUnknownAction, nil
+	}
+
+	page := &repository.Page{
+		URL:      pageURL,
+		Username: owner,
+	}
+
+	if err = p.repository.Remove(ctx, page); err != nil {
+		return "", e.Wrap("can't delete page from callback", err)
+	}
+
+	return msgDeleted, nil
+}
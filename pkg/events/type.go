@@ -3,7 +3,7 @@ package events
 import "context"
 
 type Fetcher interface {
-	Fetch(limit int) ([]Event, error)
+	Fetch(ctx context.Context, limit int) ([]Event, error)
 }
 
 type Processor interface {
@@ -18,8 +18,22 @@ const (
 	CallbackQuery
 )
 
+func (t Type) String() string {
+	switch t {
+	case Message:
+		return "message"
+	case CallbackQuery:
+		return "callback_query"
+	default:
+		return "unknown"
+	}
+}
+
 type Event struct {
 	Type Type
 	Text string
 	Meta interface{}
+	// LogAttrs are key-value pairs attached to every log line about the
+	// event. They must not contain the message text.
+	LogAttrs []any
 }
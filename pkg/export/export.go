@@ -0,0 +1,134 @@
+package export
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"io"
+	"telegrambot/internal/e"
+	"telegrambot/pkg/repository"
+	"time"
+)
+
+// Page is a page as it is exported: one JSON object per line. It keeps
+// everything the repository stores except the owner, so that a list can be
+// imported under another name.
+type Page struct {
+	URL         string     `json:"url"`
+	Tags        []string   `json:"tags,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	ChatID      int        `json:"chat_id,omitempty"`
+	ResurfaceAt *time.Time `json:"resurface_at,omitempty"`
+	Review      *Review    `json:"review,omitempty"`
+	ReadAt      *time.Time `json:"read_at,omitempty"`
+	DeletedAt   *time.Time `json:"deleted_at,omitempty"`
+}
+
+type Review struct {
+	Interval    int        `json:"interval"`
+	Ease        float64    `json:"ease"`
+	Repetitions int        `json:"repetitions"`
+	DueAt       *time.Time `json:"due_at,omitempty"`
+}
+
+func NewPage(p *repository.Page) Page {
+	page := Page{
+		URL:         p.URL,
+		Tags:        p.Tags,
+		CreatedAt:   p.CreatedAt,
+		ChatID:      p.ChatID,
+		ResurfaceAt: optionalTime(p.ResurfaceAt),
+		ReadAt:      optionalTime(p.ReadAt),
+		DeletedAt:   optionalTime(p.DeletedAt),
+	}
+
+	if p.Review != (repository.Review{}) {
+		page.Review = &Review{
+			Interval:    p.Review.Interval,
+			Ease:        p.Review.Ease,
+			Repetitions: p.Review.Repetitions,
+			DueAt:       optionalTime(p.Review.DueAt),
+		}
+	}
+
+	return page
+}
+
+// Page returns the repository page owned by owner.
+func (p Page) Page(owner string) *repository.Page {
+	page := &repository.Page{
+		URL:         p.URL,
+		Username:    owner,
+		Tags:        p.Tags,
+		CreatedAt:   p.CreatedAt,
+		ChatID:      p.ChatID,
+		ResurfaceAt: fromOptional(p.ResurfaceAt),
+		ReadAt:      fromOptional(p.ReadAt),
+		DeletedAt:   fromOptional(p.DeletedAt),
+	}
+
+	if p.Review != nil {
+		page.Review = repository.Review{
+			Interval:    p.Review.Interval,
+			Ease:        p.Review.Ease,
+			Repetitions: p.Review.Repetitions,
+			DueAt:       fromOptional(p.Review.DueAt),
+		}
+	}
+
+	return page
+}
+
+// Write writes pages as JSON lines.
+func Write(w io.Writer, pages []*repository.Page) error {
+	enc := json.NewEncoder(w)
+
+	for _, p := range pages {
+		if err := enc.Encode(NewPage(p)); err != nil {
+			return e.Wrap("can't export page", err)
+		}
+	}
+
+	return nil
+}
+
+// Read calls fn for every page read from r, giving the pages to owner.
+func Read(r io.Reader, owner string, fn func(p *repository.Page) error) error {
+	dec := json.NewDecoder(bufio.NewReader(r))
+
+	for {
+		var p Page
+
+		err := dec.Decode(&p)
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return e.Wrap("can't read page", err)
+		}
+
+		if p.URL == "" {
+			return errors.New("can't read page: url is empty")
+		}
+
+		if err := fn(p.Page(owner)); err != nil {
+			return err
+		}
+	}
+}
+
+func optionalTime(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+
+	return &t
+}
+
+func fromOptional(t *time.Time) time.Time {
+	if t == nil {
+		return time.Time{}
+	}
+
+	return *t
+}
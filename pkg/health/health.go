@@ -0,0 +1,166 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+	"telegrambot/internal/logging"
+	"time"
+)
+
+const (
+	StatusOK   = "ok"
+	StatusFail = "fail"
+)
+
+// checkTimeout bounds each check, so that a hung dependency fails the probe
+// instead of hanging it.
+const checkTimeout = 5 * time.Second
+
+// Check returns an error if the component is unhealthy.
+type Check func(ctx context.Context) error
+
+// Recent returns a check which fails if last() is more than maxAge ago.
+func Recent(last func() time.Time, maxAge time.Duration) Check {
+	return func(ctx context.Context) error {
+		if age := time.Since(last()); age > maxAge {
+			return fmt.Errorf("last success was %s ago", age.Round(time.Second))
+		}
+
+		return nil
+	}
+}
+
+type component struct {
+	name  string
+	check Check
+}
+
+// Checker serves liveness and readiness probes. Liveness checks tell whether
+// the process is stuck and should be restarted, readiness checks also cover
+// the dependencies it needs to serve users.
+type Checker struct {
+	liveness  []component
+	readiness []component
+}
+
+func New() *Checker {
+	return &Checker{}
+}
+
+// Live adds a check which is run by both probes.
+func (c *Checker) Live(name string, check Check) {
+	c.liveness = append(c.liveness, component{name: name, check: check})
+	c.readiness = append(c.readiness, component{name: name, check: check})
+}
+
+// Ready adds a check which is run by the readiness probe only.
+func (c *Checker) Ready(name string, check Check) {
+	c.readiness = append(c.readiness, component{name: name, check: check})
+}
+
+type Report struct {
+	Status     string                     `json:"status"`
+	Components map[string]ComponentReport `json:"components"`
+}
+
+type ComponentReport struct {
+	Status   string `json:"status"`
+	Error    string `json:"error,omitempty"`
+	Duration string `json:"duration"`
+}
+
+// LivenessHandler serves /healthz.
+func (c *Checker) LivenessHandler() http.Handler {
+	return handler(c.liveness)
+}
+
+// ReadinessHandler serves /readyz.
+func (c *Checker) ReadinessHandler() http.Handler {
+	return handler(c.readiness)
+}
+
+func handler(components []component) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		report := run(r.Context(), components)
+
+		code := http.StatusOK
+		if report.Status != StatusOK {
+			code = http.StatusServiceUnavailable
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		w.WriteHeader(code)
+
+		if err := json.NewEncoder(w).Encode(report); err != nil {
+			slog.Error("health: can't write report", logging.Err(err))
+		}
+	})
+}
+
+// run checks all components concurrently.
+func run(ctx context.Context, components []component) Report {
+	report := Report{
+		Status:     StatusOK,
+		Components: make(map[string]ComponentReport, len(components)),
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+
+	for _, c := range components {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			res := checkComponent(ctx, c)
+
+			mu.Lock()
+			defer mu.Unlock()
+
+			report.Components[c.name] = res
+			if res.Status != StatusOK {
+				report.Status = StatusFail
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	return report
+}
+
+func checkComponent(ctx context.Context, c component) ComponentReport {
+	ctx, cancel := context.WithTimeout(ctx, checkTimeout)
+	defer cancel()
+
+	start := time.Now()
+
+	// not every dependency takes a context, so don't wait for them past the timeout
+	done := make(chan error, 1)
+	go func() {
+		done <- c.check(ctx)
+	}()
+
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	res := ComponentReport{
+		Status:   StatusOK,
+		Duration: time.Since(start).Round(time.Microsecond).String(),
+	}
+	if err != nil {
+		res.Status = StatusFail
+		res.Error = err.Error()
+	}
+
+	return res
+}
@@ -0,0 +1,63 @@
+package instrumented
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+	"telegrambot/internal/logging"
+	"telegrambot/pkg/metrics"
+	"telegrambot/pkg/repository"
+	"telegrambot/pkg/state"
+	"telegrambot/pkg/tracing"
+	"time"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// operation is a call to a storage being observed.
+type operation struct {
+	ctx   context.Context
+	name  string
+	start time.Time
+	span  trace.Span
+}
+
+func start(ctx context.Context, name string) (context.Context, *operation) {
+	ctx, span := tracing.Start(ctx, name)
+
+	return ctx, &operation{ctx: ctx, name: name, start: time.Now(), span: span}
+}
+
+// end records the operation. Errors which describe a normal result, like an
+// empty list, are not failures.
+func (op *operation) end(err error) {
+	duration := time.Since(op.start)
+
+	logger := logging.FromContext(op.ctx)
+	if logger.Enabled(op.ctx, slog.LevelDebug) {
+		attrs := []any{"operation", op.name, "duration", duration}
+		if err != nil {
+			attrs = append(attrs, logging.Err(err))
+		}
+		logger.Debug("storage operation", attrs...)
+	}
+
+	if isExpected(err) {
+		err = nil
+	}
+
+	tracing.End(op.span, err)
+
+	if store, name, ok := strings.Cut(op.name, "."); ok && store == "repository" {
+		metrics.ObserveRepository(name, err, op.start)
+	}
+}
+
+func isExpected(err error) bool {
+	return errors.Is(err, repository.ErrNoSavedPages) ||
+		errors.Is(err, repository.ErrPageNotFound) ||
+		errors.Is(err, repository.ErrInviteNotFound) ||
+		errors.Is(err, repository.ErrNotMember) ||
+		errors.Is(err, state.ErrNotFound)
+}
@@ -0,0 +1,227 @@
+package instrumented
+
+import (
+	"context"
+	"telegrambot/pkg/repository"
+	"time"
+)
+
+// Repository traces, logs and records latencies of all operations of the
+// wrapped repository.
+type Repository struct {
+	repository.Repository
+}
+
+func NewRepository(r repository.Repository) Repository {
+	return Repository{Repository: r}
+}
+
+func (r Repository) Save(ctx context.Context, p *repository.Page) (err error) {
+	ctx, op := start(ctx, "repository.save")
+	defer func() { op.end(err) }()
+
+	return r.Repository.Save(ctx, p)
+}
+
+func (r Repository) PickRandom(ctx context.Context, username string, opts repository.PickOptions) (_ *repository.Page, err error) {
+	ctx, op := start(ctx, "repository.pick_random")
+	defer func() { op.end(err) }()
+
+	return r.Repository.PickRandom(ctx, username, opts)
+}
+
+func (r Repository) Remove(ctx context.Context, p *repository.Page) (err error) {
+	ctx, op := start(ctx, "repository.remove")
+	defer func() { op.end(err) }()
+
+	return r.Repository.Remove(ctx, p)
+}
+
+func (r Repository) IsExists(ctx context.Context, p *repository.Page) (_ bool, err error) {
+	ctx, op := start(ctx, "repository.is_exists")
+	defer func() { op.end(err) }()
+
+	return r.Repository.IsExists(ctx, p)
+}
+
+func (r Repository) Count(ctx context.Context, username string) (_ int, err error) {
+	ctx, op := start(ctx, "repository.count")
+	defer func() { op.end(err) }()
+
+	return r.Repository.Count(ctx, username)
+}
+
+func (r Repository) MarkRead(ctx context.Context, p *repository.Page, at time.Time) (err error) {
+	ctx, op := start(ctx, "repository.mark_read")
+	defer func() { op.end(err) }()
+
+	return r.Repository.MarkRead(ctx, p, at)
+}
+
+func (r Repository) Snooze(ctx context.Context, p *repository.Page, until time.Time) (err error) {
+	ctx, op := start(ctx, "repository.snooze")
+	defer func() { op.end(err) }()
+
+	return r.Repository.Snooze(ctx, p, until)
+}
+
+func (r Repository) DueSnoozed(ctx context.Context, now time.Time, retryAt time.Time) (_ []*repository.Page, err error) {
+	ctx, op := start(ctx, "repository.due_snoozed")
+	defer func() { op.end(err) }()
+
+	return r.Repository.DueSnoozed(ctx, now, retryAt)
+}
+
+func (r Repository) Unsnooze(ctx context.Context, p *repository.Page) (err error) {
+	ctx, op := start(ctx, "repository.unsnooze")
+	defer func() { op.end(err) }()
+
+	return r.Repository.Unsnooze(ctx, p)
+}
+
+func (r Repository) Get(ctx context.Context, username string, url string) (_ *repository.Page, err error) {
+	ctx, op := start(ctx, "repository.get")
+	defer func() { op.end(err) }()
+
+	return r.Repository.Get(ctx, username, url)
+}
+
+func (r Repository) UpdateReview(ctx context.Context, p *repository.Page) (err error) {
+	ctx, op := start(ctx, "repository.update_review")
+	defer func() { op.end(err) }()
+
+	return r.Repository.UpdateReview(ctx, p)
+}
+
+func (r Repository) PickDue(ctx context.Context, username string, now time.Time) (_ *repository.Page, err error) {
+	ctx, op := start(ctx, "repository.pick_due")
+	defer func() { op.end(err) }()
+
+	return r.Repository.PickDue(ctx, username, now)
+}
+
+func (r Repository) Stats(ctx context.Context, username string, now time.Time) (_ *repository.Stats, err error) {
+	ctx, op := start(ctx, "repository.stats")
+	defer func() { op.end(err) }()
+
+	return r.Repository.Stats(ctx, username, now)
+}
+
+func (r Repository) LastRemoved(ctx context.Context, username string, since time.Time) (_ *repository.Page, err error) {
+	ctx, op := start(ctx, "repository.last_removed")
+	defer func() { op.end(err) }()
+
+	return r.Repository.LastRemoved(ctx, username, since)
+}
+
+func (r Repository) Restore(ctx context.Context, p *repository.Page) (err error) {
+	ctx, op := start(ctx, "repository.restore")
+	defer func() { op.end(err) }()
+
+	return r.Repository.Restore(ctx, p)
+}
+
+func (r Repository) Trash(ctx context.Context, username string) (_ []*repository.Page, err error) {
+	ctx, op := start(ctx, "repository.trash")
+	defer func() { op.end(err) }()
+
+	return r.Repository.Trash(ctx, username)
+}
+
+func (r Repository) Purge(ctx context.Context, before time.Time) (_ int, err error) {
+	ctx, op := start(ctx, "repository.purge")
+	defer func() { op.end(err) }()
+
+	return r.Repository.Purge(ctx, before)
+}
+
+func (r Repository) Pages(ctx context.Context, username string) (_ []*repository.Page, err error) {
+	ctx, op := start(ctx, "repository.pages")
+	defer func() { op.end(err) }()
+
+	return r.Repository.Pages(ctx, username)
+}
+
+func (r Repository) CreateCollection(ctx context.Context, c *repository.Collection) (err error) {
+	ctx, op := start(ctx, "repository.create_collection")
+	defer func() { op.end(err) }()
+
+	return r.Repository.CreateCollection(ctx, c)
+}
+
+func (r Repository) CreateInvite(ctx context.Context, collectionID string, role repository.Role) (_ string, err error) {
+	ctx, op := start(ctx, "repository.create_invite")
+	defer func() { op.end(err) }()
+
+	return r.Repository.CreateInvite(ctx, collectionID, role)
+}
+
+func (r Repository) Join(ctx context.Context, code string, username string) (_ *repository.Membership, err error) {
+	ctx, op := start(ctx, "repository.join")
+	defer func() { op.end(err) }()
+
+	return r.Repository.Join(ctx, code, username)
+}
+
+func (r Repository) Memberships(ctx context.Context, username string) (_ []*repository.Membership, err error) {
+	ctx, op := start(ctx, "repository.memberships")
+	defer func() { op.end(err) }()
+
+	return r.Repository.Memberships(ctx, username)
+}
+
+func (r Repository) Membership(ctx context.Context, collectionID string, username string) (_ *repository.Membership, err error) {
+	ctx, op := start(ctx, "repository.membership")
+	defer func() { op.end(err) }()
+
+	return r.Repository.Membership(ctx, collectionID, username)
+}
+
+func (r Repository) Ban(ctx context.Context, userID int) (err error) {
+	ctx, op := start(ctx, "repository.ban")
+	defer func() { op.end(err) }()
+
+	return r.Repository.Ban(ctx, userID)
+}
+
+func (r Repository) Unban(ctx context.Context, userID int) (err error) {
+	ctx, op := start(ctx, "repository.unban")
+	defer func() { op.end(err) }()
+
+	return r.Repository.Unban(ctx, userID)
+}
+
+func (r Repository) IsBanned(ctx context.Context, userID int) (_ bool, err error) {
+	ctx, op := start(ctx, "repository.is_banned")
+	defer func() { op.end(err) }()
+
+	return r.Repository.IsBanned(ctx, userID)
+}
+
+func (r Repository) Banned(ctx context.Context) (_ []int, err error) {
+	ctx, op := start(ctx, "repository.banned")
+	defer func() { op.end(err) }()
+
+	return r.Repository.Banned(ctx)
+}
+
+func (r Repository) TouchUser(ctx context.Context, u *repository.User) (err error) {
+	ctx, op := start(ctx, "repository.touch_user")
+	defer func() { op.end(err) }()
+
+	return r.Repository.TouchUser(ctx, u)
+}
+
+func (r Repository) Users(ctx context.Context) (_ []*repository.User, err error) {
+	ctx, op := start(ctx, "repository.users")
+	defer func() { op.end(err) }()
+
+	return r.Repository.Users(ctx)
+}
+
+func (r Repository) MarkBlocked(ctx context.Context, userID int, at time.Time) (err error) {
+	ctx, op := start(ctx, "repository.mark_blocked")
+	defer func() { op.end(err) }()
+
+	return r.Repository.MarkBlocked(ctx, userID, at)
+}
@@ -0,0 +1,44 @@
+package instrumented
+
+import (
+	"context"
+	"telegrambot/pkg/state"
+	"time"
+)
+
+// Cache traces and logs all calls to the wrapped cache.
+type Cache struct {
+	state.Cache
+}
+
+func NewCache(c state.Cache) Cache {
+	return Cache{Cache: c}
+}
+
+func (c Cache) GetState(ctx context.Context, key string) (_ string, err error) {
+	ctx, op := start(ctx, "state.get")
+	defer func() { op.end(err) }()
+
+	return c.Cache.GetState(ctx, key)
+}
+
+func (c Cache) SetState(ctx context.Context, key string, value string) (err error) {
+	ctx, op := start(ctx, "state.set")
+	defer func() { op.end(err) }()
+
+	return c.Cache.SetState(ctx, key, value)
+}
+
+func (c Cache) SetStateTTL(ctx context.Context, key string, value string, ttl time.Duration) (err error) {
+	ctx, op := start(ctx, "state.set_ttl")
+	defer func() { op.end(err) }()
+
+	return c.Cache.SetStateTTL(ctx, key, value, ttl)
+}
+
+func (c Cache) DeleteState(ctx context.Context, key string) (err error) {
+	ctx, op := start(ctx, "state.delete")
+	defer func() { op.end(err) }()
+
+	return c.Cache.DeleteState(ctx, key)
+}
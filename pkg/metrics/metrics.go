@@ -0,0 +1,105 @@
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "telegrambot"
+
+const (
+	OutcomeOK    = "ok"
+	OutcomeError = "error"
+)
+
+var (
+	UpdatesFetched = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "updates_fetched_total",
+		Help:      "Updates fetched from Telegram.",
+	})
+
+	EventsProcessed = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "events_processed_total",
+		Help:      "Processed events by type and outcome.",
+	}, []string{"type", "outcome"})
+
+	EventDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "event_processing_seconds",
+		Help:      "Time spent processing an event.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"type"})
+
+	WorkersInFlight = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "consumer_workers_in_flight",
+		Help:      "Events being processed right now.",
+	})
+
+	TelegramRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "telegram_requests_total",
+		Help:      "Telegram Bot API calls by method and response code.",
+	}, []string{"method", "code"})
+
+	TelegramDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "telegram_request_duration_seconds",
+		Help:      "Latency of Telegram Bot API calls.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method"})
+
+	RepositoryDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "repository_operation_seconds",
+		Help:      "Latency of repository operations by outcome.",
+		Buckets:   []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1},
+	}, []string{"operation", "outcome"})
+)
+
+// TelegramCodeNetwork labels calls which got no response from Telegram.
+const TelegramCodeNetwork = "network"
+
+// ObserveTelegram records a Bot API call which started at start. Code is the
+// error code returned by Telegram, 200 on success.
+func ObserveTelegram(method string, code int, start time.Time) {
+	TelegramRequests.WithLabelValues(method, strconv.Itoa(code)).Inc()
+	TelegramDuration.WithLabelValues(method).Observe(time.Since(start).Seconds())
+}
+
+// ObserveTelegramNetworkError records a Bot API call which failed to get a response.
+func ObserveTelegramNetworkError(method string, start time.Time) {
+	TelegramRequests.WithLabelValues(method, TelegramCodeNetwork).Inc()
+	TelegramDuration.WithLabelValues(method).Observe(time.Since(start).Seconds())
+}
+
+// ObserveEvent records an event of the given type processed since start.
+func ObserveEvent(eventType string, err error, start time.Time) {
+	EventsProcessed.WithLabelValues(eventType, outcome(err)).Inc()
+	EventDuration.WithLabelValues(eventType).Observe(time.Since(start).Seconds())
+}
+
+// ObserveRepository records a repository operation started at start.
+func ObserveRepository(operation string, err error, start time.Time) {
+	RepositoryDuration.WithLabelValues(operation, outcome(err)).Observe(time.Since(start).Seconds())
+}
+
+// Handler serves metrics in the Prometheus format.
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
+
+func outcome(err error) string {
+	if err != nil {
+		return OutcomeError
+	}
+
+	return OutcomeOK
+}
@@ -0,0 +1,72 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"sync"
+	"telegrambot/internal/e"
+	"telegrambot/pkg/state"
+	"time"
+)
+
+// Limiter is a sliding window rate limiter which keeps timestamps of recent
+// events in state.Cache.
+type Limiter struct {
+	cache state.Cache
+	// mu serializes read-modify-write of the windows.
+	mu sync.Mutex
+}
+
+func New(cache state.Cache) *Limiter {
+	return &Limiter{cache: cache}
+}
+
+// Allow records an event for the key and reports whether there were less
+// than limit events during the window before now. Rejected events are not
+// recorded. A limit which is not positive allows everything.
+func (l *Limiter) Allow(ctx context.Context, key string, limit int, window time.Duration, now time.Time) (bool, error) {
+	if limit <= 0 {
+		return true, nil
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	key = "ratelimit:" + key
+
+	value, err := l.cache.GetState(ctx, key)
+	if err != nil && !errors.Is(err, state.ErrNotFound) {
+		return false, e.Wrap("can't get rate limit window", err)
+	}
+
+	events := recent(value, now.Add(-window))
+	if len(events) >= limit {
+		return false, nil
+	}
+
+	events = append(events, strconv.FormatInt(now.UnixNano(), 10))
+
+	// the window is useless once it is over, so it expires with it
+	if err := l.cache.SetStateTTL(ctx, key, strings.Join(events, " "), window); err != nil {
+		return false, e.Wrap("can't save rate limit window", err)
+	}
+
+	return true, nil
+}
+
+// recent returns timestamps from value which are after since.
+func recent(value string, since time.Time) []string {
+	var res []string
+	for _, f := range strings.Fields(value) {
+		ts, err := strconv.ParseInt(f, 10, 64)
+		if err != nil || ts <= since.UnixNano() {
+			continue
+		}
+
+		res = append(res, f)
+	}
+
+	return res
+}
@@ -0,0 +1,186 @@
+package recorder
+
+import (
+	"bufio"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"telegrambot/internal/e"
+	"telegrambot/pkg/clients/telegram"
+)
+
+// Recorder appends incoming updates to a JSON lines file, so that they can
+// be replayed later. Names and ids of users and chats are replaced with
+// pseudonyms, which stay the same for the same salt: a user keeps their
+// list and a group chat stays a group in the recording.
+type Recorder struct {
+	mu   sync.Mutex
+	file *os.File
+	enc  *json.Encoder
+	salt []byte
+}
+
+// New opens the recording at path. Without salt a random one is used, so
+// pseudonyms differ between restarts.
+func New(path string, salt string) (*Recorder, error) {
+	key := []byte(salt)
+	if salt == "" {
+		key = make([]byte, 32)
+		if _, err := rand.Read(key); err != nil {
+			return nil, e.Wrap("can't create recorder", err)
+		}
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0774); err != nil {
+		return nil, e.Wrap("can't create recorder", err)
+	}
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, e.Wrap("can't create recorder", err)
+	}
+
+	return &Recorder{file: file, enc: json.NewEncoder(file), salt: key}, nil
+}
+
+// Record appends the updates with personal data redacted.
+func (r *Recorder) Record(updates []telegram.Update) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, u := range updates {
+		if err := r.enc.Encode(r.Redact(u)); err != nil {
+			return e.Wrap("can't record update", err)
+		}
+	}
+
+	return nil
+}
+
+func (r *Recorder) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.file.Close()
+}
+
+// Redact returns a copy of the update where users and chats are replaced
+// with pseudonyms. Texts are kept, as commands and links are what is
+// replayed. The bot itself is kept as is.
+func (r *Recorder) Redact(u telegram.Update) telegram.Update {
+	if u.Message != nil {
+		m := r.message(*u.Message)
+		u.Message = &m
+	}
+
+	if u.ChannelPost != nil {
+		m := r.message(*u.ChannelPost)
+		u.ChannelPost = &m
+	}
+
+	if u.CallbackQuery != nil {
+		q := *u.CallbackQuery
+		q.From = r.user(q.From)
+		q.Message = r.message(q.Message)
+		u.CallbackQuery = &q
+	}
+
+	return u
+}
+
+func (r *Recorder) message(m telegram.Message) telegram.Message {
+	m.From = r.user(m.From)
+	m.Chat = r.chat(m.Chat)
+
+	if m.ReplyToMessage != nil {
+		reply := r.message(*m.ReplyToMessage)
+		m.ReplyToMessage = &reply
+	}
+
+	return m
+}
+
+func (r *Recorder) user(u telegram.User) telegram.User {
+	if u.IsBot || u.ID == 0 {
+		return u
+	}
+
+	u.ID = r.id(u.ID)
+	if u.FirstName != "" {
+		u.FirstName = "User " + r.hash("user", strconv.Itoa(u.ID))
+	}
+	if u.Username != "" {
+		u.Username = "user_" + r.hash("username", u.Username)
+	}
+
+	return u
+}
+
+// chat keeps the id of a private chat equal to the id of the user.
+func (r *Recorder) chat(c telegram.Chat) telegram.Chat {
+	c.ID = r.id(c.ID)
+	if c.Title != "" {
+		c.Title = "Chat " + r.hash("chat", strconv.Itoa(c.ID))
+	}
+	if c.Username != "" {
+		c.Username = "chat_" + r.hash("username", c.Username)
+	}
+
+	return c
+}
+
+// id returns a pseudonym of the Telegram id, keeping its sign: group chats
+// have negative ids.
+func (r *Recorder) id(id int) int {
+	if id == 0 {
+		return 0
+	}
+
+	mac := hmac.New(sha256.New, r.salt)
+	mac.Write([]byte("id:" + strconv.Itoa(id)))
+	// 31 bits are enough to avoid collisions and fit any int
+	pseudonym := int(binary.BigEndian.Uint32(mac.Sum(nil))>>1) + 1
+
+	if id < 0 {
+		return -pseudonym
+	}
+
+	return pseudonym
+}
+
+func (r *Recorder) hash(kind string, value string) string {
+	mac := hmac.New(sha256.New, r.salt)
+	mac.Write([]byte(kind + ":" + value))
+
+	return hex.EncodeToString(mac.Sum(nil))[:8]
+}
+
+// Read calls fn for every update of a recording.
+func Read(r io.Reader, fn func(u telegram.Update) error) error {
+	dec := json.NewDecoder(bufio.NewReader(r))
+
+	for {
+		var u telegram.Update
+
+		err := dec.Decode(&u)
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return e.Wrap("can't read update", err)
+		}
+
+		if err := fn(u); err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,177 @@
+package recorder
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"telegrambot/pkg/clients/telegram"
+	"testing"
+)
+
+var (
+	alice = telegram.User{ID: 100, FirstName: "Alice", Username: "alice"}
+	bot   = telegram.User{ID: 1, IsBot: true, FirstName: "Bot", Username: "test_bot"}
+	group = telegram.Chat{ID: -300, Type: telegram.ChatTypeGroup, Title: "Readers", Username: "readers"}
+)
+
+func newRecorder(t *testing.T, salt string) (*Recorder, string) {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "records", "updates.jsonl")
+
+	r, err := New(path, salt)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	t.Cleanup(func() { _ = r.Close() })
+
+	return r, path
+}
+
+func private(from telegram.User, text string) telegram.Update {
+	return telegram.Update{
+		UpdateId: 1,
+		Message: &telegram.Message{
+			MessageID: 10,
+			Text:      text,
+			From:      from,
+			Chat:      telegram.Chat{ID: from.ID, Type: telegram.ChatTypePrivate, Username: from.Username},
+		},
+	}
+}
+
+func TestRedactIsStable(t *testing.T) {
+	r, _ := newRecorder(t, "salt")
+	again, _ := newRecorder(t, "salt")
+	other, _ := newRecorder(t, "other salt")
+
+	u := private(alice, "/rnd")
+
+	got := r.Redact(u).Message.From
+	if got == alice || got.ID == alice.ID || strings.Contains(got.Username, "alice") || got.FirstName == alice.FirstName {
+		t.Errorf("redacted user = %+v, want a pseudonym", got)
+	}
+
+	if same := again.Redact(u).Message.From; same != got {
+		t.Errorf("user is %+v with the same salt, want %+v", same, got)
+	}
+	if changed := other.Redact(u).Message.From; changed.ID == got.ID || changed.Username == got.Username {
+		t.Errorf("user is %+v with another salt, want another pseudonym than %+v", changed, got)
+	}
+
+	if u.Message.From != alice {
+		t.Errorf("Redact changed the update it was given: %+v", u.Message.From)
+	}
+}
+
+func TestRedactKeepsChats(t *testing.T) {
+	r, _ := newRecorder(t, "salt")
+
+	m := r.Redact(private(alice, "https://example.com")).Message
+	if m.Chat.ID != m.From.ID || m.Chat.Type != telegram.ChatTypePrivate {
+		t.Errorf("private chat = %+v, want the id of the user %d", m.Chat, m.From.ID)
+	}
+	if m.Text != "https://example.com" {
+		t.Errorf("text = %q, want it kept", m.Text)
+	}
+
+	u := telegram.Update{Message: &telegram.Message{Text: "/rnd@test_bot", From: alice, Chat: group}}
+	got := r.Redact(u).Message.Chat
+	if got.ID >= 0 || got.ID == group.ID {
+		t.Errorf("group id = %d, want a negative pseudonym", got.ID)
+	}
+	if got.Type != telegram.ChatTypeGroup || got.Title == group.Title || got.Username == group.Username {
+		t.Errorf("group = %+v, want the type kept and the names replaced", got)
+	}
+}
+
+func TestRedactKeepsBot(t *testing.T) {
+	r, _ := newRecorder(t, "salt")
+	data := "delete"
+
+	u := r.Redact(telegram.Update{
+		CallbackQuery: &telegram.CallbackQuery{
+			ID:   "7",
+			From: alice,
+			Message: telegram.Message{
+				Text: "https://example.com",
+				From: bot,
+				Chat: telegram.Chat{ID: alice.ID, Type: telegram.ChatTypePrivate},
+			},
+			Data: &data,
+		},
+	})
+
+	q := u.CallbackQuery
+	if q.Message.From != bot {
+		t.Errorf("message of the bot is from %+v, want the bot kept", q.Message.From)
+	}
+	if q.From.ID == alice.ID || q.Message.Chat.ID != q.From.ID {
+		t.Errorf("query from %+v in chat %+v, want the pseudonym of alice in both", q.From, q.Message.Chat)
+	}
+}
+
+func TestRedactReplyToMessage(t *testing.T) {
+	r, _ := newRecorder(t, "salt")
+	bob := telegram.User{ID: 200, FirstName: "Bob", Username: "bob"}
+
+	u := telegram.Update{
+		Message: &telegram.Message{
+			Text: "/snooze 1d",
+			From: alice,
+			Chat: group,
+			ReplyToMessage: &telegram.Message{
+				Text: "https://example.com",
+				From: bob,
+				Chat: group,
+			},
+		},
+	}
+
+	got := r.Redact(u).Message
+	reply := got.ReplyToMessage
+	if reply.From.ID == bob.ID || reply.From.Username == bob.Username || reply.From.FirstName == bob.FirstName {
+		t.Errorf("replied message is from %+v, want a pseudonym", reply.From)
+	}
+	if reply.Chat != got.Chat {
+		t.Errorf("replied message is in %+v, want %+v", reply.Chat, got.Chat)
+	}
+	if u.Message.ReplyToMessage.From != bob {
+		t.Errorf("Redact changed the replied message it was given: %+v", u.Message.ReplyToMessage.From)
+	}
+}
+
+func TestRecordAndRead(t *testing.T) {
+	r, path := newRecorder(t, "salt")
+
+	updates := []telegram.Update{private(alice, "/start"), private(alice, "https://example.com")}
+	updates[1].UpdateId = 2
+
+	if err := r.Record(updates); err != nil {
+		t.Fatalf("Record: %v", err)
+	}
+	if err := r.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = f.Close() }()
+
+	var read []telegram.Update
+	if err := Read(f, func(u telegram.Update) error {
+		read = append(read, u)
+		return nil
+	}); err != nil {
+		t.Fatalf("Read: %v", err)
+	}
+
+	if len(read) != 2 || read[0].UpdateId != 1 || read[1].Message.Text != "https://example.com" {
+		t.Fatalf("read %+v, want the recorded updates", read)
+	}
+	if *read[1].Message != *r.Redact(updates[1]).Message {
+		t.Errorf("read %+v, want it redacted as recorded", read[1].Message)
+	}
+}
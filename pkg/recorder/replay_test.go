@@ -0,0 +1,111 @@
+package recorder_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"telegrambot/pkg/clients/telegram"
+	"telegrambot/pkg/clients/telegram/telegramtest"
+	tgProcessor "telegrambot/pkg/events/telegram"
+	"telegrambot/pkg/recorder"
+	"telegrambot/pkg/repository/memory"
+	stateMemory "telegrambot/pkg/state/memory"
+	"testing"
+	"time"
+)
+
+// run handles all queued updates with a new bot and returns what it sent.
+func run(t *testing.T, srv *telegramtest.Server, rec *recorder.Recorder) []telegramtest.Message {
+	t.Helper()
+
+	tg, err := telegram.NewWithURL(srv.URL, telegramtest.Token)
+	if err != nil {
+		t.Fatalf("NewWithURL: %v", err)
+	}
+
+	p := tgProcessor.New(tg, memory.New(), stateMemory.New(), tgProcessor.Options{
+		UndoWindow:  time.Hour,
+		BotUsername: srv.Bot.Username,
+		Recorder:    rec,
+	})
+
+	ctx := context.Background()
+	for {
+		got, err := p.Fetch(ctx, 100)
+		if err != nil {
+			t.Fatalf("Fetch: %v", err)
+		}
+		if len(got) == 0 {
+			return srv.Messages()
+		}
+
+		for _, event := range got {
+			if err := p.Process(ctx, event); err != nil {
+				t.Fatalf("Process(%q): %v", event.Text, err)
+			}
+		}
+	}
+}
+
+func TestReplayRecording(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "updates.jsonl")
+	alice := telegram.User{ID: 100, FirstName: "Alice", Username: "alice"}
+	group := telegram.Chat{ID: -300, Type: telegram.ChatTypeGroup, Title: "Readers"}
+
+	rec, err := recorder.New(path, "salt")
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	live := telegramtest.NewServer()
+	defer live.Close()
+
+	live.SendText(alice, "https://example.com/article #go")
+	live.SendText(alice, "/rnd")
+	live.SendChatText(alice, group, "https://example.com/shared")
+	live.SendChatText(alice, group, "/rnd@"+live.Bot.Username)
+
+	sent := run(t, live, rec)
+	if err := rec.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = f.Close() }()
+
+	replayed := telegramtest.NewServer()
+	defer replayed.Close()
+
+	if err := recorder.Read(f, func(u telegram.Update) error {
+		replayed.Queue(u)
+		return nil
+	}); err != nil {
+		t.Fatalf("Read: %v", err)
+	}
+
+	// the bot does the same for the pseudonyms: the private list and the
+	// list of the group stay apart
+	got := run(t, replayed, nil)
+	if len(got) != len(sent) || len(sent) != 4 {
+		t.Fatalf("replay sent %+v, want the same as %+v", got, sent)
+	}
+
+	chats := make(map[int]int)
+	for i := range sent {
+		if got[i].Text != sent[i].Text {
+			t.Errorf("reply %d = %q, want %q", i, got[i].Text, sent[i].Text)
+		}
+
+		if pseudonym, ok := chats[sent[i].ChatID]; ok && pseudonym != got[i].ChatID {
+			t.Errorf("reply %d is sent to %d, want %d as before", i, got[i].ChatID, pseudonym)
+		}
+		chats[sent[i].ChatID] = got[i].ChatID
+	}
+
+	if chats[alice.ID] == alice.ID || chats[group.ID] >= 0 || chats[group.ID] == group.ID {
+		t.Errorf("replies are sent to %v, want pseudonyms of the chats", chats)
+	}
+}
@@ -0,0 +1,12 @@
+package repository
+
+import "context"
+
+// Bans stores Telegram users banned by admins.
+type Bans interface {
+	Ban(ctx context.Context, userID int) error
+	Unban(ctx context.Context, userID int) error
+	IsBanned(ctx context.Context, userID int) (bool, error)
+	// Banned returns ids of banned users in ascending order.
+	Banned(ctx context.Context) ([]int, error)
+}
@@ -0,0 +1,90 @@
+package repository
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+)
+
+var (
+	ErrInviteNotFound = errors.New("invite not found")
+	ErrNotMember      = errors.New("not a member of the collection")
+	ErrUnknownRole    = errors.New("unknown role")
+)
+
+// Collections stores reading lists shared by several users.
+type Collections interface {
+	// CreateCollection saves the collection and makes its owner a member.
+	CreateCollection(ctx context.Context, c *Collection) error
+	// CreateInvite returns a code which lets users join the collection with
+	// the role. The same code is returned for the same role.
+	CreateInvite(ctx context.Context, collectionID string, role Role) (string, error)
+	// Join adds the user to the collection the code was created for. A member
+	// who joins again keeps the higher of the roles.
+	Join(ctx context.Context, code string, username string) (*Membership, error)
+	// Memberships returns collections the user is a member of.
+	Memberships(ctx context.Context, username string) ([]*Membership, error)
+	// Membership returns ErrNotMember unless the user is a member of the collection.
+	Membership(ctx context.Context, collectionID string, username string) (*Membership, error)
+}
+
+type Collection struct {
+	ID   string
+	Name string
+	// Owner is the username of the user who created the collection.
+	Owner string
+}
+
+// PagesOwner returns the key collection pages are stored under. It contains
+// '-', so it never clashes with a Telegram username.
+func (c *Collection) PagesOwner() string {
+	return "collection-" + c.ID
+}
+
+type Membership struct {
+	Collection *Collection
+	Role       Role
+}
+
+type Role string
+
+const (
+	RoleOwner  Role = "owner"
+	RoleEditor Role = "editor"
+	RoleViewer Role = "viewer"
+)
+
+func ParseRole(s string) (Role, error) {
+	switch r := Role(s); r {
+	case RoleOwner, RoleEditor, RoleViewer:
+		return r, nil
+	default:
+		return "", ErrUnknownRole
+	}
+}
+
+// CanEdit reports whether the role allows to add and remove pages.
+func (r Role) CanEdit() bool {
+	return r == RoleOwner || r == RoleEditor
+}
+
+// Max returns the role with more permissions.
+func (r Role) Max(other Role) Role {
+	rank := map[Role]int{RoleViewer: 1, RoleEditor: 2, RoleOwner: 3}
+	if rank[other] > rank[r] {
+		return other
+	}
+
+	return r
+}
+
+// NewID returns a random identifier suitable for collection ids and invite codes.
+func NewID() (string, error) {
+	b := make([]byte, 6)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(b), nil
+}
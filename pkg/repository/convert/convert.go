@@ -0,0 +1,209 @@
+package convert
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"telegrambot/internal/e"
+	"telegrambot/pkg/repository"
+)
+
+// progressEvery is how many pages are copied between progress reports
+// within a list.
+const progressEvery = 500
+
+type Options struct {
+	// Checkpoint is a file the owners whose lists are copied are appended
+	// to. Lists found in it are skipped, so an interrupted conversion
+	// continues where it stopped. No checkpoint is kept if it is empty.
+	Checkpoint string
+	// Progress is called after every list and periodically within big ones.
+	Progress func(p Progress)
+}
+
+type Progress struct {
+	// Owner is the list being copied.
+	Owner       string
+	Owners      int
+	TotalOwners int
+	// Copied is the number of pages saved to the destination.
+	Copied int
+	// Skipped is the number of pages the destination already had.
+	Skipped int
+	// Resumed is the number of lists copied by a previous run.
+	Resumed int
+}
+
+// Convert copies users, bans and pages from src to dst. Pages the
+// destination already has are kept as they are, so running it again is
+// safe. Collection memberships are not copied.
+func Convert(ctx context.Context, src repository.Repository, dst repository.Repository, opts Options) (progress Progress, err error) {
+	defer func() {
+		err = e.WrapIfErr("can't convert repository", err)
+	}()
+
+	enum, ok := src.(repository.Enumerator)
+	if !ok {
+		return progress, errors.New("source repository can't be enumerated")
+	}
+
+	if err := copyUsers(ctx, src, dst); err != nil {
+		return progress, err
+	}
+
+	owners, err := enum.Owners(ctx)
+	if err != nil {
+		return progress, err
+	}
+	progress.TotalOwners = len(owners)
+
+	done, err := readCheckpoint(opts.Checkpoint)
+	if err != nil {
+		return progress, err
+	}
+
+	report := func() {
+		if opts.Progress != nil {
+			opts.Progress(progress)
+		}
+	}
+
+	for _, owner := range owners {
+		if err := ctx.Err(); err != nil {
+			return progress, err
+		}
+
+		progress.Owner = owner
+
+		if done[owner] {
+			progress.Owners++
+			progress.Resumed++
+			continue
+		}
+
+		n := 0
+		err := enum.EachPage(ctx, owner, func(p *repository.Page) error {
+			copied, err := copyPage(ctx, dst, p)
+			if err != nil {
+				return err
+			}
+
+			if copied {
+				progress.Copied++
+			} else {
+				progress.Skipped++
+			}
+
+			if n++; n%progressEvery == 0 {
+				report()
+			}
+
+			return ctx.Err()
+		})
+		if err != nil {
+			return progress, err
+		}
+
+		if err := appendCheckpoint(opts.Checkpoint, owner); err != nil {
+			return progress, err
+		}
+
+		progress.Owners++
+		report()
+	}
+
+	return progress, nil
+}
+
+func copyUsers(ctx context.Context, src repository.Repository, dst repository.Repository) error {
+	users, err := src.Users(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, u := range users {
+		if err := dst.TouchUser(ctx, u); err != nil {
+			return err
+		}
+
+		if u.IsBlocked() {
+			if err := dst.MarkBlocked(ctx, u.ID, u.BlockedAt); err != nil {
+				return err
+			}
+		}
+	}
+
+	banned, err := src.Banned(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, id := range banned {
+		if err := dst.Ban(ctx, id); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// copyPage saves the page unless the destination has a page with its url.
+func copyPage(ctx context.Context, dst repository.Repository, p *repository.Page) (bool, error) {
+	_, err := dst.Get(ctx, p.Username, p.URL)
+	if err == nil {
+		return false, nil
+	}
+	if !errors.Is(err, repository.ErrPageNotFound) {
+		return false, err
+	}
+
+	return true, dst.Save(ctx, p)
+}
+
+func readCheckpoint(path string) (map[string]bool, error) {
+	done := make(map[string]bool)
+	if path == "" {
+		return done, nil
+	}
+
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return done, nil
+	}
+	if err != nil {
+		return nil, e.Wrap("can't read checkpoint", err)
+	}
+	defer func() { _ = f.Close() }()
+
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		if owner := strings.TrimSpace(s.Text()); owner != "" {
+			done[owner] = true
+		}
+	}
+
+	return done, e.WrapIfErr("can't read checkpoint", s.Err())
+}
+
+func appendCheckpoint(path string, owner string) (err error) {
+	if path == "" {
+		return nil
+	}
+
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return e.Wrap("can't save checkpoint", err)
+	}
+	defer func() {
+		if cErr := f.Close(); cErr != nil && err == nil {
+			err = e.Wrap("can't save checkpoint", cErr)
+		}
+	}()
+
+	_, err = fmt.Fprintln(f, owner)
+
+	return e.WrapIfErr("can't save checkpoint", err)
+}
@@ -0,0 +1,186 @@
+package convert
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"telegrambot/pkg/repository"
+	"telegrambot/pkg/repository/memory"
+	"testing"
+	"time"
+)
+
+var base = time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+// newSource returns a repository with two pages of alice, three of bob and
+// a banned user.
+func newSource(t *testing.T) *memory.RepositoryMemory {
+	t.Helper()
+
+	ctx := context.Background()
+	src := memory.New()
+
+	pages := map[string][]string{
+		"alice": {"https://example.com/a1", "https://example.com/a2"},
+		"bob":   {"https://example.com/b1", "https://example.com/b2", "https://example.com/b3"},
+	}
+	for owner, urls := range pages {
+		for _, u := range urls {
+			if err := src.Save(ctx, &repository.Page{URL: u, Username: owner, CreatedAt: base}); err != nil {
+				t.Fatalf("Save: %v", err)
+			}
+		}
+	}
+
+	if err := src.TouchUser(ctx, &repository.User{ID: 1, Username: "alice", LastSeen: base}); err != nil {
+		t.Fatalf("TouchUser: %v", err)
+	}
+	if err := src.Ban(ctx, 2); err != nil {
+		t.Fatalf("Ban: %v", err)
+	}
+
+	return src
+}
+
+func count(t *testing.T, r repository.Repository, owner string) int {
+	t.Helper()
+
+	n, err := r.Count(context.Background(), owner)
+	if err != nil {
+		t.Fatalf("Count(%s): %v", owner, err)
+	}
+
+	return n
+}
+
+func checkpoint(t *testing.T, path string) string {
+	t.Helper()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read checkpoint: %v", err)
+	}
+
+	return string(data)
+}
+
+func TestConvert(t *testing.T) {
+	ctx := context.Background()
+	src, dst := newSource(t), memory.New()
+
+	progress, err := Convert(ctx, src, dst, Options{})
+	if err != nil {
+		t.Fatalf("Convert: %v", err)
+	}
+	if progress.Copied != 5 || progress.Skipped != 0 || progress.Owners != 2 || progress.TotalOwners != 2 {
+		t.Errorf("progress = %+v, want 5 pages of 2 lists copied", progress)
+	}
+
+	if count(t, dst, "alice") != 2 || count(t, dst, "bob") != 3 {
+		t.Error("pages are not copied")
+	}
+
+	users, err := dst.Users(ctx)
+	if err != nil {
+		t.Fatalf("Users: %v", err)
+	}
+	if len(users) != 1 || users[0].Username != "alice" {
+		t.Errorf("users = %+v, want alice", users)
+	}
+
+	if banned, err := dst.IsBanned(ctx, 2); err != nil || !banned {
+		t.Errorf("IsBanned = %v, %v, want the ban copied", banned, err)
+	}
+
+	// pages copied before are skipped
+	progress, err = Convert(ctx, src, dst, Options{})
+	if err != nil {
+		t.Fatalf("second Convert: %v", err)
+	}
+	if progress.Copied != 0 || progress.Skipped != 5 {
+		t.Errorf("progress of the second run = %+v, want all 5 pages skipped", progress)
+	}
+	if count(t, dst, "bob") != 3 {
+		t.Error("second run duplicated pages")
+	}
+}
+
+func TestConvertSkipsCheckpointedOwners(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "checkpoint")
+	if err := os.WriteFile(path, []byte("alice\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	src, dst := newSource(t), memory.New()
+
+	progress, err := Convert(context.Background(), src, dst, Options{Checkpoint: path})
+	if err != nil {
+		t.Fatalf("Convert: %v", err)
+	}
+
+	if progress.Resumed != 1 || progress.Copied != 3 || progress.Owners != 2 {
+		t.Errorf("progress = %+v, want alice resumed and 3 pages of bob copied", progress)
+	}
+	if count(t, dst, "alice") != 0 {
+		t.Error("list in the checkpoint is copied again")
+	}
+	if got := checkpoint(t, path); got != "alice\nbob\n" {
+		t.Errorf("checkpoint = %q, want both lists", got)
+	}
+}
+
+// cancelling cancels the conversion after a number of pages are saved.
+type cancelling struct {
+	repository.Repository
+	saves  int
+	cancel context.CancelFunc
+}
+
+func (c *cancelling) Save(ctx context.Context, p *repository.Page) error {
+	if err := c.Repository.Save(ctx, p); err != nil {
+		return err
+	}
+
+	if c.saves--; c.saves == 0 {
+		c.cancel()
+	}
+
+	return nil
+}
+
+func TestConvertCancelled(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "checkpoint")
+	src, dst := newSource(t), memory.New()
+
+	// the list of alice is copied, the one of bob only in part
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	_, err := Convert(ctx, src, &cancelling{Repository: dst, saves: 3, cancel: cancel}, Options{Checkpoint: path})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("Convert: err = %v, want %v", err, context.Canceled)
+	}
+
+	if got := checkpoint(t, path); got != "alice\n" {
+		t.Errorf("checkpoint = %q, want only the list copied to the end", got)
+	}
+	if count(t, dst, "bob") != 1 {
+		t.Fatalf("bob has %d pages, want the one copied before cancelling", count(t, dst, "bob"))
+	}
+
+	progress, err := Convert(context.Background(), src, dst, Options{Checkpoint: path})
+	if err != nil {
+		t.Fatalf("resumed Convert: %v", err)
+	}
+
+	if progress.Resumed != 1 || progress.Skipped != 1 || progress.Copied != 2 {
+		t.Errorf("progress = %+v, want alice resumed and bob finished", progress)
+	}
+	if count(t, dst, "alice") != 2 || count(t, dst, "bob") != 3 {
+		t.Error("pages are missing after resuming")
+	}
+	if got := checkpoint(t, path); got != "alice\nbob\n" {
+		t.Errorf("checkpoint = %q, want both lists", got)
+	}
+}
@@ -0,0 +1,15 @@
+package repository
+
+import "context"
+
+// Enumerator is implemented by repositories which can list everything they
+// store, so that they can be copied to another backend.
+type Enumerator interface {
+	// Owners returns the keys of all lists which have pages, in ascending
+	// order. Besides usernames they include group chats and collections.
+	Owners(ctx context.Context) ([]string, error)
+	// EachPage calls fn for every page of the owner, including read and
+	// removed ones, without loading all of them at once. It stops at the
+	// first error returned by fn and returns it.
+	EachPage(ctx context.Context, owner string, fn func(p *Page) error) error
+}
@@ -0,0 +1,108 @@
+package files
+
+import (
+	"context"
+	"encoding/gob"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"telegrambot/internal/e"
+)
+
+// bansFile is stored next to user directories.
+const bansFile = ".banned"
+
+func (r RepositoryFiles) Ban(ctx context.Context, userID int) error {
+	r.bansMu.Lock()
+	defer r.bansMu.Unlock()
+
+	bans, err := r.bans(ctx)
+	if err != nil {
+		return e.Wrap("can't ban user", err)
+	}
+
+	bans[userID] = true
+
+	if err := r.saveBans(bans); err != nil {
+		return e.Wrap("can't ban user", err)
+	}
+
+	return nil
+}
+
+func (r RepositoryFiles) Unban(ctx context.Context, userID int) error {
+	r.bansMu.Lock()
+	defer r.bansMu.Unlock()
+
+	bans, err := r.bans(ctx)
+	if err != nil {
+		return e.Wrap("can't unban user", err)
+	}
+
+	delete(bans, userID)
+
+	if err := r.saveBans(bans); err != nil {
+		return e.Wrap("can't unban user", err)
+	}
+
+	return nil
+}
+
+func (r RepositoryFiles) IsBanned(ctx context.Context, userID int) (bool, error) {
+	bans, err := r.bans(ctx)
+	if err != nil {
+		return false, e.Wrap("can't check if user is banned", err)
+	}
+
+	_, ok := bans[userID]
+
+	return ok, nil
+}
+
+func (r RepositoryFiles) Banned(ctx context.Context) ([]int, error) {
+	bans, err := r.bans(ctx)
+	if err != nil {
+		return nil, e.Wrap("can't get banned users", err)
+	}
+
+	ids := make([]int, 0, len(bans))
+	for id := range bans {
+		ids = append(ids, id)
+	}
+
+	sort.Ints(ids)
+
+	return ids, nil
+}
+
+func (r RepositoryFiles) bans(ctx context.Context) (bans map[int]bool, err error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	bans = make(map[int]bool)
+
+	file, err := os.Open(filepath.Join(r.basePath, bansFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return bans, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if cErr := file.Close(); cErr != nil && err == nil {
+			err = cErr
+		}
+	}()
+
+	if err := gob.NewDecoder(file).Decode(&bans); err != nil {
+		return nil, err
+	}
+
+	return bans, nil
+}
+
+func (r RepositoryFiles) saveBans(bans map[int]bool) error {
+	return writeFile(r.basePath, bansFile, bans)
+}
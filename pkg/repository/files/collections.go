@@ -0,0 +1,203 @@
+package files
+
+import (
+	"context"
+	"encoding/gob"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"telegrambot/internal/e"
+	"telegrambot/pkg/repository"
+)
+
+// collectionsDir is stored next to user directories. Its name starts with
+// a dot, so it never clashes with a username.
+const collectionsDir = ".collections"
+
+type collectionFile struct {
+	Collection repository.Collection
+	Members    map[string]repository.Role
+	// Invites maps invite codes to roles.
+	Invites map[string]repository.Role
+}
+
+func (r RepositoryFiles) CreateCollection(ctx context.Context, c *repository.Collection) error {
+	cf := &collectionFile{
+		Collection: *c,
+		Members:    map[string]repository.Role{c.Owner: repository.RoleOwner},
+		Invites:    map[string]repository.Role{},
+	}
+
+	if err := r.saveCollection(ctx, cf); err != nil {
+		return e.Wrap("can't create collection", err)
+	}
+
+	return nil
+}
+
+func (r RepositoryFiles) CreateInvite(ctx context.Context, collectionID string, role repository.Role) (code string, err error) {
+	defer func() {
+		err = e.WrapIfErr("can't create invite", err)
+	}()
+
+	r.collectionsMu.Lock()
+	defer r.collectionsMu.Unlock()
+
+	cf, err := r.collection(ctx, collectionID)
+	if err != nil {
+		return "", err
+	}
+
+	for existing, invited := range cf.Invites {
+		if invited == role {
+			return existing, nil
+		}
+	}
+
+	if code, err = repository.NewID(); err != nil {
+		return "", err
+	}
+
+	cf.Invites[code] = role
+
+	return code, r.saveCollection(ctx, cf)
+}
+
+func (r RepositoryFiles) Join(ctx context.Context, code string, username string) (m *repository.Membership, err error) {
+	defer func() {
+		if !errors.Is(err, repository.ErrInviteNotFound) {
+			err = e.WrapIfErr("can't join collection", err)
+		}
+	}()
+
+	r.collectionsMu.Lock()
+	defer r.collectionsMu.Unlock()
+
+	all, err := r.collections(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, cf := range all {
+		role, ok := cf.Invites[code]
+		if !ok {
+			continue
+		}
+
+		if current, ok := cf.Members[username]; ok {
+			role = role.Max(current)
+		}
+
+		cf.Members[username] = role
+		if err := r.saveCollection(ctx, cf); err != nil {
+			return nil, err
+		}
+
+		collection := cf.Collection
+		return &repository.Membership{Collection: &collection, Role: role}, nil
+	}
+
+	return nil, repository.ErrInviteNotFound
+}
+
+func (r RepositoryFiles) Memberships(ctx context.Context, username string) ([]*repository.Membership, error) {
+	all, err := r.collections(ctx)
+	if err != nil {
+		return nil, e.Wrap("can't get memberships", err)
+	}
+
+	var res []*repository.Membership
+	for _, cf := range all {
+		if role, ok := cf.Members[username]; ok {
+			collection := cf.Collection
+			res = append(res, &repository.Membership{Collection: &collection, Role: role})
+		}
+	}
+
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].Collection.Name < res[j].Collection.Name
+	})
+
+	return res, nil
+}
+
+func (r RepositoryFiles) Membership(ctx context.Context, collectionID string, username string) (*repository.Membership, error) {
+	cf, err := r.collection(ctx, collectionID)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, repository.ErrNotMember
+	}
+	if err != nil {
+		return nil, e.Wrap("can't get membership", err)
+	}
+
+	role, ok := cf.Members[username]
+	if !ok {
+		return nil, repository.ErrNotMember
+	}
+
+	return &repository.Membership{Collection: &cf.Collection, Role: role}, nil
+}
+
+func (r RepositoryFiles) collections(ctx context.Context) ([]*collectionFile, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	entries, err := os.ReadDir(filepath.Join(r.basePath, collectionsDir))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]*collectionFile, 0, len(entries))
+	for _, entry := range entries {
+		if strings.HasPrefix(entry.Name(), ".") {
+			// a collection being written
+			continue
+		}
+
+		cf, err := r.collection(ctx, entry.Name())
+		if err != nil {
+			return nil, err
+		}
+
+		res = append(res, cf)
+	}
+
+	return res, nil
+}
+
+func (r RepositoryFiles) collection(ctx context.Context, id string) (cf *collectionFile, err error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	file, err := os.Open(filepath.Join(r.basePath, collectionsDir, id))
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if cErr := file.Close(); cErr != nil && err == nil {
+			err = cErr
+		}
+	}()
+
+	cf = &collectionFile{}
+	if err := gob.NewDecoder(file).Decode(cf); err != nil {
+		return nil, err
+	}
+
+	return cf, nil
+}
+
+func (r RepositoryFiles) saveCollection(ctx context.Context, cf *collectionFile) (err error) {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	return writeFile(filepath.Join(r.basePath, collectionsDir), cf.Collection.ID, cf)
+}
@@ -4,10 +4,12 @@ import (
 	"context"
 	"encoding/gob"
 	"errors"
-	"fmt"
 	"math/rand"
 	"os"
 	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
 	"telegrambot/internal/e"
 	"telegrambot/pkg/repository"
 	"time"
@@ -15,10 +17,28 @@ import (
 
 type RepositoryFiles struct {
 	basePath string
+	rnd      *rand.Rand
+	// collectionsMu guards members and invites of collections.
+	collectionsMu *sync.Mutex
+	// bansMu guards the bans file.
+	bansMu *sync.Mutex
+	// usersMu guards the users file, which is updated on every event.
+	usersMu *sync.Mutex
 }
 
 func New(basePath string) RepositoryFiles {
-	return RepositoryFiles{basePath: basePath}
+	return NewWithRand(basePath, repository.NewRand(nil))
+}
+
+// NewWithRand creates repository which uses rnd to pick pages.
+func NewWithRand(basePath string, rnd *rand.Rand) RepositoryFiles {
+	return RepositoryFiles{
+		basePath:      basePath,
+		rnd:           rnd,
+		collectionsMu: &sync.Mutex{},
+		bansMu:        &sync.Mutex{},
+		usersMu:       &sync.Mutex{},
+	}
 }
 
 const defaultPerm = 0774
@@ -28,9 +48,7 @@ func (r RepositoryFiles) Save(ctx context.Context, page *repository.Page) (err e
 		err = e.WrapIfErr("can't save page", err)
 	}()
 
-	fPath := filepath.Join(r.basePath, page.Username)
-
-	if err := os.MkdirAll(fPath, defaultPerm); err != nil {
+	if err := ctx.Err(); err != nil {
 		return err
 	}
 
@@ -39,81 +57,518 @@ func (r RepositoryFiles) Save(ctx context.Context, page *repository.Page) (err e
 		return err
 	}
 
-	fPath = filepath.Join(fPath, fName)
+	if page.CreatedAt.IsZero() {
+		created := *page
+		created.CreatedAt = time.Now()
+		page = &created
+	}
+
+	return writeFile(filepath.Join(r.basePath, page.Username), fName, page)
+}
+
+func (r RepositoryFiles) PickRandom(ctx context.Context, username string, opts repository.PickOptions) (page *repository.Page, err error) {
+	defer func() {
+		err = e.WrapIfErr("can't pick random page", err)
+	}()
+
+	pages, err := r.pages(ctx, username)
+	if err != nil {
+		return nil, err
+	}
+
+	candidates := make([]*repository.Page, 0, len(pages))
+	for _, p := range pages {
+		if p.IsDeleted() || p.IsRead() || p.IsSnoozed() || (opts.Tag != "" && !p.HasTag(opts.Tag)) {
+			continue
+		}
+
+		candidates = append(candidates, p)
+	}
+
+	return repository.Choose(candidates, opts, r.rnd, time.Now())
+}
 
-	file, err := os.Create(fPath)
+func (r RepositoryFiles) Remove(ctx context.Context, p *repository.Page) (err error) {
+	defer func() {
+		err = e.WrapIfErr("can't remove page", err)
+	}()
+
+	page, err := r.Get(ctx, p.Username, p.URL)
+	if errors.Is(err, repository.ErrPageNotFound) {
+		return nil
+	}
 	if err != nil {
 		return err
 	}
+
+	page.DeletedAt = time.Now()
+
+	return r.Save(ctx, page)
+}
+
+func (r RepositoryFiles) IsExists(ctx context.Context, p *repository.Page) (bool, error) {
+	page, err := r.Get(ctx, p.Username, p.URL)
+	switch {
+	case errors.Is(err, repository.ErrPageNotFound):
+		return false, nil
+	case err != nil:
+		return false, e.Wrap("can't check if page exists", err)
+	}
+
+	return !page.IsRead(), nil
+}
+
+func (r RepositoryFiles) Count(ctx context.Context, username string) (int, error) {
+	pages, err := r.pages(ctx, username)
+	if err != nil {
+		return 0, e.Wrap("can't count pages", err)
+	}
+
+	count := 0
+	for _, p := range pages {
+		if !p.IsDeleted() && !p.IsRead() {
+			count++
+		}
+	}
+
+	return count, nil
+}
+
+func (r RepositoryFiles) MarkRead(ctx context.Context, p *repository.Page, at time.Time) (err error) {
 	defer func() {
-		err = e.WrapIfErr("can't create", file.Close())
+		err = e.WrapIfErr("can't mark page as read", err)
 	}()
 
-	if err = gob.NewEncoder(file).Encode(page); err != nil {
+	page, err := r.Get(ctx, p.Username, p.URL)
+	if err != nil {
 		return err
 	}
 
-	return nil
+	page.ReadAt = at
+
+	return r.Save(ctx, page)
 }
 
-func (r RepositoryFiles) PickRandom(ctx context.Context, username string) (page *repository.Page, err error) {
+func (r RepositoryFiles) Snooze(ctx context.Context, p *repository.Page, until time.Time) (err error) {
 	defer func() {
-		err = e.WrapIfErr("can't pick random page", err)
+		err = e.WrapIfErr("can't snooze page", err)
 	}()
 
-	fPath := filepath.Join(r.basePath, username)
+	page, err := r.get(ctx, p.Username, p.URL)
+	switch {
+	case errors.Is(err, repository.ErrPageNotFound):
+		snoozed := *p
+		page = &snoozed
+	case err != nil:
+		return err
+	}
 
-	files, err := os.ReadDir(fPath)
+	page.ChatID = p.ChatID
+	page.ResurfaceAt = until
+	page.ReadAt = time.Time{}
+	page.DeletedAt = time.Time{}
+
+	return r.Save(ctx, page)
+}
+
+func (r RepositoryFiles) DueSnoozed(ctx context.Context, now time.Time, retryAt time.Time) (pages []*repository.Page, err error) {
+	defer func() {
+		err = e.WrapIfErr("can't get due snoozed pages", err)
+	}()
+
+	users, err := r.usernames(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, username := range users {
+		userPages, err := r.pages(ctx, username)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, p := range userPages {
+			if p.IsDeleted() || !p.IsSnoozed() || p.ResurfaceAt.After(now) {
+				continue
+			}
+
+			postponed := *p
+			postponed.ResurfaceAt = retryAt
+			if err := r.Save(ctx, &postponed); err != nil {
+				return nil, err
+			}
+
+			pages = append(pages, p)
+		}
+	}
+
+	return pages, nil
+}
+
+func (r RepositoryFiles) Unsnooze(ctx context.Context, p *repository.Page) (err error) {
+	defer func() {
+		err = e.WrapIfErr("can't unsnooze page", err)
+	}()
+
+	page, err := r.get(ctx, p.Username, p.URL)
+	if errors.Is(err, repository.ErrPageNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	page.ResurfaceAt = time.Time{}
+
+	return r.Save(ctx, page)
+}
+
+func (r RepositoryFiles) Get(ctx context.Context, username string, url string) (*repository.Page, error) {
+	page, err := r.get(ctx, username, url)
+	if err != nil {
+		return nil, err
+	}
+
+	if page.IsDeleted() {
+		return nil, repository.ErrPageNotFound
+	}
+
+	return page, nil
+}
+
+// get returns page regardless of whether it is in the trash.
+func (r RepositoryFiles) get(ctx context.Context, username string, url string) (*repository.Page, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, e.Wrap("can't get page", err)
+	}
+
+	fName, err := fileName(&repository.Page{URL: url, Username: username})
+	if err != nil {
+		return nil, e.Wrap("can't get page", err)
+	}
+
+	page, err := r.decodePage(filepath.Join(r.basePath, username, fName))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, repository.ErrPageNotFound
+	}
+	if err != nil {
+		return nil, e.Wrap("can't get page", err)
+	}
+
+	return page, nil
+}
+
+func (r RepositoryFiles) UpdateReview(ctx context.Context, p *repository.Page) (err error) {
+	defer func() {
+		if !errors.Is(err, repository.ErrPageNotFound) {
+			err = e.WrapIfErr("can't update review", err)
+		}
+	}()
+
+	page, err := r.Get(ctx, p.Username, p.URL)
+	if err != nil {
+		return err
+	}
+
+	page.Review = p.Review
+
+	return r.Save(ctx, page)
+}
+
+func (r RepositoryFiles) PickDue(ctx context.Context, username string, now time.Time) (page *repository.Page, err error) {
+	defer func() {
+		if !errors.Is(err, repository.ErrNoSavedPages) {
+			err = e.WrapIfErr("can't pick due page", err)
+		}
+	}()
+
+	pages, err := r.pages(ctx, username)
 	if err != nil {
 		return nil, err
 	}
 
-	if len(files) == 0 {
+	for _, p := range pages {
+		if p.IsDeleted() || p.IsRead() || p.IsSnoozed() || !p.Review.IsDue(now) {
+			continue
+		}
+
+		if page == nil || dueBefore(p, page) {
+			page = p
+		}
+	}
+
+	if page == nil {
 		return nil, repository.ErrNoSavedPages
 	}
 
-	rand.New(rand.NewSource(time.Now().UnixNano()))
+	return page, nil
+}
+
+func (r RepositoryFiles) Stats(ctx context.Context, username string, now time.Time) (*repository.Stats, error) {
+	pages, err := r.pages(ctx, username)
+	if err != nil {
+		return nil, e.Wrap("can't get stats", err)
+	}
+
+	return repository.CalcStats(pages, now), nil
+}
+
+func (r RepositoryFiles) LastRemoved(ctx context.Context, username string, since time.Time) (page *repository.Page, err error) {
+	pages, err := r.pages(ctx, username)
+	if err != nil {
+		return nil, e.Wrap("can't get last removed page", err)
+	}
+
+	for _, p := range pages {
+		if p.RemovedAt().Before(since) {
+			continue
+		}
+
+		if page == nil || p.RemovedAt().After(page.RemovedAt()) {
+			page = p
+		}
+	}
+
+	if page == nil {
+		return nil, repository.ErrPageNotFound
+	}
+
+	return page, nil
+}
+
+func (r RepositoryFiles) Restore(ctx context.Context, p *repository.Page) (err error) {
+	defer func() {
+		err = e.WrapIfErr("can't restore page", err)
+	}()
+
+	page, err := r.get(ctx, p.Username, p.URL)
+	if errors.Is(err, repository.ErrPageNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	page.DeletedAt = time.Time{}
+	page.ReadAt = time.Time{}
+
+	return r.Save(ctx, page)
+}
+
+func (r RepositoryFiles) Trash(ctx context.Context, username string) ([]*repository.Page, error) {
+	pages, err := r.pages(ctx, username)
+	if err != nil {
+		return nil, e.Wrap("can't get trash", err)
+	}
+
+	var trash []*repository.Page
+	for _, p := range pages {
+		if p.IsDeleted() {
+			trash = append(trash, p)
+		}
+	}
+
+	sort.Slice(trash, func(i, j int) bool {
+		return trash[i].DeletedAt.After(trash[j].DeletedAt)
+	})
+
+	return trash, nil
+}
+
+func (r RepositoryFiles) Purge(ctx context.Context, before time.Time) (n int, err error) {
+	defer func() {
+		err = e.WrapIfErr("can't purge pages", err)
+	}()
+
+	users, err := r.usernames(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, username := range users {
+		pages, err := r.pages(ctx, username)
+		if err != nil {
+			return n, err
+		}
+
+		for _, p := range pages {
+			if !p.IsDeleted() || !p.DeletedAt.Before(before) {
+				continue
+			}
+
+			fName, err := fileName(p)
+			if err != nil {
+				return n, err
+			}
+
+			if err := os.Remove(filepath.Join(r.basePath, username, fName)); err != nil {
+				return n, err
+			}
+
+			n++
+		}
+	}
+
+	return n, nil
+}
+
+func (r RepositoryFiles) Pages(ctx context.Context, username string) ([]*repository.Page, error) {
+	pages, err := r.pages(ctx, username)
+	if err != nil {
+		return nil, e.Wrap("can't get pages", err)
+	}
+
+	sort.Slice(pages, func(i, j int) bool {
+		return pages[i].CreatedAt.After(pages[j].CreatedAt)
+	})
+
+	return pages, nil
+}
+
+// dueBefore reports whether a should be reviewed before b.
+func dueBefore(a, b *repository.Page) bool {
+	switch {
+	case a.Review.DueAt.IsZero() != b.Review.DueAt.IsZero():
+		return b.Review.DueAt.IsZero()
+	case !a.Review.DueAt.Equal(b.Review.DueAt):
+		return a.Review.DueAt.Before(b.Review.DueAt)
+	default:
+		return a.CreatedAt.Before(b.CreatedAt)
+	}
+}
+
+// usernames returns users which have saved pages.
+func (r RepositoryFiles) usernames(ctx context.Context) ([]string, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
 
-	n := rand.Intn(len(files))
+	entries, err := os.ReadDir(r.basePath)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
 
-	file := files[n]
+	var users []string
+	for _, entry := range entries {
+		if entry.IsDir() && !strings.HasPrefix(entry.Name(), ".") {
+			users = append(users, entry.Name())
+		}
+	}
 
-	return r.decodePage(filepath.Join(fPath, file.Name()))
+	return users, nil
 }
 
-func (r RepositoryFiles) Remove(ctx context.Context, p *repository.Page) error {
-	fName, err := fileName(p)
+// pages decodes all pages saved by the user. A user without pages has no
+// directory, which is not an error.
+func (r RepositoryFiles) pages(ctx context.Context, username string) ([]*repository.Page, error) {
+	var pages []*repository.Page
+
+	err := r.eachPage(ctx, username, func(p *repository.Page) error {
+		pages = append(pages, p)
+		return nil
+	})
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
 	if err != nil {
-		return e.WrapIfErr("can't remove file", err)
+		return nil, err
 	}
 
-	fPath := filepath.Join(r.basePath, p.Username, fName)
+	return pages, nil
+}
+
+// eachPage decodes pages saved by the user one by one in file name order.
+func (r RepositoryFiles) eachPage(ctx context.Context, username string, fn func(p *repository.Page) error) error {
+	fPath := filepath.Join(r.basePath, username)
+
+	files, err := os.ReadDir(fPath)
+	if err != nil {
+		return err
+	}
 
-	if err := os.Remove(fPath); err != nil {
-		msg := fmt.Sprintf("can't remove file: %s", fPath)
-		return e.WrapIfErr(msg, err)
+	for _, file := range files {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		if strings.HasPrefix(file.Name(), ".") {
+			// a page being written
+			continue
+		}
+
+		p, err := r.decodePage(filepath.Join(fPath, file.Name()))
+		if err != nil {
+			return err
+		}
+
+		if p.CreatedAt.IsZero() {
+			// pages saved before CreatedAt was introduced
+			info, err := file.Info()
+			if err != nil {
+				return err
+			}
+			p.CreatedAt = info.ModTime()
+		}
+
+		if err := fn(p); err != nil {
+			return err
+		}
 	}
 
 	return nil
 }
 
-func (r RepositoryFiles) IsExists(ctx context.Context, p *repository.Page) (bool, error) {
-	fName, err := fileName(p)
+// Owners returns the users directories, which are sorted by name.
+func (r RepositoryFiles) Owners(ctx context.Context) ([]string, error) {
+	owners, err := r.usernames(ctx)
 	if err != nil {
-		return false, e.Wrap("can't check if file exists", err)
+		return nil, e.Wrap("can't get owners", err)
 	}
 
-	fPath := filepath.Join(r.basePath, p.Username, fName)
+	return owners, nil
+}
 
-	switch _, err = os.Stat(fPath); {
-	case errors.Is(err, os.ErrNotExist):
-		return false, nil
-	case err != nil:
-		msg := fmt.Sprintf("can't check if file %s exists", fPath)
-		return false, e.Wrap(msg, err)
+// EachPage calls fn for every page of the owner. An owner without pages has
+// no directory, which is not an error.
+func (r RepositoryFiles) EachPage(ctx context.Context, owner string, fn func(p *repository.Page) error) error {
+	err := r.eachPage(ctx, owner, fn)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+
+	return err
+}
+
+// writeFile encodes v to a temporary file and renames it to name, so that
+// readers never see a partly written file.
+func writeFile(dir string, name string, v any) (err error) {
+	if err := os.MkdirAll(dir, defaultPerm); err != nil {
+		return err
+	}
+
+	file, err := os.CreateTemp(dir, "."+name+"-*")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = os.Remove(file.Name())
+		}
+	}()
+
+	if err := gob.NewEncoder(file).Encode(v); err != nil {
+		_ = file.Close()
+		return err
+	}
+
+	if err := file.Close(); err != nil {
+		return err
 	}
 
-	return true, nil
+	return os.Rename(file.Name(), filepath.Join(dir, name))
 }
 
 func (r RepositoryFiles) decodePage(filePath string) (page *repository.Page, err error) {
@@ -122,7 +577,9 @@ func (r RepositoryFiles) decodePage(filePath string) (page *repository.Page, err
 		return nil, e.Wrap("can't decode page", err)
 	}
 	defer func() {
-		err = e.WrapIfErr("can't close file", file.Close())
+		if cErr := file.Close(); cErr != nil && err == nil {
+			err = e.Wrap("can't close file", cErr)
+		}
 	}()
 
 	var p repository.Page
@@ -0,0 +1,13 @@
+package files
+
+import (
+	"telegrambot/pkg/repository"
+	"telegrambot/pkg/repository/repositorytest"
+	"testing"
+)
+
+func TestRepository(t *testing.T) {
+	repositorytest.Run(t, func(t *testing.T) repository.Repository {
+		return New(t.TempDir())
+	})
+}
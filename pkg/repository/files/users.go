@@ -0,0 +1,126 @@
+package files
+
+import (
+	"context"
+	"encoding/gob"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"telegrambot/internal/e"
+	"telegrambot/pkg/repository"
+	"time"
+)
+
+// usersFile is stored next to user directories.
+const usersFile = ".users"
+
+func (r RepositoryFiles) TouchUser(ctx context.Context, u *repository.User) error {
+	r.usersMu.Lock()
+	defer r.usersMu.Unlock()
+
+	users, err := r.users(ctx)
+	if err != nil {
+		return e.Wrap("can't save user", err)
+	}
+
+	now := u.LastSeen
+	if now.IsZero() {
+		now = time.Now()
+	}
+
+	saved, ok := users[u.ID]
+	if !ok {
+		saved = &repository.User{ID: u.ID, FirstSeen: now}
+		users[u.ID] = saved
+	}
+
+	saved.Username = u.Username
+	saved.FirstName = u.FirstName
+	saved.LastSeen = now
+	if u.ChatID != 0 {
+		saved.ChatID = u.ChatID
+		saved.BlockedAt = time.Time{}
+	}
+
+	if err := r.saveUsers(users); err != nil {
+		return e.Wrap("can't save user", err)
+	}
+
+	return nil
+}
+
+func (r RepositoryFiles) Users(ctx context.Context) ([]*repository.User, error) {
+	r.usersMu.Lock()
+	defer r.usersMu.Unlock()
+
+	users, err := r.users(ctx)
+	if err != nil {
+		return nil, e.Wrap("can't get users", err)
+	}
+
+	res := make([]*repository.User, 0, len(users))
+	for _, u := range users {
+		res = append(res, u)
+	}
+
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].ID < res[j].ID
+	})
+
+	return res, nil
+}
+
+func (r RepositoryFiles) MarkBlocked(ctx context.Context, userID int, at time.Time) error {
+	r.usersMu.Lock()
+	defer r.usersMu.Unlock()
+
+	users, err := r.users(ctx)
+	if err != nil {
+		return e.Wrap("can't mark user as blocked", err)
+	}
+
+	u, ok := users[userID]
+	if !ok {
+		return nil
+	}
+
+	u.BlockedAt = at
+
+	if err := r.saveUsers(users); err != nil {
+		return e.Wrap("can't mark user as blocked", err)
+	}
+
+	return nil
+}
+
+func (r RepositoryFiles) users(ctx context.Context) (users map[int]*repository.User, err error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	users = make(map[int]*repository.User)
+
+	file, err := os.Open(filepath.Join(r.basePath, usersFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return users, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if cErr := file.Close(); cErr != nil && err == nil {
+			err = cErr
+		}
+	}()
+
+	if err := gob.NewDecoder(file).Decode(&users); err != nil {
+		return nil, err
+	}
+
+	return users, nil
+}
+
+func (r RepositoryFiles) saveUsers(users map[int]*repository.User) error {
+	return writeFile(r.basePath, usersFile, users)
+}
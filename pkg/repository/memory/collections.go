@@ -0,0 +1,123 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"telegrambot/internal/e"
+	"telegrambot/pkg/repository"
+)
+
+type collection struct {
+	collection repository.Collection
+	members    map[string]repository.Role
+	// invites maps invite codes to roles.
+	invites map[string]repository.Role
+}
+
+func (r *RepositoryMemory) CreateCollection(ctx context.Context, c *repository.Collection) error {
+	if err := r.lock(ctx); err != nil {
+		return err
+	}
+	defer r.mu.Unlock()
+
+	r.collections[c.ID] = &collection{
+		collection: *c,
+		members:    map[string]repository.Role{c.Owner: repository.RoleOwner},
+		invites:    map[string]repository.Role{},
+	}
+
+	return nil
+}
+
+func (r *RepositoryMemory) CreateInvite(ctx context.Context, collectionID string, role repository.Role) (string, error) {
+	if err := r.lock(ctx); err != nil {
+		return "", err
+	}
+	defer r.mu.Unlock()
+
+	c, ok := r.collections[collectionID]
+	if !ok {
+		return "", e.Wrap("can't create invite", repository.ErrNotMember)
+	}
+
+	for code, invited := range c.invites {
+		if invited == role {
+			return code, nil
+		}
+	}
+
+	code, err := repository.NewID()
+	if err != nil {
+		return "", e.Wrap("can't create invite", err)
+	}
+
+	c.invites[code] = role
+
+	return code, nil
+}
+
+func (r *RepositoryMemory) Join(ctx context.Context, code string, username string) (*repository.Membership, error) {
+	if err := r.lock(ctx); err != nil {
+		return nil, err
+	}
+	defer r.mu.Unlock()
+
+	for _, c := range r.collections {
+		role, ok := c.invites[code]
+		if !ok {
+			continue
+		}
+
+		if current, ok := c.members[username]; ok {
+			role = role.Max(current)
+		}
+
+		c.members[username] = role
+
+		info := c.collection
+		return &repository.Membership{Collection: &info, Role: role}, nil
+	}
+
+	return nil, repository.ErrInviteNotFound
+}
+
+func (r *RepositoryMemory) Memberships(ctx context.Context, username string) ([]*repository.Membership, error) {
+	if err := r.lock(ctx); err != nil {
+		return nil, err
+	}
+	defer r.mu.Unlock()
+
+	var res []*repository.Membership
+	for _, c := range r.collections {
+		if role, ok := c.members[username]; ok {
+			info := c.collection
+			res = append(res, &repository.Membership{Collection: &info, Role: role})
+		}
+	}
+
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].Collection.Name < res[j].Collection.Name
+	})
+
+	return res, nil
+}
+
+func (r *RepositoryMemory) Membership(ctx context.Context, collectionID string, username string) (*repository.Membership, error) {
+	if err := r.lock(ctx); err != nil {
+		return nil, err
+	}
+	defer r.mu.Unlock()
+
+	c, ok := r.collections[collectionID]
+	if !ok {
+		return nil, repository.ErrNotMember
+	}
+
+	role, ok := c.members[username]
+	if !ok {
+		return nil, repository.ErrNotMember
+	}
+
+	info := c.collection
+	return &repository.Membership{Collection: &info, Role: role}, nil
+}
@@ -0,0 +1,475 @@
+package memory
+
+import (
+	"context"
+	"math/rand"
+	"sort"
+	"sync"
+	"telegrambot/pkg/repository"
+	"time"
+)
+
+// RepositoryMemory keeps everything in memory. It is lost on restart, so it
+// suits development and tests.
+type RepositoryMemory struct {
+	mu  sync.Mutex
+	rnd *rand.Rand
+
+	// pages maps owners to their pages by url.
+	pages       map[string]map[string]*repository.Page
+	collections map[string]*collection
+	bans        map[int]bool
+	users       map[int]*repository.User
+}
+
+func New() *RepositoryMemory {
+	return NewWithRand(repository.NewRand(nil))
+}
+
+// NewWithRand creates repository which uses rnd to pick pages.
+func NewWithRand(rnd *rand.Rand) *RepositoryMemory {
+	return &RepositoryMemory{
+		rnd:         rnd,
+		pages:       make(map[string]map[string]*repository.Page),
+		collections: make(map[string]*collection),
+		bans:        make(map[int]bool),
+		users:       make(map[int]*repository.User),
+	}
+}
+
+func (r *RepositoryMemory) Save(ctx context.Context, p *repository.Page) error {
+	if err := r.lock(ctx); err != nil {
+		return err
+	}
+	defer r.mu.Unlock()
+
+	page := clonePage(p)
+	if page.CreatedAt.IsZero() {
+		page.CreatedAt = time.Now()
+	}
+
+	r.save(page)
+
+	return nil
+}
+
+func (r *RepositoryMemory) PickRandom(ctx context.Context, username string, opts repository.PickOptions) (*repository.Page, error) {
+	if err := r.lock(ctx); err != nil {
+		return nil, err
+	}
+	defer r.mu.Unlock()
+
+	var candidates []*repository.Page
+	for _, p := range r.sorted(username) {
+		if p.IsDeleted() || p.IsRead() || p.IsSnoozed() || (opts.Tag != "" && !p.HasTag(opts.Tag)) {
+			continue
+		}
+
+		candidates = append(candidates, p)
+	}
+
+	page, err := repository.Choose(candidates, opts, r.rnd, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	return clonePage(page), nil
+}
+
+func (r *RepositoryMemory) Remove(ctx context.Context, p *repository.Page) error {
+	if err := r.lock(ctx); err != nil {
+		return err
+	}
+	defer r.mu.Unlock()
+
+	page, ok := r.get(p.Username, p.URL)
+	if !ok || page.IsDeleted() {
+		return nil
+	}
+
+	page.DeletedAt = time.Now()
+
+	return nil
+}
+
+func (r *RepositoryMemory) IsExists(ctx context.Context, p *repository.Page) (bool, error) {
+	if err := r.lock(ctx); err != nil {
+		return false, err
+	}
+	defer r.mu.Unlock()
+
+	page, ok := r.get(p.Username, p.URL)
+
+	return ok && !page.IsDeleted() && !page.IsRead(), nil
+}
+
+func (r *RepositoryMemory) Count(ctx context.Context, username string) (int, error) {
+	if err := r.lock(ctx); err != nil {
+		return 0, err
+	}
+	defer r.mu.Unlock()
+
+	count := 0
+	for _, p := range r.pages[username] {
+		if !p.IsDeleted() && !p.IsRead() {
+			count++
+		}
+	}
+
+	return count, nil
+}
+
+func (r *RepositoryMemory) MarkRead(ctx context.Context, p *repository.Page, at time.Time) error {
+	if err := r.lock(ctx); err != nil {
+		return err
+	}
+	defer r.mu.Unlock()
+
+	page, ok := r.get(p.Username, p.URL)
+	if !ok || page.IsDeleted() {
+		return repository.ErrPageNotFound
+	}
+
+	page.ReadAt = at
+
+	return nil
+}
+
+func (r *RepositoryMemory) Snooze(ctx context.Context, p *repository.Page, until time.Time) error {
+	if err := r.lock(ctx); err != nil {
+		return err
+	}
+	defer r.mu.Unlock()
+
+	page, ok := r.get(p.Username, p.URL)
+	if !ok {
+		page = clonePage(p)
+		if page.CreatedAt.IsZero() {
+			page.CreatedAt = time.Now()
+		}
+		r.save(page)
+	}
+
+	page.ChatID = p.ChatID
+	page.ResurfaceAt = until
+	page.ReadAt = time.Time{}
+	page.DeletedAt = time.Time{}
+
+	return nil
+}
+
+func (r *RepositoryMemory) DueSnoozed(ctx context.Context, now time.Time, retryAt time.Time) ([]*repository.Page, error) {
+	if err := r.lock(ctx); err != nil {
+		return nil, err
+	}
+	defer r.mu.Unlock()
+
+	var pages []*repository.Page
+	for _, owner := range r.owners() {
+		for _, p := range r.sorted(owner) {
+			if p.IsDeleted() || !p.IsSnoozed() || p.ResurfaceAt.After(now) {
+				continue
+			}
+
+			pages = append(pages, clonePage(p))
+			p.ResurfaceAt = retryAt
+		}
+	}
+
+	return pages, nil
+}
+
+func (r *RepositoryMemory) Unsnooze(ctx context.Context, p *repository.Page) error {
+	if err := r.lock(ctx); err != nil {
+		return err
+	}
+	defer r.mu.Unlock()
+
+	if page, ok := r.get(p.Username, p.URL); ok {
+		page.ResurfaceAt = time.Time{}
+	}
+
+	return nil
+}
+
+func (r *RepositoryMemory) Get(ctx context.Context, username string, url string) (*repository.Page, error) {
+	if err := r.lock(ctx); err != nil {
+		return nil, err
+	}
+	defer r.mu.Unlock()
+
+	page, ok := r.get(username, url)
+	if !ok || page.IsDeleted() {
+		return nil, repository.ErrPageNotFound
+	}
+
+	return clonePage(page), nil
+}
+
+func (r *RepositoryMemory) UpdateReview(ctx context.Context, p *repository.Page) error {
+	if err := r.lock(ctx); err != nil {
+		return err
+	}
+	defer r.mu.Unlock()
+
+	page, ok := r.get(p.Username, p.URL)
+	if !ok || page.IsDeleted() {
+		return repository.ErrPageNotFound
+	}
+
+	page.Review = p.Review
+
+	return nil
+}
+
+func (r *RepositoryMemory) PickDue(ctx context.Context, username string, now time.Time) (*repository.Page, error) {
+	if err := r.lock(ctx); err != nil {
+		return nil, err
+	}
+	defer r.mu.Unlock()
+
+	var page *repository.Page
+	for _, p := range r.sorted(username) {
+		if p.IsDeleted() || p.IsRead() || p.IsSnoozed() || !p.Review.IsDue(now) {
+			continue
+		}
+
+		if page == nil || dueBefore(p, page) {
+			page = p
+		}
+	}
+
+	if page == nil {
+		return nil, repository.ErrNoSavedPages
+	}
+
+	return clonePage(page), nil
+}
+
+func (r *RepositoryMemory) Stats(ctx context.Context, username string, now time.Time) (*repository.Stats, error) {
+	if err := r.lock(ctx); err != nil {
+		return nil, err
+	}
+	defer r.mu.Unlock()
+
+	return repository.CalcStats(r.sorted(username), now), nil
+}
+
+func (r *RepositoryMemory) LastRemoved(ctx context.Context, username string, since time.Time) (*repository.Page, error) {
+	if err := r.lock(ctx); err != nil {
+		return nil, err
+	}
+	defer r.mu.Unlock()
+
+	var page *repository.Page
+	for _, p := range r.sorted(username) {
+		if p.RemovedAt().Before(since) {
+			continue
+		}
+
+		if page == nil || p.RemovedAt().After(page.RemovedAt()) {
+			page = p
+		}
+	}
+
+	if page == nil {
+		return nil, repository.ErrPageNotFound
+	}
+
+	return clonePage(page), nil
+}
+
+func (r *RepositoryMemory) Restore(ctx context.Context, p *repository.Page) error {
+	if err := r.lock(ctx); err != nil {
+		return err
+	}
+	defer r.mu.Unlock()
+
+	page, ok := r.get(p.Username, p.URL)
+	if !ok {
+		return nil
+	}
+
+	page.DeletedAt = time.Time{}
+	page.ReadAt = time.Time{}
+
+	return nil
+}
+
+func (r *RepositoryMemory) Trash(ctx context.Context, username string) ([]*repository.Page, error) {
+	if err := r.lock(ctx); err != nil {
+		return nil, err
+	}
+	defer r.mu.Unlock()
+
+	var trash []*repository.Page
+	for _, p := range r.sorted(username) {
+		if p.IsDeleted() {
+			trash = append(trash, clonePage(p))
+		}
+	}
+
+	sort.SliceStable(trash, func(i, j int) bool {
+		return trash[i].DeletedAt.After(trash[j].DeletedAt)
+	})
+
+	return trash, nil
+}
+
+func (r *RepositoryMemory) Purge(ctx context.Context, before time.Time) (int, error) {
+	if err := r.lock(ctx); err != nil {
+		return 0, err
+	}
+	defer r.mu.Unlock()
+
+	n := 0
+	for owner, pages := range r.pages {
+		for url, p := range pages {
+			if p.IsDeleted() && p.DeletedAt.Before(before) {
+				delete(pages, url)
+				n++
+			}
+		}
+
+		if len(pages) == 0 {
+			delete(r.pages, owner)
+		}
+	}
+
+	return n, nil
+}
+
+func (r *RepositoryMemory) Pages(ctx context.Context, username string) ([]*repository.Page, error) {
+	if err := r.lock(ctx); err != nil {
+		return nil, err
+	}
+	defer r.mu.Unlock()
+
+	pages := r.sorted(username)
+
+	res := make([]*repository.Page, 0, len(pages))
+	for i := len(pages) - 1; i >= 0; i-- {
+		res = append(res, clonePage(pages[i]))
+	}
+
+	return res, nil
+}
+
+func (r *RepositoryMemory) Owners(ctx context.Context) ([]string, error) {
+	if err := r.lock(ctx); err != nil {
+		return nil, err
+	}
+	defer r.mu.Unlock()
+
+	owners := make([]string, 0, len(r.pages))
+	for _, owner := range r.owners() {
+		if len(r.pages[owner]) > 0 {
+			owners = append(owners, owner)
+		}
+	}
+
+	return owners, nil
+}
+
+// EachPage calls fn for copies of the pages of the owner in url order, so
+// that fn may use the repository.
+func (r *RepositoryMemory) EachPage(ctx context.Context, owner string, fn func(p *repository.Page) error) error {
+	if err := r.lock(ctx); err != nil {
+		return err
+	}
+
+	pages := make([]*repository.Page, 0, len(r.pages[owner]))
+	for _, p := range r.pages[owner] {
+		pages = append(pages, clonePage(p))
+	}
+	r.mu.Unlock()
+
+	sort.Slice(pages, func(i, j int) bool {
+		return pages[i].URL < pages[j].URL
+	})
+
+	for _, p := range pages {
+		if err := fn(p); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// lock locks the repository unless ctx is already done, so that canceled
+// calls fail the same way they do in other backends.
+func (r *RepositoryMemory) lock(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+
+	return nil
+}
+
+func (r *RepositoryMemory) save(p *repository.Page) {
+	pages, ok := r.pages[p.Username]
+	if !ok {
+		pages = make(map[string]*repository.Page)
+		r.pages[p.Username] = pages
+	}
+
+	pages[p.URL] = p
+}
+
+func (r *RepositoryMemory) get(username string, url string) (*repository.Page, bool) {
+	p, ok := r.pages[username][url]
+
+	return p, ok
+}
+
+// sorted returns pages of the user oldest first, so that picking from them
+// doesn't depend on map iteration order.
+func (r *RepositoryMemory) sorted(username string) []*repository.Page {
+	pages := make([]*repository.Page, 0, len(r.pages[username]))
+	for _, p := range r.pages[username] {
+		pages = append(pages, p)
+	}
+
+	sort.Slice(pages, func(i, j int) bool {
+		if !pages[i].CreatedAt.Equal(pages[j].CreatedAt) {
+			return pages[i].CreatedAt.Before(pages[j].CreatedAt)
+		}
+		return pages[i].URL < pages[j].URL
+	})
+
+	return pages
+}
+
+func (r *RepositoryMemory) owners() []string {
+	owners := make([]string, 0, len(r.pages))
+	for owner := range r.pages {
+		owners = append(owners, owner)
+	}
+
+	sort.Strings(owners)
+
+	return owners
+}
+
+// dueBefore reports whether a should be reviewed before b.
+func dueBefore(a, b *repository.Page) bool {
+	switch {
+	case a.Review.DueAt.IsZero() != b.Review.DueAt.IsZero():
+		return b.Review.DueAt.IsZero()
+	case !a.Review.DueAt.Equal(b.Review.DueAt):
+		return a.Review.DueAt.Before(b.Review.DueAt)
+	default:
+		return a.CreatedAt.Before(b.CreatedAt)
+	}
+}
+
+// clonePage copies the page, so that callers can't change stored pages.
+func clonePage(p *repository.Page) *repository.Page {
+	page := *p
+	page.Tags = append([]string(nil), p.Tags...)
+
+	return &page
+}
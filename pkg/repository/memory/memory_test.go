@@ -0,0 +1,88 @@
+package memory
+
+import (
+	"context"
+	"path/filepath"
+	"slices"
+	"telegrambot/pkg/repository"
+	"telegrambot/pkg/repository/repositorytest"
+	"testing"
+	"time"
+)
+
+func TestRepository(t *testing.T) {
+	repositorytest.Run(t, func(t *testing.T) repository.Repository {
+		return New()
+	})
+}
+
+func TestSnapshot(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "snapshot", "bot.json")
+	created := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	src := New()
+	pages := []*repository.Page{
+		{URL: "https://example.com/a", Username: "alice", Tags: []string{"go"}, CreatedAt: created},
+		{URL: "https://example.com/b", Username: "alice", CreatedAt: created, ReadAt: created.Add(time.Hour)},
+		{URL: "https://example.com/c", Username: "пользователь", CreatedAt: created},
+	}
+	for _, p := range pages {
+		if err := src.Save(ctx, p); err != nil {
+			t.Fatalf("Save: %v", err)
+		}
+	}
+	if err := src.Ban(ctx, 7); err != nil {
+		t.Fatalf("Ban: %v", err)
+	}
+	if err := src.TouchUser(ctx, &repository.User{ID: 1, Username: "alice", FirstSeen: created, LastSeen: created}); err != nil {
+		t.Fatalf("TouchUser: %v", err)
+	}
+	if err := src.CreateCollection(ctx, &repository.Collection{ID: "c1", Name: "Books", Owner: "alice"}); err != nil {
+		t.Fatalf("CreateCollection: %v", err)
+	}
+
+	if err := src.SaveSnapshot(path); err != nil {
+		t.Fatalf("SaveSnapshot: %v", err)
+	}
+
+	dst := New()
+	if err := dst.LoadSnapshot(path); err != nil {
+		t.Fatalf("LoadSnapshot: %v", err)
+	}
+
+	for _, want := range pages {
+		got, err := dst.Get(ctx, want.Username, want.URL)
+		if err != nil {
+			t.Fatalf("Get(%s): %v", want.URL, err)
+		}
+		if !got.CreatedAt.Equal(want.CreatedAt) || !got.ReadAt.Equal(want.ReadAt) || !slices.Equal(got.Tags, want.Tags) {
+			t.Errorf("loaded page = %+v, want %+v", got, want)
+		}
+	}
+
+	if banned, err := dst.IsBanned(ctx, 7); err != nil || !banned {
+		t.Errorf("IsBanned = %v, %v, want the ban to be loaded", banned, err)
+	}
+
+	users, err := dst.Users(ctx)
+	if err != nil {
+		t.Fatalf("Users: %v", err)
+	}
+	if len(users) != 1 || users[0].Username != "alice" {
+		t.Errorf("users = %+v, want alice", users)
+	}
+
+	memberships, err := dst.Memberships(ctx, "alice")
+	if err != nil {
+		t.Fatalf("Memberships: %v", err)
+	}
+	if len(memberships) != 1 || memberships[0].Collection.Name != "Books" {
+		t.Errorf("memberships = %+v, want the Books collection", memberships)
+	}
+
+	// the first start has nothing to load
+	if err := New().LoadSnapshot(filepath.Join(t.TempDir(), "missing.json")); err != nil {
+		t.Errorf("LoadSnapshot of a missing file: %v", err)
+	}
+}
@@ -0,0 +1,217 @@
+package memory
+
+import (
+	"encoding/json"
+	"errors"
+	"maps"
+	"os"
+	"path/filepath"
+	"sort"
+	"telegrambot/internal/e"
+	"telegrambot/pkg/export"
+	"telegrambot/pkg/repository"
+	"time"
+)
+
+// snapshot is everything the repository keeps. Pages are stored in the
+// export format under their owners.
+type snapshot struct {
+	Pages       map[string][]export.Page `json:"pages"`
+	Collections []snapshotCollection     `json:"collections,omitempty"`
+	Banned      []int                    `json:"banned,omitempty"`
+	Users       []snapshotUser           `json:"users,omitempty"`
+}
+
+type snapshotCollection struct {
+	ID      string                     `json:"id"`
+	Name    string                     `json:"name"`
+	Owner   string                     `json:"owner"`
+	Members map[string]repository.Role `json:"members"`
+	Invites map[string]repository.Role `json:"invites,omitempty"`
+}
+
+type snapshotUser struct {
+	ID        int        `json:"id"`
+	Username  string     `json:"username,omitempty"`
+	FirstName string     `json:"first_name,omitempty"`
+	ChatID    int        `json:"chat_id,omitempty"`
+	FirstSeen time.Time  `json:"first_seen"`
+	LastSeen  time.Time  `json:"last_seen"`
+	BlockedAt *time.Time `json:"blocked_at,omitempty"`
+}
+
+// SaveSnapshot writes the repository to a JSON file, which LoadSnapshot
+// reads back. The file is replaced at once, so a crash while saving keeps
+// the previous snapshot.
+func (r *RepositoryMemory) SaveSnapshot(path string) (err error) {
+	defer func() {
+		err = e.WrapIfErr("can't save snapshot", err)
+	}()
+
+	data, err := json.Marshal(r.snapshot())
+	if err != nil {
+		return err
+	}
+
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0774); err != nil {
+		return err
+	}
+
+	file, err := os.CreateTemp(dir, "."+filepath.Base(path)+"-*")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = os.Remove(file.Name())
+		}
+	}()
+
+	if _, err := file.Write(data); err != nil {
+		_ = file.Close()
+		return err
+	}
+
+	if err := file.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(file.Name(), path)
+}
+
+// LoadSnapshot replaces the contents of the repository with a snapshot. A
+// missing file is not an error: there is nothing to load on the first start.
+func (r *RepositoryMemory) LoadSnapshot(path string) (err error) {
+	defer func() {
+		err = e.WrapIfErr("can't load snapshot", err)
+	}()
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var s snapshot
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+
+	r.restore(&s)
+
+	return nil
+}
+
+func (r *RepositoryMemory) snapshot() *snapshot {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	s := &snapshot{Pages: make(map[string][]export.Page, len(r.pages))}
+
+	for _, owner := range r.owners() {
+		pages := r.sorted(owner)
+		if len(pages) == 0 {
+			continue
+		}
+
+		exported := make([]export.Page, 0, len(pages))
+		for _, p := range pages {
+			exported = append(exported, export.NewPage(clonePage(p)))
+		}
+		s.Pages[owner] = exported
+	}
+
+	for _, c := range r.collections {
+		s.Collections = append(s.Collections, snapshotCollection{
+			ID:      c.collection.ID,
+			Name:    c.collection.Name,
+			Owner:   c.collection.Owner,
+			Members: maps.Clone(c.members),
+			Invites: maps.Clone(c.invites),
+		})
+	}
+	sort.Slice(s.Collections, func(i, j int) bool {
+		return s.Collections[i].ID < s.Collections[j].ID
+	})
+
+	for id := range r.bans {
+		s.Banned = append(s.Banned, id)
+	}
+	sort.Ints(s.Banned)
+
+	for _, u := range r.users {
+		su := snapshotUser{
+			ID:        u.ID,
+			Username:  u.Username,
+			FirstName: u.FirstName,
+			ChatID:    u.ChatID,
+			FirstSeen: u.FirstSeen,
+			LastSeen:  u.LastSeen,
+		}
+		if u.IsBlocked() {
+			blockedAt := u.BlockedAt
+			su.BlockedAt = &blockedAt
+		}
+
+		s.Users = append(s.Users, su)
+	}
+	sort.Slice(s.Users, func(i, j int) bool {
+		return s.Users[i].ID < s.Users[j].ID
+	})
+
+	return s
+}
+
+func (r *RepositoryMemory) restore(s *snapshot) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.pages = make(map[string]map[string]*repository.Page, len(s.Pages))
+	for owner, pages := range s.Pages {
+		for _, p := range pages {
+			r.save(p.Page(owner))
+		}
+	}
+
+	r.collections = make(map[string]*collection, len(s.Collections))
+	for _, c := range s.Collections {
+		restored := &collection{
+			collection: repository.Collection{ID: c.ID, Name: c.Name, Owner: c.Owner},
+			members:    c.Members,
+			invites:    c.Invites,
+		}
+		if restored.members == nil {
+			restored.members = map[string]repository.Role{}
+		}
+		if restored.invites == nil {
+			restored.invites = map[string]repository.Role{}
+		}
+
+		r.collections[c.ID] = restored
+	}
+
+	r.bans = make(map[int]bool, len(s.Banned))
+	for _, id := range s.Banned {
+		r.bans[id] = true
+	}
+
+	r.users = make(map[int]*repository.User, len(s.Users))
+	for _, u := range s.Users {
+		restored := &repository.User{
+			ID:        u.ID,
+			Username:  u.Username,
+			FirstName: u.FirstName,
+			ChatID:    u.ChatID,
+			FirstSeen: u.FirstSeen,
+			LastSeen:  u.LastSeen,
+		}
+		if u.BlockedAt != nil {
+			restored.BlockedAt = *u.BlockedAt
+		}
+
+		r.users[u.ID] = restored
+	}
+}
@@ -0,0 +1,115 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"telegrambot/pkg/repository"
+	"time"
+)
+
+func (r *RepositoryMemory) Ban(ctx context.Context, userID int) error {
+	if err := r.lock(ctx); err != nil {
+		return err
+	}
+	defer r.mu.Unlock()
+
+	r.bans[userID] = true
+
+	return nil
+}
+
+func (r *RepositoryMemory) Unban(ctx context.Context, userID int) error {
+	if err := r.lock(ctx); err != nil {
+		return err
+	}
+	defer r.mu.Unlock()
+
+	delete(r.bans, userID)
+
+	return nil
+}
+
+func (r *RepositoryMemory) IsBanned(ctx context.Context, userID int) (bool, error) {
+	if err := r.lock(ctx); err != nil {
+		return false, err
+	}
+	defer r.mu.Unlock()
+
+	return r.bans[userID], nil
+}
+
+func (r *RepositoryMemory) Banned(ctx context.Context) ([]int, error) {
+	if err := r.lock(ctx); err != nil {
+		return nil, err
+	}
+	defer r.mu.Unlock()
+
+	ids := make([]int, 0, len(r.bans))
+	for id := range r.bans {
+		ids = append(ids, id)
+	}
+
+	sort.Ints(ids)
+
+	return ids, nil
+}
+
+func (r *RepositoryMemory) TouchUser(ctx context.Context, u *repository.User) error {
+	if err := r.lock(ctx); err != nil {
+		return err
+	}
+	defer r.mu.Unlock()
+
+	now := u.LastSeen
+	if now.IsZero() {
+		now = time.Now()
+	}
+
+	saved, ok := r.users[u.ID]
+	if !ok {
+		saved = &repository.User{ID: u.ID, FirstSeen: now}
+		r.users[u.ID] = saved
+	}
+
+	saved.Username = u.Username
+	saved.FirstName = u.FirstName
+	saved.LastSeen = now
+	if u.ChatID != 0 {
+		saved.ChatID = u.ChatID
+		saved.BlockedAt = time.Time{}
+	}
+
+	return nil
+}
+
+func (r *RepositoryMemory) Users(ctx context.Context) ([]*repository.User, error) {
+	if err := r.lock(ctx); err != nil {
+		return nil, err
+	}
+	defer r.mu.Unlock()
+
+	res := make([]*repository.User, 0, len(r.users))
+	for _, u := range r.users {
+		user := *u
+		res = append(res, &user)
+	}
+
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].ID < res[j].ID
+	})
+
+	return res, nil
+}
+
+func (r *RepositoryMemory) MarkBlocked(ctx context.Context, userID int, at time.Time) error {
+	if err := r.lock(ctx); err != nil {
+		return err
+	}
+	defer r.mu.Unlock()
+
+	if u, ok := r.users[userID]; ok {
+		u.BlockedAt = at
+	}
+
+	return nil
+}
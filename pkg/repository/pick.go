@@ -0,0 +1,165 @@
+package repository
+
+import (
+	"errors"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+var ErrUnknownStrategy = errors.New("unknown pick strategy")
+
+// Strategy defines how PickRandom chooses a page.
+type Strategy string
+
+const (
+	StrategyRandom   Strategy = "random"
+	StrategyOldest   Strategy = "oldest"
+	StrategyNewest   Strategy = "newest"
+	StrategyWeighted Strategy = "weighted"
+	StrategyNoRepeat Strategy = "norepeat"
+	// StrategyReview picks among pages due for spaced repetition review.
+	StrategyReview Strategy = "review"
+)
+
+var Strategies = []Strategy{
+	StrategyRandom,
+	StrategyOldest,
+	StrategyNewest,
+	StrategyWeighted,
+	StrategyNoRepeat,
+	StrategyReview,
+}
+
+func ParseStrategy(s string) (Strategy, error) {
+	for _, strategy := range Strategies {
+		if string(strategy) == s {
+			return strategy, nil
+		}
+	}
+
+	return "", ErrUnknownStrategy
+}
+
+type PickOptions struct {
+	Strategy Strategy
+	// Tag limits the choice to pages with the tag.
+	Tag string
+	// LastURL is the previously picked page, which StrategyNoRepeat avoids
+	// unless it is the only candidate.
+	LastURL string
+}
+
+// Choose picks a page from candidates according to opts. Candidates are
+// expected to be already filtered by tag and availability.
+func Choose(candidates []*Page, opts PickOptions, rnd *rand.Rand, now time.Time) (*Page, error) {
+	if len(candidates) == 0 {
+		return nil, ErrNoSavedPages
+	}
+
+	switch opts.Strategy {
+	case StrategyRandom, "":
+		return candidates[rnd.Intn(len(candidates))], nil
+	case StrategyOldest:
+		res := candidates[0]
+		for _, p := range candidates[1:] {
+			if p.CreatedAt.Before(res.CreatedAt) {
+				res = p
+			}
+		}
+		return res, nil
+	case StrategyNewest:
+		res := candidates[0]
+		for _, p := range candidates[1:] {
+			if p.CreatedAt.After(res.CreatedAt) {
+				res = p
+			}
+		}
+		return res, nil
+	case StrategyWeighted:
+		return chooseWeighted(candidates, rnd, now), nil
+	case StrategyNoRepeat:
+		rest := make([]*Page, 0, len(candidates))
+		for _, p := range candidates {
+			if p.URL != opts.LastURL {
+				rest = append(rest, p)
+			}
+		}
+		if len(rest) == 0 {
+			rest = candidates
+		}
+		return rest[rnd.Intn(len(rest))], nil
+	case StrategyReview:
+		due := make([]*Page, 0, len(candidates))
+		for _, p := range candidates {
+			if p.Review.IsDue(now) {
+				due = append(due, p)
+			}
+		}
+		if len(due) == 0 {
+			return nil, ErrNoSavedPages
+		}
+		return due[rnd.Intn(len(due))], nil
+	default:
+		return nil, ErrUnknownStrategy
+	}
+}
+
+// chooseWeighted picks a page with probability proportional to its age,
+// so that long forgotten pages come up more often.
+func chooseWeighted(candidates []*Page, rnd *rand.Rand, now time.Time) *Page {
+	weights := make([]float64, len(candidates))
+	var total float64
+	for i, p := range candidates {
+		weights[i] = ageWeight(p, now)
+		total += weights[i]
+	}
+
+	x := rnd.Float64() * total
+	for i, w := range weights {
+		if x < w {
+			return candidates[i]
+		}
+		x -= w
+	}
+
+	return candidates[len(candidates)-1]
+}
+
+func ageWeight(p *Page, now time.Time) float64 {
+	age := now.Sub(p.CreatedAt).Hours()
+	if age < 0 {
+		age = 0
+	}
+
+	return age + 1
+}
+
+// NewRand returns a source of randomness safe for concurrent use.
+// A nil src is seeded with the current time.
+func NewRand(src rand.Source) *rand.Rand {
+	if src == nil {
+		src = rand.NewSource(time.Now().UnixNano())
+	}
+
+	return rand.New(&lockedSource{src: src})
+}
+
+type lockedSource struct {
+	mu  sync.Mutex
+	src rand.Source
+}
+
+func (s *lockedSource) Int63() int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.src.Int63()
+}
+
+func (s *lockedSource) Seed(seed int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.src.Seed(seed)
+}
@@ -0,0 +1,227 @@
+package repository_test
+
+import (
+	"errors"
+	"math"
+	"math/rand"
+	"telegrambot/pkg/repository"
+	"telegrambot/pkg/repository/repositorytest"
+	"testing"
+	"time"
+)
+
+var now = time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+func pageAged(url string, age time.Duration) *repository.Page {
+	return &repository.Page{URL: url, Username: "alice", CreatedAt: now.Add(-age)}
+}
+
+func TestChooseOrder(t *testing.T) {
+	candidates := []*repository.Page{
+		pageAged("https://example.com/middle", 48*time.Hour),
+		pageAged("https://example.com/oldest", 72*time.Hour),
+		pageAged("https://example.com/newest", time.Hour),
+	}
+
+	tests := []struct {
+		strategy repository.Strategy
+		want     string
+	}{
+		{repository.StrategyOldest, "https://example.com/oldest"},
+		{repository.StrategyNewest, "https://example.com/newest"},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.strategy), func(t *testing.T) {
+			rnd := repository.NewRand(rand.NewSource(1))
+
+			for i := 0; i < 10; i++ {
+				got, err := repository.Choose(candidates, repository.PickOptions{Strategy: tt.strategy}, rnd, now)
+				if err != nil {
+					t.Fatalf("Choose: %v", err)
+				}
+				if got.URL != tt.want {
+					t.Fatalf("Choose = %s, want %s", got.URL, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestChooseRandomIsSeeded(t *testing.T) {
+	candidates := []*repository.Page{
+		pageAged("https://example.com/a", time.Hour),
+		pageAged("https://example.com/b", 2*time.Hour),
+		pageAged("https://example.com/c", 3*time.Hour),
+		pageAged("https://example.com/d", 4*time.Hour),
+	}
+
+	for _, strategy := range []repository.Strategy{repository.StrategyRandom, repository.StrategyWeighted, repository.StrategyNoRepeat} {
+		t.Run(string(strategy), func(t *testing.T) {
+			first := pickSequence(t, candidates, strategy, 7)
+			second := pickSequence(t, candidates, strategy, 7)
+
+			for i := range first {
+				if first[i] != second[i] {
+					t.Fatalf("pick %d with the same seed = %s and %s", i, first[i], second[i])
+				}
+			}
+		})
+	}
+}
+
+func pickSequence(t *testing.T, candidates []*repository.Page, strategy repository.Strategy, seed int64) []string {
+	t.Helper()
+
+	rnd := repository.NewRand(rand.NewSource(seed))
+
+	var urls []string
+	for i := 0; i < 20; i++ {
+		got, err := repository.Choose(candidates, repository.PickOptions{Strategy: strategy}, rnd, now)
+		if err != nil {
+			t.Fatalf("Choose: %v", err)
+		}
+		urls = append(urls, got.URL)
+	}
+
+	return urls
+}
+
+func TestChooseWeighted(t *testing.T) {
+	// weights are the age in hours plus one: 1 and 10
+	candidates := []*repository.Page{
+		pageAged("https://example.com/new", 0),
+		pageAged("https://example.com/old", 9*time.Hour),
+	}
+
+	tests := []struct {
+		name     string
+		fraction float64
+		want     string
+	}{
+		{"start", 0, "https://example.com/new"},
+		{"within the first weight", 0.09, "https://example.com/new"},
+		{"past the first weight", 0.1, "https://example.com/old"},
+		{"end", 0.99, "https://example.com/old"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rnd := repositorytest.FixedRand(tt.fraction)
+
+			got, err := repository.Choose(candidates, repository.PickOptions{Strategy: repository.StrategyWeighted}, rnd, now)
+			if err != nil {
+				t.Fatalf("Choose: %v", err)
+			}
+			if got.URL != tt.want {
+				t.Errorf("Choose = %s, want %s", got.URL, tt.want)
+			}
+		})
+	}
+}
+
+func TestChooseWeightedPrefersOldPages(t *testing.T) {
+	candidates := []*repository.Page{
+		pageAged("https://example.com/new", 0),
+		pageAged("https://example.com/old", 9*time.Hour),
+	}
+
+	rnd := repository.NewRand(rand.NewSource(42))
+	const picks = 10000
+
+	old := 0
+	for i := 0; i < picks; i++ {
+		got, err := repository.Choose(candidates, repository.PickOptions{Strategy: repository.StrategyWeighted}, rnd, now)
+		if err != nil {
+			t.Fatalf("Choose: %v", err)
+		}
+		if got.URL == "https://example.com/old" {
+			old++
+		}
+	}
+
+	if share := float64(old) / picks; math.Abs(share-10.0/11) > 0.02 {
+		t.Errorf("old page was picked %.3f of times, want about %.3f", share, 10.0/11)
+	}
+}
+
+func TestChooseNoRepeat(t *testing.T) {
+	last := "https://example.com/a"
+	candidates := []*repository.Page{
+		pageAged(last, time.Hour),
+		pageAged("https://example.com/b", time.Hour),
+		pageAged("https://example.com/c", time.Hour),
+	}
+
+	rnd := repository.NewRand(rand.NewSource(3))
+	opts := repository.PickOptions{Strategy: repository.StrategyNoRepeat, LastURL: last}
+
+	seen := make(map[string]bool)
+	for i := 0; i < 100; i++ {
+		got, err := repository.Choose(candidates, opts, rnd, now)
+		if err != nil {
+			t.Fatalf("Choose: %v", err)
+		}
+		if got.URL == last {
+			t.Fatalf("Choose returned the last page %s", last)
+		}
+		seen[got.URL] = true
+	}
+
+	if len(seen) != 2 {
+		t.Errorf("Choose returned %v, want both other pages", seen)
+	}
+}
+
+func TestChooseNoRepeatFallsBackToLastPage(t *testing.T) {
+	last := "https://example.com/a"
+	candidates := []*repository.Page{pageAged(last, time.Hour)}
+
+	rnd := repository.NewRand(rand.NewSource(3))
+
+	got, err := repository.Choose(candidates, repository.PickOptions{Strategy: repository.StrategyNoRepeat, LastURL: last}, rnd, now)
+	if err != nil {
+		t.Fatalf("Choose: %v", err)
+	}
+	if got.URL != last {
+		t.Errorf("Choose = %s, want the only page %s", got.URL, last)
+	}
+}
+
+func TestChooseReview(t *testing.T) {
+	due := pageAged("https://example.com/due", time.Hour)
+	due.Review.DueAt = now.Add(-time.Minute)
+	later := pageAged("https://example.com/later", time.Hour)
+	later.Review.DueAt = now.Add(time.Hour)
+
+	rnd := repository.NewRand(rand.NewSource(5))
+
+	for i := 0; i < 10; i++ {
+		got, err := repository.Choose([]*repository.Page{later, due}, repository.PickOptions{Strategy: repository.StrategyReview}, rnd, now)
+		if err != nil {
+			t.Fatalf("Choose: %v", err)
+		}
+		if got.URL != due.URL {
+			t.Fatalf("Choose = %s, want %s", got.URL, due.URL)
+		}
+	}
+
+	_, err := repository.Choose([]*repository.Page{later}, repository.PickOptions{Strategy: repository.StrategyReview}, rnd, now)
+	if !errors.Is(err, repository.ErrNoSavedPages) {
+		t.Errorf("Choose without due pages: err = %v, want %v", err, repository.ErrNoSavedPages)
+	}
+}
+
+func TestChooseErrors(t *testing.T) {
+	rnd := repository.NewRand(rand.NewSource(1))
+
+	_, err := repository.Choose(nil, repository.PickOptions{}, rnd, now)
+	if !errors.Is(err, repository.ErrNoSavedPages) {
+		t.Errorf("Choose without candidates: err = %v, want %v", err, repository.ErrNoSavedPages)
+	}
+
+	_, err = repository.Choose([]*repository.Page{pageAged("https://example.com/a", 0)}, repository.PickOptions{Strategy: "unknown"}, rnd, now)
+	if !errors.Is(err, repository.ErrUnknownStrategy) {
+		t.Errorf("Choose with an unknown strategy: err = %v, want %v", err, repository.ErrUnknownStrategy)
+	}
+}
@@ -0,0 +1,65 @@
+package postgres
+
+import (
+	"context"
+	"telegrambot/internal/e"
+)
+
+// Ban bans the user.
+func (r *RepositoryPostgres) Ban(ctx context.Context, userID int) error {
+	q := `INSERT INTO banned_users (user_id) VALUES (?) ON CONFLICT DO NOTHING`
+
+	if _, err := r.db.ExecContext(ctx, rebind(q), userID); err != nil {
+		return e.Wrap("can't ban user", err)
+	}
+
+	return nil
+}
+
+// Unban lifts the ban of the user.
+func (r *RepositoryPostgres) Unban(ctx context.Context, userID int) error {
+	q := `DELETE FROM banned_users WHERE user_id = ?`
+
+	if _, err := r.db.ExecContext(ctx, rebind(q), userID); err != nil {
+		return e.Wrap("can't unban user", err)
+	}
+
+	return nil
+}
+
+// IsBanned checks if the user is banned.
+func (r *RepositoryPostgres) IsBanned(ctx context.Context, userID int) (bool, error) {
+	q := `SELECT EXISTS (SELECT 1 FROM banned_users WHERE user_id = ?)`
+
+	var banned bool
+
+	if err := r.db.QueryRowContext(ctx, rebind(q), userID).Scan(&banned); err != nil {
+		return false, e.Wrap("can't check if user is banned", err)
+	}
+
+	return banned, nil
+}
+
+// Banned returns ids of banned users.
+func (r *RepositoryPostgres) Banned(ctx context.Context) (ids []int, err error) {
+	defer func() {
+		err = e.WrapIfErr("can't get banned users", err)
+	}()
+
+	rows, err := r.db.QueryContext(ctx, `SELECT user_id FROM banned_users ORDER BY user_id`)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+
+		ids = append(ids, id)
+	}
+
+	return ids, rows.Err()
+}
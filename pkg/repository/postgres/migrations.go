@@ -0,0 +1,120 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"telegrambot/internal/e"
+	"telegrambot/internal/logging"
+	"time"
+)
+
+// migrationsLock is the advisory lock which keeps replicas started at the
+// same time from migrating the schema concurrently.
+const migrationsLock = 7402815
+
+// migrations are applied in order, each once. Applied migrations must never
+// be changed, add a new one instead.
+var migrations = []string{
+	`CREATE TABLE pages (
+    id BIGSERIAL PRIMARY KEY,
+    url TEXT NOT NULL,
+    username TEXT NOT NULL,
+    chat_id BIGINT,
+    created_at BIGINT NOT NULL,
+    resurface_at BIGINT,
+    review_interval INTEGER,
+    review_ease DOUBLE PRECISION,
+    review_repetitions INTEGER,
+    due_at BIGINT,
+    read_at BIGINT,
+    deleted_at BIGINT,
+    domain TEXT NOT NULL DEFAULT '',
+    rnd DOUBLE PRECISION NOT NULL DEFAULT random(),
+    UNIQUE (username, url)
+);
+CREATE INDEX pages_pick_idx ON pages (username, rnd) WHERE deleted_at IS NULL AND read_at IS NULL AND resurface_at IS NULL;
+CREATE INDEX pages_created_at_idx ON pages (username, created_at);
+CREATE INDEX pages_resurface_at_idx ON pages (resurface_at) WHERE resurface_at IS NOT NULL;
+CREATE INDEX pages_deleted_at_idx ON pages (deleted_at) WHERE deleted_at IS NOT NULL;
+CREATE TABLE page_tags (
+    id BIGSERIAL PRIMARY KEY,
+    username TEXT NOT NULL,
+    url TEXT NOT NULL,
+    tag TEXT NOT NULL,
+    FOREIGN KEY (username, url) REFERENCES pages (username, url) ON DELETE CASCADE
+);
+CREATE INDEX page_tags_page_idx ON page_tags (username, url);
+CREATE INDEX page_tags_tag_idx ON page_tags (username, tag);
+CREATE TABLE collections (id TEXT PRIMARY KEY, name TEXT NOT NULL, owner TEXT NOT NULL);
+CREATE TABLE collection_members (
+    collection_id TEXT NOT NULL REFERENCES collections (id),
+    username TEXT NOT NULL,
+    role TEXT NOT NULL,
+    PRIMARY KEY (collection_id, username)
+);
+CREATE INDEX collection_members_username_idx ON collection_members (username);
+CREATE TABLE collection_invites (
+    code TEXT PRIMARY KEY,
+    collection_id TEXT NOT NULL REFERENCES collections (id),
+    role TEXT NOT NULL,
+    UNIQUE (collection_id, role)
+);
+CREATE TABLE banned_users (user_id BIGINT PRIMARY KEY);
+CREATE TABLE users (
+    id BIGINT PRIMARY KEY,
+    username TEXT NOT NULL DEFAULT '',
+    first_name TEXT NOT NULL DEFAULT '',
+    chat_id BIGINT NOT NULL DEFAULT 0,
+    first_seen BIGINT,
+    last_seen BIGINT,
+    blocked_at BIGINT
+);`,
+}
+
+// Init creates the schema or applies migrations missing in the database.
+func (r *RepositoryPostgres) Init(ctx context.Context) (err error) {
+	defer func() {
+		err = e.WrapIfErr("can't migrate postgres db", err)
+	}()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	if _, err = tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock($1)`, migrationsLock); err != nil {
+		return err
+	}
+
+	q := `CREATE TABLE IF NOT EXISTS schema_migrations (version INTEGER PRIMARY KEY, applied_at BIGINT NOT NULL)`
+
+	if _, err = tx.ExecContext(ctx, q); err != nil {
+		return err
+	}
+
+	var version int
+	if err = tx.QueryRowContext(ctx, `SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&version); err != nil {
+		return err
+	}
+
+	for i := version; i < len(migrations); i++ {
+		if _, err = tx.ExecContext(ctx, migrations[i]); err != nil {
+			return fmt.Errorf("migration %d: %w", i+1, err)
+		}
+
+		q = `INSERT INTO schema_migrations (version, applied_at) VALUES ($1, $2)`
+
+		if _, err = tx.ExecContext(ctx, q, i+1, time.Now().Unix()); err != nil {
+			return err
+		}
+
+		logging.FromContext(ctx).Info("postgres: applied migration", "version", i+1)
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,74 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"telegrambot/internal/e"
+	"telegrambot/pkg/repository"
+	"time"
+)
+
+// TouchUser saves user or updates the saved one.
+func (r *RepositoryPostgres) TouchUser(ctx context.Context, u *repository.User) error {
+	q := `INSERT INTO users (id, username, first_name, chat_id, first_seen, last_seen) VALUES (?, ?, ?, ?, ?, ?)
+ON CONFLICT (id) DO UPDATE SET
+    username = excluded.username,
+    first_name = excluded.first_name,
+    chat_id = CASE WHEN excluded.chat_id <> 0 THEN excluded.chat_id ELSE users.chat_id END,
+    last_seen = excluded.last_seen,
+    blocked_at = CASE WHEN excluded.chat_id <> 0 THEN NULL ELSE users.blocked_at END`
+
+	now := u.LastSeen
+	if now.IsZero() {
+		now = time.Now()
+	}
+
+	if _, err := r.db.ExecContext(ctx, rebind(q), u.ID, u.Username, u.FirstName, u.ChatID, now.Unix(), now.Unix()); err != nil {
+		return e.Wrap("can't save user", err)
+	}
+
+	return nil
+}
+
+// Users returns all known users.
+func (r *RepositoryPostgres) Users(ctx context.Context) (users []*repository.User, err error) {
+	defer func() {
+		err = e.WrapIfErr("can't get users", err)
+	}()
+
+	q := `SELECT id, username, first_name, chat_id, first_seen, last_seen, blocked_at FROM users ORDER BY id`
+
+	rows, err := r.db.QueryContext(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	for rows.Next() {
+		var u repository.User
+		var firstSeen, lastSeen, blockedAt sql.NullInt64
+
+		if err := rows.Scan(&u.ID, &u.Username, &u.FirstName, &u.ChatID, &firstSeen, &lastSeen, &blockedAt); err != nil {
+			return nil, err
+		}
+
+		u.FirstSeen = fromUnix(firstSeen)
+		u.LastSeen = fromUnix(lastSeen)
+		u.BlockedAt = fromUnix(blockedAt)
+
+		users = append(users, &u)
+	}
+
+	return users, rows.Err()
+}
+
+// MarkBlocked marks that the user has blocked the bot.
+func (r *RepositoryPostgres) MarkBlocked(ctx context.Context, userID int, at time.Time) error {
+	q := `UPDATE users SET blocked_at = ? WHERE id = ?`
+
+	if _, err := r.db.ExecContext(ctx, rebind(q), at.Unix(), userID); err != nil {
+		return e.Wrap("can't mark user as blocked", err)
+	}
+
+	return nil
+}
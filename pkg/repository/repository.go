@@ -5,23 +5,109 @@ import (
 	"crypto/sha1"
 	"errors"
 	"fmt"
+	"net/url"
+	"strings"
 	"telegrambot/internal/e"
+	"time"
 )
 
-var ErrNoSavedPages = errors.New("no saved pages")
+var (
+	ErrNoSavedPages = errors.New("no saved pages")
+	ErrPageNotFound = errors.New("page not found")
+)
 
 type Repository interface {
+	Collections
+	Bans
+	Users
+
+	// Save saves the page replacing the previously saved one with the same url.
 	Save(ctx context.Context, p *Page) error
-	PickRandom(ctx context.Context, username string) (*Page, error)
+	// PickRandom returns ErrNoSavedPages if the user has no page to pick,
+	// including when the user has never saved one.
+	PickRandom(ctx context.Context, username string, opts PickOptions) (*Page, error)
+	// Remove moves the page to the trash, where it stays until it is purged.
+	// Removing a missing page is not an error.
 	Remove(ctx context.Context, p *Page) error
+	// IsExists checks if there is an unread page with the same url.
 	IsExists(ctx context.Context, p *Page) (bool, error)
+	// Count returns the number of pages in the list, that is neither read
+	// nor removed.
+	Count(ctx context.Context, username string) (int, error)
+	// MarkRead keeps the page for statistics but excludes it from picking.
+	MarkRead(ctx context.Context, p *Page, at time.Time) error
+	// Snooze hides the page from PickRandom until the given time. The page is
+	// saved if it doesn't exist yet.
+	Snooze(ctx context.Context, p *Page, until time.Time) error
+	// DueSnoozed returns snoozed pages which are due at now and postpones
+	// them until retryAt, so that a page which fails to be delivered comes
+	// back later and other replicas don't deliver it meanwhile.
+	DueSnoozed(ctx context.Context, now time.Time, retryAt time.Time) ([]*Page, error)
+	// Unsnooze puts a delivered page back into rotation. Unsnoozing a
+	// missing page is not an error.
+	Unsnooze(ctx context.Context, p *Page) error
+	// Get returns ErrPageNotFound if the user has no page with the url.
+	Get(ctx context.Context, username string, url string) (*Page, error)
+	// UpdateReview stores the review schedule of an existing page.
+	UpdateReview(ctx context.Context, p *Page) error
+	// PickDue returns the most overdue page in review. Pages which were never
+	// reviewed are considered due after the overdue ones, oldest first.
+	PickDue(ctx context.Context, username string, now time.Time) (*Page, error)
+	Stats(ctx context.Context, username string, now time.Time) (*Stats, error)
+	// LastRemoved returns the page which was most recently removed or read
+	// since the given time, or ErrPageNotFound.
+	LastRemoved(ctx context.Context, username string, since time.Time) (*Page, error)
+	// Restore returns removed or read page back to the list. Restoring a
+	// missing page is not an error.
+	Restore(ctx context.Context, p *Page) error
+	// Trash returns removed pages, most recently removed first.
+	Trash(ctx context.Context, username string) ([]*Page, error)
+	// Purge permanently deletes pages removed before the given time and
+	// returns their number.
+	Purge(ctx context.Context, before time.Time) (int, error)
+	// Pages returns all pages of the user including read and removed ones,
+	// newest first.
+	Pages(ctx context.Context, username string) ([]*Page, error)
 }
 
 type Page struct {
-	URL      string
-	Username string
+	URL string
+	// Username is the owner of the page: either a Telegram username or a key
+	// of a list shared by a group chat.
+	Username  string
+	Tags      []string
+	CreatedAt time.Time
+	// ChatID is the chat the page is delivered to when it resurfaces.
+	ChatID int
+	// ResurfaceAt is zero unless the page is snoozed.
+	ResurfaceAt time.Time
+	Review      Review
+	// ReadAt is zero for unread pages.
+	ReadAt time.Time
+	// DeletedAt is zero unless the page is in the trash.
+	DeletedAt time.Time
+}
+
+type Stats struct {
+	Total        int
+	Unread       int
+	ReadThisWeek int
+	// AvgUnreadAge is the average age of unread pages.
+	AvgUnreadAge time.Duration
+	TopDomains   []Count
+	TopTags      []Count
 }
 
+type Count struct {
+	Name  string
+	Count int
+}
+
+// TopCountsLimit is the number of entries in Stats.TopDomains and Stats.TopTags.
+const TopCountsLimit = 5
+
+const week = 7 * 24 * time.Hour
+
 func (p *Page) Hash() (string, error) {
 	h := sha1.New()
 
@@ -35,3 +121,44 @@ func (p *Page) Hash() (string, error) {
 
 	return fmt.Sprintf("%x", h.Sum(nil)), nil
 }
+
+func (p *Page) IsSnoozed() bool {
+	return !p.ResurfaceAt.IsZero()
+}
+
+func (p *Page) HasTag(tag string) bool {
+	for _, t := range p.Tags {
+		if t == tag {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (p *Page) IsDeleted() bool {
+	return !p.DeletedAt.IsZero()
+}
+
+// RemovedAt returns when the page was removed or read.
+func (p *Page) RemovedAt() time.Time {
+	if p.DeletedAt.After(p.ReadAt) {
+		return p.DeletedAt
+	}
+
+	return p.ReadAt
+}
+
+func (p *Page) IsRead() bool {
+	return !p.ReadAt.IsZero()
+}
+
+// Domain returns host of the page url without the www prefix.
+func (p *Page) Domain() string {
+	u, err := url.Parse(p.URL)
+	if err != nil {
+		return ""
+	}
+
+	return strings.TrimPrefix(strings.ToLower(u.Hostname()), "www.")
+}
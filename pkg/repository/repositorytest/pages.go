@@ -0,0 +1,668 @@
+package repositorytest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"telegrambot/pkg/repository"
+	"testing"
+	"time"
+)
+
+func testSaveAndGet(t *testing.T, r repository.Repository) {
+	ctx := context.Background()
+
+	p := page("alice", "https://example.com/a", time.Hour, "go", "db")
+	p.ResurfaceAt = base.Add(time.Hour)
+	p.Review = repository.Review{Interval: 3, Ease: 2.5, Repetitions: 2, DueAt: base.Add(72 * time.Hour)}
+	save(t, r, p)
+
+	samePage(t, mustGet(t, r, "alice", p.URL), p)
+
+	_, err := r.Get(ctx, "alice", "https://example.com/missing")
+	wantErr(t, "Get of a missing url", err, repository.ErrPageNotFound)
+
+	_, err = r.Get(ctx, "bob", p.URL)
+	wantErr(t, "Get of a page of another user", err, repository.ErrPageNotFound)
+
+	exists, err := r.IsExists(ctx, p)
+	wantNoErr(t, "IsExists", err)
+	if !exists {
+		t.Error("IsExists = false for a saved page")
+	}
+
+	exists, err = r.IsExists(ctx, page("bob", p.URL, 0))
+	wantNoErr(t, "IsExists", err)
+	if exists {
+		t.Error("IsExists = true for a page of another user")
+	}
+}
+
+func testSaveReplaces(t *testing.T, r repository.Repository) {
+	save(t, r, page("alice", "https://example.com/a", time.Hour, "old"))
+
+	replaced := page("alice", "https://example.com/a", 0, "new")
+	save(t, r, replaced)
+
+	samePage(t, mustGet(t, r, "alice", replaced.URL), replaced)
+	wantCount(t, r, "alice", 1)
+
+	// a page saved without creation time gets the current one
+	fresh := &repository.Page{URL: "https://example.com/fresh", Username: "alice"}
+	save(t, r, fresh)
+	if got := mustGet(t, r, "alice", fresh.URL); got.CreatedAt.IsZero() {
+		t.Error("CreatedAt is zero for a page saved without it")
+	}
+}
+
+func testEmptyUser(t *testing.T, r repository.Repository) {
+	ctx := context.Background()
+
+	for _, strategy := range repository.Strategies {
+		_, err := r.PickRandom(ctx, "nobody", repository.PickOptions{Strategy: strategy})
+		wantErr(t, fmt.Sprintf("PickRandom(%s) for an unknown user", strategy), err, repository.ErrNoSavedPages)
+	}
+
+	_, err := r.PickDue(ctx, "nobody", base)
+	wantErr(t, "PickDue for an unknown user", err, repository.ErrNoSavedPages)
+
+	_, err = r.LastRemoved(ctx, "nobody", time.Time{})
+	wantErr(t, "LastRemoved for an unknown user", err, repository.ErrPageNotFound)
+
+	wantCount(t, r, "nobody", 0)
+
+	pages, err := r.Pages(ctx, "nobody")
+	wantNoErr(t, "Pages", err)
+	if len(pages) != 0 {
+		t.Errorf("Pages for an unknown user = %q", urls(pages))
+	}
+
+	trash, err := r.Trash(ctx, "nobody")
+	wantNoErr(t, "Trash", err)
+	if len(trash) != 0 {
+		t.Errorf("Trash for an unknown user = %q", urls(trash))
+	}
+
+	stats, err := r.Stats(ctx, "nobody", base)
+	wantNoErr(t, "Stats", err)
+	if stats.Total != 0 || stats.Unread != 0 || len(stats.TopDomains) != 0 || len(stats.TopTags) != 0 {
+		t.Errorf("Stats for an unknown user = %+v", stats)
+	}
+
+	due, err := r.DueSnoozed(ctx, base, base.Add(time.Hour))
+	wantNoErr(t, "DueSnoozed", err)
+	if len(due) != 0 {
+		t.Errorf("DueSnoozed in an empty repository = %q", urls(due))
+	}
+
+	n, err := r.Purge(ctx, base)
+	wantNoErr(t, "Purge", err)
+	if n != 0 {
+		t.Errorf("Purge in an empty repository = %d", n)
+	}
+
+	users, err := r.Users(ctx)
+	wantNoErr(t, "Users", err)
+	if len(users) != 0 {
+		t.Errorf("Users in an empty repository = %d", len(users))
+	}
+}
+
+// testOwners checks that lists of users, group chats and collections are
+// kept apart.
+func testOwners(t *testing.T, r repository.Repository) {
+	owners := []string{"alice", "chat-100", "collection-abc", "user-7"}
+
+	for i, owner := range owners {
+		for j := 0; j <= i; j++ {
+			save(t, r, page(owner, fmt.Sprintf("https://example.com/%d", j), 0))
+		}
+	}
+
+	for i, owner := range owners {
+		wantCount(t, r, owner, i+1)
+	}
+}
+
+func testUnicodeUsername(t *testing.T, r repository.Repository) {
+	ctx := context.Background()
+
+	owner := "пользователь_名前_😀"
+	p := page(owner, "https://пример.рф/статья?q=тест", 0, "чтение")
+	save(t, r, p)
+
+	samePage(t, mustGet(t, r, owner, p.URL), p)
+	wantCount(t, r, owner, 1)
+
+	got, err := r.PickRandom(ctx, owner, repository.PickOptions{Tag: "чтение"})
+	wantNoErr(t, "PickRandom", err)
+	samePage(t, got, p)
+
+	stats, err := r.Stats(ctx, owner, base)
+	wantNoErr(t, "Stats", err)
+	if len(stats.TopDomains) != 1 || stats.TopDomains[0].Name != "пример.рф" {
+		t.Errorf("TopDomains = %+v", stats.TopDomains)
+	}
+
+	wantCount(t, r, "пользователь", 0)
+}
+
+func testRemoveAndRestore(t *testing.T, r repository.Repository) {
+	ctx := context.Background()
+
+	p := page("alice", "https://example.com/a", time.Hour)
+	save(t, r, p)
+
+	before := time.Now().Add(-time.Minute)
+	wantNoErr(t, "Remove", r.Remove(ctx, p))
+
+	_, err := r.Get(ctx, "alice", p.URL)
+	wantErr(t, "Get of a removed page", err, repository.ErrPageNotFound)
+
+	exists, err := r.IsExists(ctx, p)
+	wantNoErr(t, "IsExists", err)
+	if exists {
+		t.Error("IsExists = true for a removed page")
+	}
+
+	wantCount(t, r, "alice", 0)
+
+	_, err = r.PickRandom(ctx, "alice", repository.PickOptions{})
+	wantErr(t, "PickRandom of a removed page", err, repository.ErrNoSavedPages)
+
+	trash, err := r.Trash(ctx, "alice")
+	wantNoErr(t, "Trash", err)
+	if len(trash) != 1 || trash[0].URL != p.URL || !trash[0].IsDeleted() {
+		t.Fatalf("Trash = %q", urls(trash))
+	}
+
+	removed, err := r.LastRemoved(ctx, "alice", before)
+	wantNoErr(t, "LastRemoved", err)
+	if removed.URL != p.URL {
+		t.Errorf("LastRemoved = %s, want %s", removed.URL, p.URL)
+	}
+
+	_, err = r.LastRemoved(ctx, "alice", time.Now().Add(time.Hour))
+	wantErr(t, "LastRemoved since a later time", err, repository.ErrPageNotFound)
+
+	wantNoErr(t, "Restore", r.Restore(ctx, p))
+	samePage(t, mustGet(t, r, "alice", p.URL), p)
+	wantCount(t, r, "alice", 1)
+
+	// saving a removed page brings it back
+	wantNoErr(t, "Remove", r.Remove(ctx, p))
+	save(t, r, p)
+	samePage(t, mustGet(t, r, "alice", p.URL), p)
+}
+
+func testRemoveMissing(t *testing.T, r repository.Repository) {
+	ctx := context.Background()
+
+	missing := page("nobody", "https://example.com/missing", 0)
+
+	wantNoErr(t, "Remove of a missing page", r.Remove(ctx, missing))
+	wantNoErr(t, "Restore of a missing page", r.Restore(ctx, missing))
+
+	err := r.MarkRead(ctx, missing, base)
+	wantErr(t, "MarkRead of a missing page", err, repository.ErrPageNotFound)
+
+	err = r.UpdateReview(ctx, missing)
+	wantErr(t, "UpdateReview of a missing page", err, repository.ErrPageNotFound)
+
+	_, err = r.Get(ctx, missing.Username, missing.URL)
+	wantErr(t, "Get after Restore of a missing page", err, repository.ErrPageNotFound)
+}
+
+func testMarkRead(t *testing.T, r repository.Repository) {
+	ctx := context.Background()
+
+	p := page("alice", "https://example.com/a", time.Hour)
+	save(t, r, p)
+
+	before := time.Now().Add(-time.Minute)
+	wantNoErr(t, "MarkRead", r.MarkRead(ctx, p, time.Now()))
+
+	exists, err := r.IsExists(ctx, p)
+	wantNoErr(t, "IsExists", err)
+	if exists {
+		t.Error("IsExists = true for a read page")
+	}
+
+	wantCount(t, r, "alice", 0)
+
+	_, err = r.PickRandom(ctx, "alice", repository.PickOptions{})
+	wantErr(t, "PickRandom of a read page", err, repository.ErrNoSavedPages)
+
+	read := mustGet(t, r, "alice", p.URL)
+	if !read.IsRead() {
+		t.Error("read page has no ReadAt")
+	}
+
+	removed, err := r.LastRemoved(ctx, "alice", before)
+	wantNoErr(t, "LastRemoved", err)
+	if removed.URL != p.URL {
+		t.Errorf("LastRemoved = %s, want %s", removed.URL, p.URL)
+	}
+
+	wantNoErr(t, "Restore", r.Restore(ctx, p))
+	wantCount(t, r, "alice", 1)
+
+	wantNoErr(t, "Remove", r.Remove(ctx, p))
+	err = r.MarkRead(ctx, p, time.Now())
+	wantErr(t, "MarkRead of a removed page", err, repository.ErrPageNotFound)
+}
+
+func testSnooze(t *testing.T, r repository.Repository) {
+	ctx := context.Background()
+
+	p := page("alice", "https://example.com/a", time.Hour)
+	save(t, r, p)
+
+	until := base.Add(time.Hour)
+	wantNoErr(t, "Snooze", r.Snooze(ctx, p, until))
+
+	_, err := r.PickRandom(ctx, "alice", repository.PickOptions{})
+	wantErr(t, "PickRandom of a snoozed page", err, repository.ErrNoSavedPages)
+
+	// snoozing an unsaved page saves it
+	unsaved := page("bob", "https://example.com/b", 0)
+	wantNoErr(t, "Snooze of an unsaved page", r.Snooze(ctx, unsaved, until))
+	if got := mustGet(t, r, "bob", unsaved.URL); !sameTime(got.ResurfaceAt, until) {
+		t.Errorf("ResurfaceAt = %v, want %v", got.ResurfaceAt, until)
+	}
+
+	retryAt := until.Add(10 * time.Minute)
+
+	due, err := r.DueSnoozed(ctx, until.Add(-time.Minute), retryAt)
+	wantNoErr(t, "DueSnoozed", err)
+	if len(due) != 0 {
+		t.Errorf("DueSnoozed before the time = %q", urls(due))
+	}
+
+	due, err = r.DueSnoozed(ctx, until, retryAt)
+	wantNoErr(t, "DueSnoozed", err)
+	if got := sortedURLs(due); !slices.Equal(got, []string{p.URL, unsaved.URL}) {
+		t.Errorf("DueSnoozed = %q", got)
+	}
+	for _, rp := range due {
+		if rp.ChatID != 42 {
+			t.Errorf("due page %s has chat %d, want 42", rp.URL, rp.ChatID)
+		}
+	}
+
+	// pages are postponed until they are unsnoozed
+	due, err = r.DueSnoozed(ctx, retryAt.Add(-time.Minute), retryAt)
+	wantNoErr(t, "DueSnoozed", err)
+	if len(due) != 0 {
+		t.Errorf("DueSnoozed before the retry = %q", urls(due))
+	}
+
+	wantNoErr(t, "Unsnooze", r.Unsnooze(ctx, p))
+	wantNoErr(t, "Unsnooze of a missing page", r.Unsnooze(ctx, page("alice", "https://example.com/missing", 0)))
+
+	due, err = r.DueSnoozed(ctx, retryAt, retryAt.Add(10*time.Minute))
+	wantNoErr(t, "DueSnoozed", err)
+	if got := urls(due); !slices.Equal(got, []string{unsaved.URL}) {
+		t.Errorf("DueSnoozed after the retry = %q, want the page which wasn't unsnoozed", got)
+	}
+	wantNoErr(t, "Unsnooze", r.Unsnooze(ctx, unsaved))
+
+	got, err := r.PickRandom(ctx, "alice", repository.PickOptions{})
+	wantNoErr(t, "PickRandom of a resurfaced page", err)
+	if got.URL != p.URL || got.IsSnoozed() {
+		t.Errorf("PickRandom = %+v", got)
+	}
+
+	// snoozing a removed page returns it from the trash
+	wantNoErr(t, "Remove", r.Remove(ctx, p))
+	wantNoErr(t, "Snooze", r.Snooze(ctx, p, until))
+	mustGet(t, r, "alice", p.URL)
+}
+
+func testPickStrategies(t *testing.T, r repository.Repository) {
+	ctx := context.Background()
+
+	oldest := page("alice", "https://example.com/oldest", 72*time.Hour)
+	middle := page("alice", "https://example.com/middle", 48*time.Hour)
+	newest := page("alice", "https://example.com/newest", 24*time.Hour)
+	save(t, r, middle, oldest, newest)
+
+	pick := func(opts repository.PickOptions) *repository.Page {
+		t.Helper()
+
+		p, err := r.PickRandom(ctx, "alice", opts)
+		wantNoErr(t, fmt.Sprintf("PickRandom(%s)", opts.Strategy), err)
+
+		return p
+	}
+
+	if p := pick(repository.PickOptions{Strategy: repository.StrategyOldest}); p.URL != oldest.URL {
+		t.Errorf("oldest strategy picked %s", p.URL)
+	}
+	if p := pick(repository.PickOptions{Strategy: repository.StrategyNewest}); p.URL != newest.URL {
+		t.Errorf("newest strategy picked %s", p.URL)
+	}
+
+	all := []string{oldest.URL, middle.URL, newest.URL}
+	for _, strategy := range []repository.Strategy{"", repository.StrategyRandom, repository.StrategyWeighted, repository.StrategyReview} {
+		for i := 0; i < 10; i++ {
+			if p := pick(repository.PickOptions{Strategy: strategy}); !slices.Contains(all, p.URL) {
+				t.Fatalf("%s strategy picked %s", strategy, p.URL)
+			}
+		}
+	}
+
+	for i := 0; i < 20; i++ {
+		p := pick(repository.PickOptions{Strategy: repository.StrategyNoRepeat, LastURL: middle.URL})
+		if p.URL == middle.URL {
+			t.Fatal("norepeat strategy picked the last page")
+		}
+	}
+
+	_, err := r.PickRandom(ctx, "alice", repository.PickOptions{Strategy: "unknown"})
+	wantErr(t, "PickRandom with an unknown strategy", err, repository.ErrUnknownStrategy)
+
+	// the only page is picked even if it was the last one
+	save(t, r, page("bob", "https://example.com/only", 0))
+	p, err := r.PickRandom(ctx, "bob", repository.PickOptions{Strategy: repository.StrategyNoRepeat, LastURL: "https://example.com/only"})
+	wantNoErr(t, "PickRandom(norepeat)", err)
+	if p.URL != "https://example.com/only" {
+		t.Errorf("norepeat strategy picked %s", p.URL)
+	}
+}
+
+func testPickByTag(t *testing.T, r repository.Repository) {
+	ctx := context.Background()
+
+	tagged := page("alice", "https://example.com/tagged", time.Hour, "go", "db")
+	save(t, r, tagged, page("alice", "https://example.com/other", time.Hour, "rust"))
+
+	for i := 0; i < 10; i++ {
+		p, err := r.PickRandom(ctx, "alice", repository.PickOptions{Tag: "db"})
+		wantNoErr(t, "PickRandom by tag", err)
+		samePage(t, p, tagged)
+	}
+
+	_, err := r.PickRandom(ctx, "alice", repository.PickOptions{Tag: "missing"})
+	wantErr(t, "PickRandom by a missing tag", err, repository.ErrNoSavedPages)
+
+	_, err = r.PickRandom(ctx, "bob", repository.PickOptions{Tag: "go"})
+	wantErr(t, "PickRandom by a tag of another user", err, repository.ErrNoSavedPages)
+}
+
+func testReview(t *testing.T, r repository.Repository) {
+	ctx := context.Background()
+
+	overdue := page("alice", "https://example.com/overdue", 96*time.Hour)
+	overdue.Review = repository.Review{Interval: 1, Ease: 2.5, Repetitions: 1, DueAt: base.Add(-48 * time.Hour)}
+	due := page("alice", "https://example.com/due", 96*time.Hour)
+	due.Review = repository.Review{Interval: 1, Ease: 2.5, Repetitions: 1, DueAt: base.Add(-time.Hour)}
+	future := page("alice", "https://example.com/future", 96*time.Hour)
+	future.Review = repository.Review{Interval: 3, Ease: 2.5, Repetitions: 2, DueAt: base.Add(48 * time.Hour)}
+	never := page("alice", "https://example.com/never", 24*time.Hour)
+	save(t, r, never, future, due, overdue)
+
+	for _, want := range []*repository.Page{overdue, due, never} {
+		p, err := r.PickDue(ctx, "alice", base)
+		wantNoErr(t, "PickDue", err)
+		if p.URL != want.URL {
+			t.Fatalf("PickDue = %s, want %s", p.URL, want.URL)
+		}
+
+		p.Review = repository.Review{Interval: 6, Ease: 2.6, Repetitions: 3, DueAt: base.Add(144 * time.Hour)}
+		wantNoErr(t, "UpdateReview", r.UpdateReview(ctx, p))
+		samePage(t, mustGet(t, r, "alice", p.URL), p)
+	}
+
+	_, err := r.PickDue(ctx, "alice", base)
+	wantErr(t, "PickDue without due pages", err, repository.ErrNoSavedPages)
+}
+
+func testPurge(t *testing.T, r repository.Repository) {
+	ctx := context.Background()
+
+	removed := page("alice", "https://example.com/removed", time.Hour, "go")
+	kept := page("alice", "https://example.com/kept", time.Hour)
+	other := page("bob", "https://example.com/removed", time.Hour)
+	save(t, r, removed, kept, other)
+
+	wantNoErr(t, "Remove", r.Remove(ctx, removed))
+	wantNoErr(t, "Remove", r.Remove(ctx, other))
+
+	n, err := r.Purge(ctx, time.Now().Add(-time.Hour))
+	wantNoErr(t, "Purge", err)
+	if n != 0 {
+		t.Errorf("Purge of recently removed pages = %d", n)
+	}
+
+	n, err = r.Purge(ctx, time.Now().Add(time.Hour))
+	wantNoErr(t, "Purge", err)
+	if n != 2 {
+		t.Errorf("Purge = %d, want 2", n)
+	}
+
+	trash, err := r.Trash(ctx, "alice")
+	wantNoErr(t, "Trash", err)
+	if len(trash) != 0 {
+		t.Errorf("Trash after Purge = %q", urls(trash))
+	}
+
+	wantNoErr(t, "Restore of a purged page", r.Restore(ctx, removed))
+	_, err = r.Get(ctx, "alice", removed.URL)
+	wantErr(t, "Get of a purged page", err, repository.ErrPageNotFound)
+
+	// a purged page can be saved again without its old tags
+	again := page("alice", removed.URL, 0)
+	save(t, r, again)
+	samePage(t, mustGet(t, r, "alice", again.URL), again)
+	wantCount(t, r, "alice", 2)
+}
+
+func testStats(t *testing.T, r repository.Repository) {
+	ctx := context.Background()
+
+	now := time.Now()
+	a := page("alice", "https://www.example.com/a", 0, "go", "db")
+	a.CreatedAt = now.Add(-48 * time.Hour)
+	b := page("alice", "https://example.com/b", 0, "go")
+	b.CreatedAt = now.Add(-24 * time.Hour)
+	read := page("alice", "https://other.org/read", 0, "go")
+	read.CreatedAt = now.Add(-72 * time.Hour)
+	removed := page("alice", "https://removed.org/x", 0, "removed")
+	save(t, r, a, b, read, removed)
+
+	wantNoErr(t, "MarkRead", r.MarkRead(ctx, read, now.Add(-time.Hour)))
+	wantNoErr(t, "Remove", r.Remove(ctx, removed))
+
+	stats, err := r.Stats(ctx, "alice", now)
+	wantNoErr(t, "Stats", err)
+
+	if stats.Total != 3 || stats.Unread != 2 || stats.ReadThisWeek != 1 {
+		t.Errorf("Stats = %+v, want 3 total, 2 unread and 1 read this week", stats)
+	}
+
+	if age := stats.AvgUnreadAge.Round(time.Hour); age != 36*time.Hour {
+		t.Errorf("AvgUnreadAge = %v, want 36h", age)
+	}
+
+	wantCounts := func(name string, got []repository.Count, want []repository.Count) {
+		t.Helper()
+
+		if !slices.Equal(got, want) {
+			t.Errorf("%s = %+v, want %+v", name, got, want)
+		}
+	}
+
+	wantCounts("TopDomains", stats.TopDomains, []repository.Count{{Name: "example.com", Count: 2}, {Name: "other.org", Count: 1}})
+	wantCounts("TopTags", stats.TopTags, []repository.Count{{Name: "go", Count: 3}, {Name: "db", Count: 1}})
+}
+
+func testPages(t *testing.T, r repository.Repository) {
+	ctx := context.Background()
+
+	old := page("alice", "https://example.com/old", 48*time.Hour)
+	read := page("alice", "https://example.com/read", 24*time.Hour)
+	removed := page("alice", "https://example.com/removed", time.Hour, "x")
+	save(t, r, old, read, removed, page("bob", "https://example.com/bob", 0))
+
+	wantNoErr(t, "MarkRead", r.MarkRead(ctx, read, base))
+	wantNoErr(t, "Remove", r.Remove(ctx, removed))
+
+	pages, err := r.Pages(ctx, "alice")
+	wantNoErr(t, "Pages", err)
+
+	want := []string{removed.URL, read.URL, old.URL}
+	if got := urls(pages); !slices.Equal(got, want) {
+		t.Fatalf("Pages = %q, want %q", got, want)
+	}
+
+	if !pages[0].IsDeleted() || !slices.Equal(pages[0].Tags, []string{"x"}) {
+		t.Errorf("removed page = %+v", pages[0])
+	}
+	if !pages[1].IsRead() {
+		t.Errorf("read page = %+v", pages[1])
+	}
+}
+
+func testEnumerate(t *testing.T, r repository.Repository) {
+	enum, ok := r.(repository.Enumerator)
+	if !ok {
+		t.Skip("repository can't be enumerated")
+	}
+
+	ctx := context.Background()
+
+	removed := page("chat-100", "https://example.com/removed", 0, "x")
+	save(t, r, page("bob", "https://example.com/b", 0), page("alice", "https://example.com/a", 0), removed)
+	wantNoErr(t, "Remove", r.Remove(ctx, removed))
+
+	owners, err := enum.Owners(ctx)
+	wantNoErr(t, "Owners", err)
+	if want := []string{"alice", "bob", "chat-100"}; !slices.Equal(owners, want) {
+		t.Errorf("Owners = %q, want %q", owners, want)
+	}
+
+	var pages []*repository.Page
+	err = enum.EachPage(ctx, "chat-100", func(p *repository.Page) error {
+		pages = append(pages, p)
+		return nil
+	})
+	wantNoErr(t, "EachPage", err)
+	if len(pages) != 1 || !pages[0].IsDeleted() || !slices.Equal(pages[0].Tags, []string{"x"}) {
+		t.Errorf("EachPage = %+v", pages)
+	}
+
+	stop := errors.New("stop")
+	err = enum.EachPage(ctx, "alice", func(p *repository.Page) error {
+		return stop
+	})
+	wantErr(t, "EachPage stopped by fn", err, stop)
+
+	err = enum.EachPage(ctx, "nobody", func(p *repository.Page) error {
+		t.Errorf("EachPage of an unknown owner returned %s", p.URL)
+		return nil
+	})
+	wantNoErr(t, "EachPage of an unknown owner", err)
+}
+
+func testConcurrency(t *testing.T, r repository.Repository) {
+	ctx := context.Background()
+
+	const workers = 8
+	const perWorker = 10
+
+	var wg sync.WaitGroup
+	errs := make(chan error, workers*perWorker*4)
+
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+
+			for i := 0; i < perWorker; i++ {
+				p := page("alice", fmt.Sprintf("https://example.com/%d/%d", w, i), time.Duration(i)*time.Hour, "go")
+				if err := r.Save(ctx, p); err != nil {
+					errs <- err
+				}
+
+				if _, err := r.PickRandom(ctx, "alice", repository.PickOptions{}); err != nil {
+					errs <- err
+				}
+
+				u := &repository.User{ID: w + 1, Username: fmt.Sprintf("user%d", w), LastSeen: base}
+				if err := r.TouchUser(ctx, u); err != nil {
+					errs <- err
+				}
+
+				if err := r.Ban(ctx, w+1); err != nil {
+					errs <- err
+				}
+			}
+		}(w)
+	}
+
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		t.Errorf("concurrent call: %v", err)
+	}
+
+	wantCount(t, r, "alice", workers*perWorker)
+
+	users, err := r.Users(ctx)
+	wantNoErr(t, "Users", err)
+	if len(users) != workers {
+		t.Errorf("Users = %d, want %d", len(users), workers)
+	}
+
+	banned, err := r.Banned(ctx)
+	wantNoErr(t, "Banned", err)
+	if len(banned) != workers {
+		t.Errorf("Banned = %v, want %d users", banned, workers)
+	}
+}
+
+func testContextCancellation(t *testing.T, r repository.Repository) {
+	saved := page("alice", "https://example.com/saved", 0)
+	save(t, r, saved)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err := r.Save(ctx, page("alice", "https://example.com/canceled", 0))
+	wantErr(t, "Save with a canceled context", err, context.Canceled)
+
+	_, err = r.Get(ctx, "alice", saved.URL)
+	wantErr(t, "Get with a canceled context", err, context.Canceled)
+
+	_, err = r.PickRandom(ctx, "alice", repository.PickOptions{})
+	wantErr(t, "PickRandom with a canceled context", err, context.Canceled)
+
+	_, err = r.Pages(ctx, "alice")
+	wantErr(t, "Pages with a canceled context", err, context.Canceled)
+
+	_, err = r.Count(ctx, "alice")
+	wantErr(t, "Count with a canceled context", err, context.Canceled)
+
+	err = r.Remove(ctx, saved)
+	wantErr(t, "Remove with a canceled context", err, context.Canceled)
+
+	err = r.TouchUser(ctx, &repository.User{ID: 1})
+	wantErr(t, "TouchUser with a canceled context", err, context.Canceled)
+
+	_, err = r.Users(ctx)
+	wantErr(t, "Users with a canceled context", err, context.Canceled)
+
+	_, err = r.Memberships(ctx, "alice")
+	wantErr(t, "Memberships with a canceled context", err, context.Canceled)
+
+	_, err = r.IsBanned(ctx, 1)
+	wantErr(t, "IsBanned with a canceled context", err, context.Canceled)
+
+	// nothing is changed by canceled calls
+	wantCount(t, r, "alice", 1)
+	mustGet(t, r, "alice", saved.URL)
+}
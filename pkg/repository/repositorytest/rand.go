@@ -0,0 +1,17 @@
+package repositorytest
+
+import "math/rand"
+
+// FixedRand returns a source of randomness whose Float64 always returns
+// fraction, so that tests decide what a random pick gets.
+func FixedRand(fraction float64) *rand.Rand {
+	return rand.New(fixedSource(fraction))
+}
+
+type fixedSource float64
+
+func (f fixedSource) Int63() int64 {
+	return int64(float64(f) * (1 << 63))
+}
+
+func (f fixedSource) Seed(int64) {}
@@ -0,0 +1,163 @@
+package repositorytest
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"telegrambot/pkg/repository"
+	"testing"
+	"time"
+)
+
+// Factory returns an empty repository. It is called for every subtest, the
+// repository may be cleaned up with t.Cleanup.
+type Factory func(t *testing.T) repository.Repository
+
+// Run checks that the repository behaves the way repository.Repository
+// documents it, so that backends can be swapped without changing the bot.
+// Backends call it from their tests:
+//
+//	func TestRepository(t *testing.T) {
+//		repositorytest.Run(t, func(t *testing.T) repository.Repository {
+//			return files.New(t.TempDir())
+//		})
+//	}
+func Run(t *testing.T, newRepository Factory) {
+	tests := []struct {
+		name string
+		test func(t *testing.T, r repository.Repository)
+	}{
+		{"SaveAndGet", testSaveAndGet},
+		{"SaveReplaces", testSaveReplaces},
+		{"EmptyUser", testEmptyUser},
+		{"Owners", testOwners},
+		{"UnicodeUsername", testUnicodeUsername},
+		{"RemoveAndRestore", testRemoveAndRestore},
+		{"RemoveMissing", testRemoveMissing},
+		{"MarkRead", testMarkRead},
+		{"Snooze", testSnooze},
+		{"PickStrategies", testPickStrategies},
+		{"PickByTag", testPickByTag},
+		{"Review", testReview},
+		{"Purge", testPurge},
+		{"Stats", testStats},
+		{"Pages", testPages},
+		{"Enumerate", testEnumerate},
+		{"Users", testUsers},
+		{"Bans", testBans},
+		{"Collections", testCollections},
+		{"ConcurrentJoin", testConcurrentJoin},
+		{"Concurrency", testConcurrency},
+		{"ContextCancellation", testContextCancellation},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.test(t, newRepository(t))
+		})
+	}
+}
+
+// base is the time pages are created at. Repositories may keep times with
+// a precision of a second, so times are compared by seconds.
+var base = time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+func page(owner string, url string, age time.Duration, tags ...string) *repository.Page {
+	return &repository.Page{
+		URL:       url,
+		Username:  owner,
+		Tags:      tags,
+		CreatedAt: base.Add(-age),
+		ChatID:    42,
+	}
+}
+
+func save(t *testing.T, r repository.Repository, pages ...*repository.Page) {
+	t.Helper()
+
+	for _, p := range pages {
+		if err := r.Save(context.Background(), p); err != nil {
+			t.Fatalf("Save(%s): %v", p.URL, err)
+		}
+	}
+}
+
+func mustGet(t *testing.T, r repository.Repository, owner string, url string) *repository.Page {
+	t.Helper()
+
+	p, err := r.Get(context.Background(), owner, url)
+	if err != nil {
+		t.Fatalf("Get(%s, %s): %v", owner, url, err)
+	}
+
+	return p
+}
+
+func wantErr(t *testing.T, what string, err error, target error) {
+	t.Helper()
+
+	if !errors.Is(err, target) {
+		t.Errorf("%s: got error %v, want %v", what, err, target)
+	}
+}
+
+func wantNoErr(t *testing.T, what string, err error) {
+	t.Helper()
+
+	if err != nil {
+		t.Fatalf("%s: %v", what, err)
+	}
+}
+
+func wantCount(t *testing.T, r repository.Repository, owner string, want int) {
+	t.Helper()
+
+	n, err := r.Count(context.Background(), owner)
+	wantNoErr(t, "Count", err)
+	if n != want {
+		t.Errorf("Count(%s) = %d, want %d", owner, n, want)
+	}
+}
+
+func sameTime(a, b time.Time) bool {
+	return a.IsZero() == b.IsZero() && a.Unix() == b.Unix()
+}
+
+// samePage compares everything repositories store.
+func samePage(t *testing.T, got, want *repository.Page) {
+	t.Helper()
+
+	switch {
+	case got.URL != want.URL || got.Username != want.Username:
+		t.Errorf("got page %s of %s, want %s of %s", got.URL, got.Username, want.URL, want.Username)
+	case !slices.Equal(got.Tags, want.Tags) && len(got.Tags)+len(want.Tags) > 0:
+		t.Errorf("page %s: tags %q, want %q", want.URL, got.Tags, want.Tags)
+	case got.ChatID != want.ChatID:
+		t.Errorf("page %s: chat %d, want %d", want.URL, got.ChatID, want.ChatID)
+	case !sameTime(got.CreatedAt, want.CreatedAt):
+		t.Errorf("page %s: created at %v, want %v", want.URL, got.CreatedAt, want.CreatedAt)
+	case !sameTime(got.ResurfaceAt, want.ResurfaceAt):
+		t.Errorf("page %s: resurface at %v, want %v", want.URL, got.ResurfaceAt, want.ResurfaceAt)
+	case !sameTime(got.ReadAt, want.ReadAt):
+		t.Errorf("page %s: read at %v, want %v", want.URL, got.ReadAt, want.ReadAt)
+	case got.Review.Interval != want.Review.Interval || got.Review.Ease != want.Review.Ease ||
+		got.Review.Repetitions != want.Review.Repetitions || !sameTime(got.Review.DueAt, want.Review.DueAt):
+		t.Errorf("page %s: review %+v, want %+v", want.URL, got.Review, want.Review)
+	}
+}
+
+func urls(pages []*repository.Page) []string {
+	res := make([]string, 0, len(pages))
+	for _, p := range pages {
+		res = append(res, p.URL)
+	}
+
+	return res
+}
+
+func sortedURLs(pages []*repository.Page) []string {
+	res := urls(pages)
+	slices.Sort(res)
+
+	return res
+}
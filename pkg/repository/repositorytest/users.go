@@ -0,0 +1,206 @@
+package repositorytest
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+	"telegrambot/pkg/repository"
+	"testing"
+	"time"
+)
+
+func testUsers(t *testing.T, r repository.Repository) {
+	ctx := context.Background()
+
+	first := base.Add(-time.Hour)
+	wantNoErr(t, "TouchUser", r.TouchUser(ctx, &repository.User{ID: 2, Username: "bob", ChatID: 20, LastSeen: first}))
+	wantNoErr(t, "TouchUser", r.TouchUser(ctx, &repository.User{ID: 1, FirstName: "Алиса", LastSeen: first}))
+
+	// a message in a group keeps the private chat
+	wantNoErr(t, "TouchUser", r.TouchUser(ctx, &repository.User{ID: 2, Username: "bobby", LastSeen: base}))
+
+	users, err := r.Users(ctx)
+	wantNoErr(t, "Users", err)
+	if len(users) != 2 || users[0].ID != 1 || users[1].ID != 2 {
+		t.Fatalf("Users = %+v", users)
+	}
+
+	bob := users[1]
+	if bob.Username != "bobby" || bob.ChatID != 20 || !sameTime(bob.FirstSeen, first) || !sameTime(bob.LastSeen, base) {
+		t.Errorf("updated user = %+v", bob)
+	}
+	if bob.PagesOwner() != "bobby" || users[0].PagesOwner() != "user-1" || users[0].FirstName != "Алиса" {
+		t.Errorf("users = %+v, %+v", users[0], bob)
+	}
+
+	wantNoErr(t, "MarkBlocked", r.MarkBlocked(ctx, 2, base))
+	users, err = r.Users(ctx)
+	wantNoErr(t, "Users", err)
+	if !users[1].IsBlocked() || users[0].IsBlocked() {
+		t.Errorf("blocked users = %+v, %+v", users[0], users[1])
+	}
+
+	// only a message in the private chat means the bot is unblocked
+	wantNoErr(t, "TouchUser", r.TouchUser(ctx, &repository.User{ID: 2, Username: "bobby", LastSeen: base}))
+	users, err = r.Users(ctx)
+	wantNoErr(t, "Users", err)
+	if !users[1].IsBlocked() {
+		t.Error("user is unblocked by a group message")
+	}
+
+	wantNoErr(t, "TouchUser", r.TouchUser(ctx, &repository.User{ID: 2, Username: "bobby", ChatID: 20, LastSeen: base}))
+	users, err = r.Users(ctx)
+	wantNoErr(t, "Users", err)
+	if users[1].IsBlocked() {
+		t.Error("user is still blocked after a private message")
+	}
+
+	wantNoErr(t, "MarkBlocked of an unknown user", r.MarkBlocked(ctx, 99, base))
+}
+
+func testBans(t *testing.T, r repository.Repository) {
+	ctx := context.Background()
+
+	for _, id := range []int{3, 1, 2, 1} {
+		wantNoErr(t, "Ban", r.Ban(ctx, id))
+	}
+	wantNoErr(t, "Unban", r.Unban(ctx, 2))
+	wantNoErr(t, "Unban of a user who is not banned", r.Unban(ctx, 5))
+
+	banned, err := r.Banned(ctx)
+	wantNoErr(t, "Banned", err)
+	if !slices.Equal(banned, []int{1, 3}) {
+		t.Errorf("Banned = %v, want [1 3]", banned)
+	}
+
+	for id, want := range map[int]bool{1: true, 2: false, 3: true, 4: false} {
+		got, err := r.IsBanned(ctx, id)
+		wantNoErr(t, "IsBanned", err)
+		if got != want {
+			t.Errorf("IsBanned(%d) = %v, want %v", id, got, want)
+		}
+	}
+}
+
+func testCollections(t *testing.T, r repository.Repository) {
+	ctx := context.Background()
+
+	c := &repository.Collection{ID: "c1", Name: "Книги", Owner: "alice"}
+	wantNoErr(t, "CreateCollection", r.CreateCollection(ctx, c))
+	wantNoErr(t, "CreateCollection", r.CreateCollection(ctx, &repository.Collection{ID: "c2", Name: "Articles", Owner: "bob"}))
+
+	m, err := r.Membership(ctx, "c1", "alice")
+	wantNoErr(t, "Membership", err)
+	if m.Role != repository.RoleOwner || *m.Collection != *c {
+		t.Errorf("owner membership = %+v", m)
+	}
+
+	_, err = r.Membership(ctx, "c1", "bob")
+	wantErr(t, "Membership of a stranger", err, repository.ErrNotMember)
+
+	viewer, err := r.CreateInvite(ctx, "c1", repository.RoleViewer)
+	wantNoErr(t, "CreateInvite", err)
+	again, err := r.CreateInvite(ctx, "c1", repository.RoleViewer)
+	wantNoErr(t, "CreateInvite", err)
+	if viewer != again {
+		t.Errorf("CreateInvite returned %q and %q for the same role", viewer, again)
+	}
+
+	editor, err := r.CreateInvite(ctx, "c1", repository.RoleEditor)
+	wantNoErr(t, "CreateInvite", err)
+	if editor == viewer {
+		t.Error("CreateInvite returned the same code for different roles")
+	}
+
+	_, err = r.Join(ctx, "missing", "bob")
+	wantErr(t, "Join with an unknown code", err, repository.ErrInviteNotFound)
+
+	m, err = r.Join(ctx, editor, "bob")
+	wantNoErr(t, "Join", err)
+	if m.Role != repository.RoleEditor || m.Collection.ID != "c1" {
+		t.Errorf("Join = %+v", m)
+	}
+
+	// joining again keeps the higher role
+	m, err = r.Join(ctx, viewer, "bob")
+	wantNoErr(t, "Join", err)
+	if m.Role != repository.RoleEditor {
+		t.Errorf("role after joining as viewer = %s", m.Role)
+	}
+
+	m, err = r.Join(ctx, editor, "alice")
+	wantNoErr(t, "Join", err)
+	if m.Role != repository.RoleOwner {
+		t.Errorf("owner role after joining as editor = %s", m.Role)
+	}
+
+	memberships, err := r.Memberships(ctx, "bob")
+	wantNoErr(t, "Memberships", err)
+
+	var names []string
+	for _, m := range memberships {
+		names = append(names, m.Collection.Name)
+	}
+	if !slices.Equal(names, []string{"Articles", "Книги"}) {
+		t.Errorf("Memberships = %q", names)
+	}
+
+	memberships, err = r.Memberships(ctx, "nobody")
+	wantNoErr(t, "Memberships", err)
+	if len(memberships) != 0 {
+		t.Errorf("Memberships of a stranger = %d", len(memberships))
+	}
+}
+
+func testConcurrentJoin(t *testing.T, r repository.Repository) {
+	ctx := context.Background()
+
+	wantNoErr(t, "CreateCollection", r.CreateCollection(ctx, &repository.Collection{ID: "c1", Name: "Книги", Owner: "alice"}))
+
+	code, err := r.CreateInvite(ctx, "c1", repository.RoleViewer)
+	wantNoErr(t, "CreateInvite", err)
+
+	const members = 8
+
+	var wg sync.WaitGroup
+	errs := make(chan error, members*2)
+
+	for i := 0; i < members; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			if _, err := r.Join(ctx, code, fmt.Sprintf("user%d", i)); err != nil {
+				errs <- err
+			}
+
+			// invites of other roles are created meanwhile
+			if _, err := r.CreateInvite(ctx, "c1", repository.RoleEditor); err != nil {
+				errs <- err
+			}
+		}(i)
+	}
+
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		t.Errorf("concurrent call: %v", err)
+	}
+
+	// every join is kept, none is overwritten by another one
+	for i := 0; i < members; i++ {
+		m, err := r.Membership(ctx, "c1", fmt.Sprintf("user%d", i))
+		wantNoErr(t, "Membership", err)
+		if err == nil && m.Role != repository.RoleViewer {
+			t.Errorf("role of user%d = %s, want %s", i, m.Role, repository.RoleViewer)
+		}
+	}
+
+	again, err := r.CreateInvite(ctx, "c1", repository.RoleViewer)
+	wantNoErr(t, "CreateInvite", err)
+	if again != code {
+		t.Errorf("viewer invite = %q after concurrent calls, want %q", again, code)
+	}
+}
@@ -0,0 +1,82 @@
+package repository
+
+import (
+	"errors"
+	"math"
+	"time"
+)
+
+var ErrUnknownGrade = errors.New("unknown review grade")
+
+// Grade is the quality of a recall in terms of the SM-2 algorithm.
+type Grade int
+
+const (
+	GradeAgain Grade = 1
+	GradeGood  Grade = 4
+	GradeEasy  Grade = 5
+)
+
+var grades = map[string]Grade{
+	"again": GradeAgain,
+	"good":  GradeGood,
+	"easy":  GradeEasy,
+}
+
+// ParseGrade returns the grade named again, good or easy.
+func ParseGrade(s string) (Grade, error) {
+	grade, ok := grades[s]
+	if !ok {
+		return 0, ErrUnknownGrade
+	}
+
+	return grade, nil
+}
+
+const (
+	defaultEase = 2.5
+	minEase     = 1.3
+	day         = 24 * time.Hour
+)
+
+// Review is a spaced repetition schedule of a page.
+type Review struct {
+	// Interval is the number of days between the last and the next review.
+	Interval    int
+	Ease        float64
+	Repetitions int
+	// DueAt is zero for pages which were never reviewed.
+	DueAt time.Time
+}
+
+func (r Review) IsDue(now time.Time) bool {
+	return !r.DueAt.After(now)
+}
+
+// Next returns the schedule after the page was reviewed with the grade at now.
+func (r Review) Next(grade Grade, now time.Time) Review {
+	if r.Ease == 0 {
+		r.Ease = defaultEase
+	}
+
+	if grade < 3 {
+		r.Repetitions = 0
+		r.Interval = 1
+	} else {
+		switch r.Repetitions {
+		case 0:
+			r.Interval = 1
+		case 1:
+			r.Interval = 6
+		default:
+			r.Interval = int(math.Round(float64(r.Interval) * r.Ease))
+		}
+		r.Repetitions++
+	}
+
+	q := float64(5 - grade)
+	r.Ease = math.Max(minEase, r.Ease+0.1-q*(0.08+q*0.02))
+	r.DueAt = now.Add(time.Duration(r.Interval) * day)
+
+	return r
+}
@@ -0,0 +1,65 @@
+package sqlite
+
+import (
+	"context"
+	"telegrambot/internal/e"
+)
+
+// Ban bans the user.
+func (r *RepositorySQLite) Ban(ctx context.Context, userID int) error {
+	q := `INSERT OR IGNORE INTO banned_users (user_id) VALUES (?)`
+
+	if _, err := r.db.ExecContext(ctx, q, userID); err != nil {
+		return e.Wrap("can't ban user", err)
+	}
+
+	return nil
+}
+
+// Unban lifts the ban of the user.
+func (r *RepositorySQLite) Unban(ctx context.Context, userID int) error {
+	q := `DELETE FROM banned_users WHERE user_id = ?`
+
+	if _, err := r.db.ExecContext(ctx, q, userID); err != nil {
+		return e.Wrap("can't unban user", err)
+	}
+
+	return nil
+}
+
+// IsBanned checks if the user is banned.
+func (r *RepositorySQLite) IsBanned(ctx context.Context, userID int) (bool, error) {
+	q := `SELECT COUNT(*) FROM banned_users WHERE user_id = ?`
+
+	var count int
+
+	if err := r.db.QueryRowContext(ctx, q, userID).Scan(&count); err != nil {
+		return false, e.Wrap("can't check if user is banned", err)
+	}
+
+	return count > 0, nil
+}
+
+// Banned returns ids of banned users.
+func (r *RepositorySQLite) Banned(ctx context.Context) (ids []int, err error) {
+	defer func() {
+		err = e.WrapIfErr("can't get banned users", err)
+	}()
+
+	rows, err := r.db.QueryContext(ctx, `SELECT user_id FROM banned_users ORDER BY user_id`)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+
+		ids = append(ids, id)
+	}
+
+	return ids, rows.Err()
+}
@@ -0,0 +1,163 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"telegrambot/internal/e"
+	"telegrambot/pkg/repository"
+)
+
+// CreateCollection saves collection and makes its owner a member.
+func (r *RepositorySQLite) CreateCollection(ctx context.Context, c *repository.Collection) (err error) {
+	defer func() {
+		err = e.WrapIfErr("can't create collection", err)
+	}()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	q := `INSERT INTO collections (id, name, owner) VALUES (?, ?, ?)`
+
+	if _, err = tx.ExecContext(ctx, q, c.ID, c.Name, c.Owner); err != nil {
+		return err
+	}
+
+	q = `INSERT INTO collection_members (collection_id, username, role) VALUES (?, ?, ?)`
+
+	if _, err = tx.ExecContext(ctx, q, c.ID, c.Owner, repository.RoleOwner); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// CreateInvite returns invite code for the collection and role.
+func (r *RepositorySQLite) CreateInvite(ctx context.Context, collectionID string, role repository.Role) (code string, err error) {
+	defer func() {
+		err = e.WrapIfErr("can't create invite", err)
+	}()
+
+	q := `SELECT code FROM collection_invites WHERE collection_id = ? AND role = ?`
+
+	err = r.db.QueryRowContext(ctx, q, collectionID, role).Scan(&code)
+	if err == nil {
+		return code, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return "", err
+	}
+
+	if code, err = repository.NewID(); err != nil {
+		return "", err
+	}
+
+	q = `INSERT INTO collection_invites (code, collection_id, role) VALUES (?, ?, ?)`
+
+	if _, err = r.db.ExecContext(ctx, q, code, collectionID, role); err != nil {
+		return "", err
+	}
+
+	return code, nil
+}
+
+// Join adds user to the collection the invite code was created for.
+func (r *RepositorySQLite) Join(ctx context.Context, code string, username string) (m *repository.Membership, err error) {
+	defer func() {
+		if !errors.Is(err, repository.ErrInviteNotFound) {
+			err = e.WrapIfErr("can't join collection", err)
+		}
+	}()
+
+	q := `SELECT c.id, c.name, c.owner, i.role FROM collection_invites i
+JOIN collections c ON c.id = i.collection_id
+WHERE i.code = ?`
+
+	m, err = scanMembership(r.db.QueryRowContext(ctx, q, code))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, repository.ErrInviteNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	current, err := r.Membership(ctx, m.Collection.ID, username)
+	switch {
+	case errors.Is(err, repository.ErrNotMember):
+	case err != nil:
+		return nil, err
+	default:
+		m.Role = m.Role.Max(current.Role)
+	}
+
+	q = `INSERT OR REPLACE INTO collection_members (collection_id, username, role) VALUES (?, ?, ?)`
+
+	if _, err = r.db.ExecContext(ctx, q, m.Collection.ID, username, m.Role); err != nil {
+		return nil, err
+	}
+
+	return m, nil
+}
+
+// Memberships returns collections the user is a member of.
+func (r *RepositorySQLite) Memberships(ctx context.Context, username string) (res []*repository.Membership, err error) {
+	defer func() {
+		err = e.WrapIfErr("can't get memberships", err)
+	}()
+
+	q := `SELECT c.id, c.name, c.owner, m.role FROM collection_members m
+JOIN collections c ON c.id = m.collection_id
+WHERE m.username = ? ORDER BY c.name`
+
+	rows, err := r.db.QueryContext(ctx, q, username)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	for rows.Next() {
+		m, err := scanMembership(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		res = append(res, m)
+	}
+
+	return res, rows.Err()
+}
+
+// Membership returns membership of the user in the collection.
+func (r *RepositorySQLite) Membership(ctx context.Context, collectionID string, username string) (*repository.Membership, error) {
+	q := `SELECT c.id, c.name, c.owner, m.role FROM collection_members m
+JOIN collections c ON c.id = m.collection_id
+WHERE m.collection_id = ? AND m.username = ?`
+
+	m, err := scanMembership(r.db.QueryRowContext(ctx, q, collectionID, username))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, repository.ErrNotMember
+	}
+	if err != nil {
+		return nil, e.Wrap("can't get membership", err)
+	}
+
+	return m, nil
+}
+
+func scanMembership(s scanner) (*repository.Membership, error) {
+	var c repository.Collection
+	var role string
+
+	if err := s.Scan(&c.ID, &c.Name, &c.Owner, &role); err != nil {
+		return nil, err
+	}
+
+	return &repository.Membership{Collection: &c, Role: repository.Role(role)}, nil
+}
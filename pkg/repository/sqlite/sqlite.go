@@ -4,19 +4,32 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"fmt"
+	"math/rand"
+	"strings"
 	"telegrambot/internal/e"
+	"telegrambot/internal/logging"
 	"telegrambot/pkg/repository"
+	"time"
 
 	_ "modernc.org/sqlite"
 )
 
+const pageColumns = `url, username, chat_id, created_at, resurface_at, review_interval, review_ease, review_repetitions, due_at, read_at, deleted_at`
+
 type RepositorySQLite struct {
-	db *sql.DB
+	db  *sql.DB
+	rnd *rand.Rand
 }
 
 // New creates new SQLite repository.
 func New(path string) (*RepositorySQLite, error) {
-	db, err := sql.Open("sqlite", path)
+	return NewWithRand(path, repository.NewRand(nil))
+}
+
+// NewWithRand creates new SQLite repository which uses rnd to pick pages.
+func NewWithRand(path string, rnd *rand.Rand) (*RepositorySQLite, error) {
+	db, err := sql.Open("sqlite", dsn(path))
 	if err != nil {
 		return nil, e.Wrap("can't open sqlite db", err)
 	}
@@ -25,42 +38,206 @@ func New(path string) (*RepositorySQLite, error) {
 		return nil, e.Wrap("can't ping sqlite db", err)
 	}
 
-	return &RepositorySQLite{db: db}, nil
+	return &RepositorySQLite{db: db, rnd: rnd}, nil
 }
 
-// Save saves page to repository.
-func (r *RepositorySQLite) Save(ctx context.Context, p *repository.Page) error {
-	q := `INSERT INTO pages (url, username) VALUES (?, ?)`
+// dsn makes concurrent calls wait for each other instead of failing with
+// SQLITE_BUSY: connections wait for locks, and transactions take the write
+// lock up front, as they all write after reading.
+func dsn(path string) string {
+	sep := "?"
+	if strings.Contains(path, "?") {
+		sep = "&"
+	}
 
-	if _, err := r.db.ExecContext(ctx, q, p.URL, p.Username); err != nil {
-		return e.Wrap("can't save page", err)
+	return path + sep + "_pragma=busy_timeout(5000)&_txlock=immediate"
+}
+
+// Ping checks that the database can serve queries.
+func (r *RepositorySQLite) Ping(ctx context.Context) error {
+	var one int
+	if err := r.db.QueryRowContext(ctx, `SELECT 1`).Scan(&one); err != nil {
+		return e.Wrap("can't ping sqlite db", err)
 	}
 
 	return nil
 }
 
-// PickRandom pick random page from repository.
-func (r *RepositorySQLite) PickRandom(ctx context.Context, username string) (*repository.Page, error) {
-	q := `SELECT url FROM pages WHERE username = ? ORDER BY RANDOM() LIMIT 1`
+// Save saves page to repository.
+func (r *RepositorySQLite) Save(ctx context.Context, p *repository.Page) (err error) {
+	defer func() {
+		err = e.WrapIfErr("can't save page", err)
+	}()
+
+	createdAt := p.CreatedAt
+	if createdAt.IsZero() {
+		createdAt = time.Now()
+	}
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	q := `DELETE FROM pages WHERE url = ? and username = ?;
+DELETE FROM page_tags WHERE url = ? and username = ?`
+
+	if _, err = tx.ExecContext(ctx, q, p.URL, p.Username, p.URL, p.Username); err != nil {
+		return err
+	}
+
+	q = `INSERT INTO pages (` + pageColumns + `, domain) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+
+	_, err = tx.ExecContext(ctx, q, p.URL, p.Username, p.ChatID, createdAt.Unix(), toUnix(p.ResurfaceAt),
+		p.Review.Interval, p.Review.Ease, p.Review.Repetitions, toUnix(p.Review.DueAt), toUnix(p.ReadAt),
+		toUnix(p.DeletedAt), p.Domain())
+	if err != nil {
+		return err
+	}
+
+	q = `INSERT INTO page_tags (url, username, tag) VALUES (?, ?, ?)`
+
+	for _, tag := range p.Tags {
+		if _, err = tx.ExecContext(ctx, q, p.URL, p.Username, tag); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// PickRandom picks page from repository according to opts.
+func (r *RepositorySQLite) PickRandom(ctx context.Context, username string, opts repository.PickOptions) (page *repository.Page, err error) {
+	defer func() {
+		if !errors.Is(err, repository.ErrNoSavedPages) {
+			err = e.WrapIfErr("can't pick random page", err)
+		}
+	}()
+
+	where := `username = ? AND deleted_at IS NULL AND read_at IS NULL AND resurface_at IS NULL`
+	args := []any{username}
+
+	if opts.Tag != "" {
+		where += ` AND EXISTS (SELECT 1 FROM page_tags t WHERE t.url = pages.url AND t.username = pages.username AND t.tag = ?)`
+		args = append(args, opts.Tag)
+	}
+
+	switch opts.Strategy {
+	case repository.StrategyOldest:
+		page, err = r.pickOne(ctx, where, args, `created_at ASC, rowid ASC`, 0)
+	case repository.StrategyNewest:
+		page, err = r.pickOne(ctx, where, args, `created_at DESC, rowid DESC`, 0)
+	case repository.StrategyWeighted:
+		page, err = r.pickWeighted(ctx, where, args)
+	case repository.StrategyNoRepeat:
+		page, err = r.pickUniform(ctx, where+` AND url <> ?`, append(args, opts.LastURL))
+		if errors.Is(err, repository.ErrNoSavedPages) {
+			page, err = r.pickUniform(ctx, where, args)
+		}
+	case repository.StrategyReview:
+		page, err = r.pickUniform(ctx, where+` AND (due_at IS NULL OR due_at <= ?)`, append(args, time.Now().Unix()))
+	case repository.StrategyRandom, "":
+		page, err = r.pickUniform(ctx, where, args)
+	default:
+		return nil, repository.ErrUnknownStrategy
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if page.Tags, err = r.tags(ctx, page); err != nil {
+		return nil, err
+	}
+
+	return page, nil
+}
+
+// pickUniform picks page matching where with equal probability.
+func (r *RepositorySQLite) pickUniform(ctx context.Context, where string, args []any) (*repository.Page, error) {
+	var count int
 
-	var url string
+	q := `SELECT COUNT(*) FROM pages WHERE ` + where
 
-	err := r.db.QueryRowContext(ctx, q, username).Scan(&url)
+	if err := r.db.QueryRowContext(ctx, q, args...).Scan(&count); err != nil {
+		return nil, err
+	}
+
+	if count == 0 {
+		return nil, repository.ErrNoSavedPages
+	}
+
+	return r.pickOne(ctx, where, args, `rowid`, r.rnd.Intn(count))
+}
+
+// pickWeighted picks page matching where with probability growing with its age.
+func (r *RepositorySQLite) pickWeighted(ctx context.Context, where string, args []any) (*repository.Page, error) {
+	q := `SELECT ` + pageColumns + ` FROM pages WHERE ` + where + ` ORDER BY rowid`
+
+	rows, err := r.db.QueryContext(ctx, q, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	var candidates []*repository.Page
+	for rows.Next() {
+		p, err := scanPage(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		candidates = append(candidates, p)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return repository.Choose(candidates, repository.PickOptions{Strategy: repository.StrategyWeighted}, r.rnd, time.Now())
+}
+
+func (r *RepositorySQLite) pickOne(ctx context.Context, where string, args []any, order string, offset int) (*repository.Page, error) {
+	q := `SELECT ` + pageColumns + ` FROM pages WHERE ` + where + ` ORDER BY ` + order + ` LIMIT 1 OFFSET ?`
+
+	p, err := scanPage(r.db.QueryRowContext(ctx, q, append(args, offset)...))
 	if errors.Is(err, sql.ErrNoRows) {
 		return nil, repository.ErrNoSavedPages
 	}
+
+	return p, err
+}
+
+func (r *RepositorySQLite) tags(ctx context.Context, p *repository.Page) ([]string, error) {
+	q := `SELECT tag FROM page_tags WHERE url = ? AND username = ? ORDER BY rowid`
+
+	rows, err := r.db.QueryContext(ctx, q, p.URL, p.Username)
 	if err != nil {
-		return nil, e.Wrap("can't pick random page", err)
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	var tags []string
+	for rows.Next() {
+		var tag string
+		if err := rows.Scan(&tag); err != nil {
+			return nil, err
+		}
+
+		tags = append(tags, tag)
 	}
 
-	return &repository.Page{URL: url, Username: username}, nil
+	return tags, rows.Err()
 }
 
-// Remove removes page from repository.
+// Remove moves page to the trash.
 func (r *RepositorySQLite) Remove(ctx context.Context, p *repository.Page) error {
-	q := `DELETE FROM pages WHERE url = ? and username = ?`
+	q := `UPDATE pages SET deleted_at = ? WHERE url = ? and username = ? AND deleted_at IS NULL`
 
-	if _, err := r.db.ExecContext(ctx, q, p.URL, p.Username); err != nil {
+	if _, err := r.db.ExecContext(ctx, q, time.Now().Unix(), p.URL, p.Username); err != nil {
 		return e.Wrap("can't remove page", err)
 	}
 
@@ -69,7 +246,7 @@ func (r *RepositorySQLite) Remove(ctx context.Context, p *repository.Page) error
 
 // IsExists checks if page exists in repository.
 func (r *RepositorySQLite) IsExists(ctx context.Context, p *repository.Page) (bool, error) {
-	q := `SELECT COUNT(*) FROM pages WHERE url = ? and username = ?`
+	q := `SELECT COUNT(*) FROM pages WHERE url = ? and username = ? AND deleted_at IS NULL AND read_at IS NULL`
 
 	var count int
 
@@ -80,6 +257,390 @@ func (r *RepositorySQLite) IsExists(ctx context.Context, p *repository.Page) (bo
 	return count > 0, nil
 }
 
+// Count counts pages which are neither read nor removed.
+func (r *RepositorySQLite) Count(ctx context.Context, username string) (int, error) {
+	q := `SELECT COUNT(*) FROM pages WHERE username = ? AND deleted_at IS NULL AND read_at IS NULL`
+
+	var count int
+
+	if err := r.db.QueryRowContext(ctx, q, username).Scan(&count); err != nil {
+		return 0, e.Wrap("can't count pages", err)
+	}
+
+	return count, nil
+}
+
+// MarkRead marks page as read.
+func (r *RepositorySQLite) MarkRead(ctx context.Context, p *repository.Page, at time.Time) error {
+	q := `UPDATE pages SET read_at = ? WHERE url = ? and username = ? AND deleted_at IS NULL`
+
+	res, err := r.db.ExecContext(ctx, q, at.Unix(), p.URL, p.Username)
+	if err != nil {
+		return e.Wrap("can't mark page as read", err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return e.Wrap("can't mark page as read", err)
+	}
+	if n == 0 {
+		return e.Wrap("can't mark page as read", repository.ErrPageNotFound)
+	}
+
+	return nil
+}
+
+// Snooze hides page from PickRandom until the given time.
+func (r *RepositorySQLite) Snooze(ctx context.Context, p *repository.Page, until time.Time) (err error) {
+	defer func() {
+		err = e.WrapIfErr("can't snooze page", err)
+	}()
+
+	q := `UPDATE pages SET chat_id = ?, resurface_at = ?, read_at = NULL, deleted_at = NULL WHERE url = ? and username = ?`
+
+	res, err := r.db.ExecContext(ctx, q, p.ChatID, until.Unix(), p.URL, p.Username)
+	if err != nil {
+		return err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if n > 0 {
+		return nil
+	}
+
+	snoozed := *p
+	snoozed.ResurfaceAt = until
+
+	return r.Save(ctx, &snoozed)
+}
+
+// DueSnoozed returns snoozed pages which are due and postpones them until retryAt.
+func (r *RepositorySQLite) DueSnoozed(ctx context.Context, now time.Time, retryAt time.Time) (pages []*repository.Page, err error) {
+	defer func() {
+		err = e.WrapIfErr("can't get due snoozed pages", err)
+	}()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	q := `SELECT ` + pageColumns + ` FROM pages WHERE resurface_at <= ? AND deleted_at IS NULL`
+
+	rows, err := tx.QueryContext(ctx, q, now.Unix())
+	if err != nil {
+		return nil, err
+	}
+
+	for rows.Next() {
+		p, err := scanPage(rows)
+		if err != nil {
+			_ = rows.Close()
+			return nil, err
+		}
+
+		pages = append(pages, p)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	if err = rows.Close(); err != nil {
+		return nil, err
+	}
+
+	q = `UPDATE pages SET resurface_at = ? WHERE resurface_at <= ? AND deleted_at IS NULL`
+
+	if _, err = tx.ExecContext(ctx, q, retryAt.Unix(), now.Unix()); err != nil {
+		return nil, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return pages, nil
+}
+
+// Unsnooze puts the page back into rotation.
+func (r *RepositorySQLite) Unsnooze(ctx context.Context, p *repository.Page) error {
+	q := `UPDATE pages SET resurface_at = NULL WHERE url = ? AND username = ?`
+
+	if _, err := r.db.ExecContext(ctx, q, p.URL, p.Username); err != nil {
+		return e.Wrap("can't unsnooze page", err)
+	}
+
+	return nil
+}
+
+// Get returns page saved by the user.
+func (r *RepositorySQLite) Get(ctx context.Context, username string, url string) (*repository.Page, error) {
+	q := `SELECT ` + pageColumns + ` FROM pages WHERE url = ? AND username = ? AND deleted_at IS NULL LIMIT 1`
+
+	p, err := scanPage(r.db.QueryRowContext(ctx, q, url, username))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, repository.ErrPageNotFound
+	}
+	if err != nil {
+		return nil, e.Wrap("can't get page", err)
+	}
+
+	if p.Tags, err = r.tags(ctx, p); err != nil {
+		return nil, e.Wrap("can't get page", err)
+	}
+
+	return p, nil
+}
+
+// UpdateReview stores review schedule of the page.
+func (r *RepositorySQLite) UpdateReview(ctx context.Context, p *repository.Page) error {
+	q := `UPDATE pages SET review_interval = ?, review_ease = ?, review_repetitions = ?, due_at = ? WHERE url = ? AND username = ? AND deleted_at IS NULL`
+
+	res, err := r.db.ExecContext(ctx, q, p.Review.Interval, p.Review.Ease, p.Review.Repetitions, toUnix(p.Review.DueAt), p.URL, p.Username)
+	if err != nil {
+		return e.Wrap("can't update review", err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return e.Wrap("can't update review", err)
+	}
+	if n == 0 {
+		return repository.ErrPageNotFound
+	}
+
+	return nil
+}
+
+// PickDue picks the most overdue page in review.
+func (r *RepositorySQLite) PickDue(ctx context.Context, username string, now time.Time) (*repository.Page, error) {
+	where := `username = ? AND deleted_at IS NULL AND read_at IS NULL AND resurface_at IS NULL AND (due_at IS NULL OR due_at <= ?)`
+
+	p, err := r.pickOne(ctx, where, []any{username, now.Unix()}, `due_at IS NULL, due_at, created_at, rowid`, 0)
+	if errors.Is(err, repository.ErrNoSavedPages) {
+		return nil, err
+	}
+	if err != nil {
+		return nil, e.Wrap("can't pick due page", err)
+	}
+
+	if p.Tags, err = r.tags(ctx, p); err != nil {
+		return nil, e.Wrap("can't pick due page", err)
+	}
+
+	return p, nil
+}
+
+// LastRemoved returns the page which was most recently removed or read since the given time.
+func (r *RepositorySQLite) LastRemoved(ctx context.Context, username string, since time.Time) (*repository.Page, error) {
+	q := `SELECT ` + pageColumns + ` FROM pages
+WHERE username = ? AND MAX(COALESCE(deleted_at, 0), COALESCE(read_at, 0)) >= ?
+ORDER BY MAX(COALESCE(deleted_at, 0), COALESCE(read_at, 0)) DESC, rowid DESC LIMIT 1`
+
+	p, err := scanPage(r.db.QueryRowContext(ctx, q, username, since.Unix()))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, repository.ErrPageNotFound
+	}
+	if err != nil {
+		return nil, e.Wrap("can't get last removed page", err)
+	}
+
+	return p, nil
+}
+
+// Restore returns removed or read page back to the list.
+func (r *RepositorySQLite) Restore(ctx context.Context, p *repository.Page) error {
+	q := `UPDATE pages SET deleted_at = NULL, read_at = NULL WHERE url = ? AND username = ?`
+
+	if _, err := r.db.ExecContext(ctx, q, p.URL, p.Username); err != nil {
+		return e.Wrap("can't restore page", err)
+	}
+
+	return nil
+}
+
+// Trash returns removed pages, most recently removed first.
+func (r *RepositorySQLite) Trash(ctx context.Context, username string) (pages []*repository.Page, err error) {
+	defer func() {
+		err = e.WrapIfErr("can't get trash", err)
+	}()
+
+	q := `SELECT ` + pageColumns + ` FROM pages WHERE username = ? AND deleted_at IS NOT NULL ORDER BY deleted_at DESC, rowid DESC`
+
+	rows, err := r.db.QueryContext(ctx, q, username)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	for rows.Next() {
+		p, err := scanPage(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		pages = append(pages, p)
+	}
+
+	return pages, rows.Err()
+}
+
+// Pages returns all pages of the user, newest first.
+func (r *RepositorySQLite) Pages(ctx context.Context, username string) (pages []*repository.Page, err error) {
+	defer func() {
+		err = e.WrapIfErr("can't get pages", err)
+	}()
+
+	q := `SELECT ` + pageColumns + ` FROM pages WHERE username = ? ORDER BY created_at DESC, rowid DESC`
+
+	rows, err := r.db.QueryContext(ctx, q, username)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	for rows.Next() {
+		p, err := scanPage(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		pages = append(pages, p)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	tags, err := r.userTags(ctx, username)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, p := range pages {
+		p.Tags = tags[p.URL]
+	}
+
+	return pages, nil
+}
+
+// userTags returns tags of all pages of the user by page url.
+func (r *RepositorySQLite) userTags(ctx context.Context, username string) (map[string][]string, error) {
+	q := `SELECT url, tag FROM page_tags WHERE username = ? ORDER BY rowid`
+
+	rows, err := r.db.QueryContext(ctx, q, username)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	tags := make(map[string][]string)
+	for rows.Next() {
+		var url, tag string
+		if err := rows.Scan(&url, &tag); err != nil {
+			return nil, err
+		}
+
+		tags[url] = append(tags[url], tag)
+	}
+
+	return tags, rows.Err()
+}
+
+// Purge permanently deletes pages removed before the given time.
+func (r *RepositorySQLite) Purge(ctx context.Context, before time.Time) (int, error) {
+	q := `DELETE FROM page_tags WHERE EXISTS (
+    SELECT 1 FROM pages p WHERE p.url = page_tags.url AND p.username = page_tags.username AND p.deleted_at < ?
+)`
+
+	if _, err := r.db.ExecContext(ctx, q, before.Unix()); err != nil {
+		return 0, e.Wrap("can't purge pages", err)
+	}
+
+	res, err := r.db.ExecContext(ctx, `DELETE FROM pages WHERE deleted_at < ?`, before.Unix())
+	if err != nil {
+		return 0, e.Wrap("can't purge pages", err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, e.Wrap("can't purge pages", err)
+	}
+
+	return int(n), nil
+}
+
+// Stats calculates statistics of the user pages.
+func (r *RepositorySQLite) Stats(ctx context.Context, username string, now time.Time) (stats *repository.Stats, err error) {
+	defer func() {
+		err = e.WrapIfErr("can't get stats", err)
+	}()
+
+	stats = &repository.Stats{}
+
+	q := `SELECT COUNT(*),
+       COALESCE(SUM(read_at IS NULL), 0),
+       COALESCE(SUM(read_at >= ?), 0),
+       COALESCE(AVG(CASE WHEN read_at IS NULL THEN ? - created_at END), 0)
+FROM pages WHERE username = ? AND deleted_at IS NULL`
+
+	var avgAge float64
+
+	err = r.db.QueryRowContext(ctx, q, repository.WeekAgo(now).Unix(), now.Unix(), username).
+		Scan(&stats.Total, &stats.Unread, &stats.ReadThisWeek, &avgAge)
+	if err != nil {
+		return nil, err
+	}
+
+	stats.AvgUnreadAge = time.Duration(avgAge) * time.Second
+
+	q = `SELECT domain, COUNT(*) AS n FROM pages WHERE username = ? AND deleted_at IS NULL AND domain <> ''
+GROUP BY domain ORDER BY n DESC, domain LIMIT ?`
+
+	if stats.TopDomains, err = r.counts(ctx, q, username, repository.TopCountsLimit); err != nil {
+		return nil, err
+	}
+
+	q = `SELECT t.tag, COUNT(*) AS n FROM page_tags t
+JOIN pages p ON p.url = t.url AND p.username = t.username AND p.deleted_at IS NULL
+WHERE t.username = ?
+GROUP BY t.tag ORDER BY n DESC, t.tag LIMIT ?`
+
+	if stats.TopTags, err = r.counts(ctx, q, username, repository.TopCountsLimit); err != nil {
+		return nil, err
+	}
+
+	return stats, nil
+}
+
+// counts runs query returning name and count columns.
+func (r *RepositorySQLite) counts(ctx context.Context, q string, args ...any) ([]repository.Count, error) {
+	rows, err := r.db.QueryContext(ctx, q, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	var res []repository.Count
+	for rows.Next() {
+		var c repository.Count
+		if err := rows.Scan(&c.Name, &c.Count); err != nil {
+			return nil, err
+		}
+
+		res = append(res, c)
+	}
+
+	return res, rows.Err()
+}
+
 func (r *RepositorySQLite) Init(ctx context.Context) error {
 	q := `CREATE TABLE IF NOT EXISTS pages (url TEXT, username TEXT); 
 CREATE INDEX IF NOT EXISTS pages_username_idx ON pages (username);
@@ -90,5 +651,223 @@ CREATE INDEX IF NOT EXISTS pages_url_idx ON pages(url);`
 		return e.Wrap("can't create table", err)
 	}
 
+	columns := []struct {
+		name       string
+		definition string
+	}{
+		{name: "chat_id", definition: "INTEGER"},
+		{name: "resurface_at", definition: "INTEGER"},
+		{name: "created_at", definition: "INTEGER"},
+		{name: "review_interval", definition: "INTEGER"},
+		{name: "review_ease", definition: "REAL"},
+		{name: "review_repetitions", definition: "INTEGER"},
+		{name: "due_at", definition: "INTEGER"},
+		{name: "read_at", definition: "INTEGER"},
+		{name: "domain", definition: "TEXT"},
+		{name: "deleted_at", definition: "INTEGER"},
+	}
+
+	for _, c := range columns {
+		if err := r.addColumn(ctx, "pages", c.name, c.definition); err != nil {
+			return e.Wrap("can't migrate table", err)
+		}
+	}
+
+	q = `CREATE INDEX IF NOT EXISTS pages_resurface_at_idx ON pages(resurface_at);
+UPDATE pages SET created_at = strftime('%s', 'now') WHERE created_at IS NULL;
+CREATE TABLE IF NOT EXISTS page_tags (url TEXT, username TEXT, tag TEXT);
+CREATE INDEX IF NOT EXISTS page_tags_page_idx ON page_tags(username, url);
+CREATE TABLE IF NOT EXISTS collections (id TEXT PRIMARY KEY, name TEXT, owner TEXT);
+CREATE TABLE IF NOT EXISTS collection_members (collection_id TEXT, username TEXT, role TEXT, PRIMARY KEY (collection_id, username));
+CREATE INDEX IF NOT EXISTS collection_members_username_idx ON collection_members(username);
+CREATE TABLE IF NOT EXISTS collection_invites (code TEXT PRIMARY KEY, collection_id TEXT, role TEXT);
+CREATE TABLE IF NOT EXISTS banned_users (user_id INTEGER PRIMARY KEY);
+CREATE TABLE IF NOT EXISTS users (
+    id INTEGER PRIMARY KEY,
+    username TEXT,
+    first_name TEXT,
+    chat_id INTEGER,
+    first_seen INTEGER,
+    last_seen INTEGER,
+    blocked_at INTEGER
+);`
+
+	if _, err = r.db.ExecContext(ctx, q); err != nil {
+		return e.Wrap("can't migrate tables", err)
+	}
+
+	if err = r.fillDomains(ctx); err != nil {
+		return e.Wrap("can't migrate tables", err)
+	}
+
+	return nil
+}
+
+// fillDomains sets domain of pages saved before the column was introduced.
+func (r *RepositorySQLite) fillDomains(ctx context.Context) error {
+	rows, err := r.db.QueryContext(ctx, `SELECT DISTINCT url FROM pages WHERE domain IS NULL`)
+	if err != nil {
+		return err
+	}
+
+	var urls []string
+	for rows.Next() {
+		var url string
+		if err := rows.Scan(&url); err != nil {
+			_ = rows.Close()
+			return err
+		}
+
+		urls = append(urls, url)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	if err := rows.Close(); err != nil {
+		return err
+	}
+
+	for _, url := range urls {
+		p := repository.Page{URL: url}
+		if _, err := r.db.ExecContext(ctx, `UPDATE pages SET domain = ? WHERE url = ?`, p.Domain(), url); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// addColumn adds column to the table unless it already exists.
+func (r *RepositorySQLite) addColumn(ctx context.Context, table, column, definition string) error {
+	rows, err := r.db.QueryContext(ctx, fmt.Sprintf(`SELECT name FROM pragma_table_info('%s')`, table))
+	if err != nil {
+		return err
+	}
+	defer func() { _ = rows.Close() }()
+
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return err
+		}
+		if name == column {
+			return nil
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	q := fmt.Sprintf(`ALTER TABLE %s ADD COLUMN %s %s`, table, column, definition)
+
+	if _, err = r.db.ExecContext(ctx, q); err != nil {
+		return err
+	}
+
+	logging.FromContext(ctx).Info("sqlite: added column", "table", table, "column", column)
+
 	return nil
 }
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+// scanPage scans pageColumns.
+func scanPage(s scanner) (*repository.Page, error) {
+	var p repository.Page
+	var chatID, createdAt, resurfaceAt, interval, repetitions, dueAt, readAt, deletedAt sql.NullInt64
+	var ease sql.NullFloat64
+
+	err := s.Scan(&p.URL, &p.Username, &chatID, &createdAt, &resurfaceAt, &interval, &ease, &repetitions, &dueAt,
+		&readAt, &deletedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	p.ChatID = int(chatID.Int64)
+	p.CreatedAt = fromUnix(createdAt)
+	p.ResurfaceAt = fromUnix(resurfaceAt)
+	p.Review = repository.Review{
+		Interval:    int(interval.Int64),
+		Ease:        ease.Float64,
+		Repetitions: int(repetitions.Int64),
+		DueAt:       fromUnix(dueAt),
+	}
+	p.ReadAt = fromUnix(readAt)
+	p.DeletedAt = fromUnix(deletedAt)
+
+	return &p, nil
+}
+
+func fromUnix(t sql.NullInt64) time.Time {
+	if !t.Valid {
+		return time.Time{}
+	}
+
+	return time.Unix(t.Int64, 0)
+}
+
+// toUnix converts time to a nullable unix timestamp.
+func toUnix(t time.Time) sql.NullInt64 {
+	if t.IsZero() {
+		return sql.NullInt64{}
+	}
+
+	return sql.NullInt64{Int64: t.Unix(), Valid: true}
+}
+
+// Owners returns the keys of all lists which have pages.
+func (r *RepositorySQLite) Owners(ctx context.Context) (owners []string, err error) {
+	defer func() {
+		err = e.WrapIfErr("can't get owners", err)
+	}()
+
+	rows, err := r.db.QueryContext(ctx, `SELECT DISTINCT username FROM pages ORDER BY username`)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	for rows.Next() {
+		var owner string
+		if err := rows.Scan(&owner); err != nil {
+			return nil, err
+		}
+
+		owners = append(owners, owner)
+	}
+
+	return owners, rows.Err()
+}
+
+// EachPage calls fn for every page of the owner in url order.
+func (r *RepositorySQLite) EachPage(ctx context.Context, owner string, fn func(p *repository.Page) error) error {
+	// tags are read first, as fn may use the connection the rows hold
+	tags, err := r.userTags(ctx, owner)
+	if err != nil {
+		return e.Wrap("can't enumerate pages", err)
+	}
+
+	q := `SELECT ` + pageColumns + ` FROM pages WHERE username = ? ORDER BY url`
+
+	rows, err := r.db.QueryContext(ctx, q, owner)
+	if err != nil {
+		return e.Wrap("can't enumerate pages", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	for rows.Next() {
+		p, err := scanPage(rows)
+		if err != nil {
+			return e.Wrap("can't enumerate pages", err)
+		}
+		p.Tags = tags[p.URL]
+
+		if err := fn(p); err != nil {
+			return err
+		}
+	}
+
+	return e.WrapIfErr("can't enumerate pages", rows.Err())
+}
@@ -0,0 +1,24 @@
+package sqlite
+
+import (
+	"context"
+	"path/filepath"
+	"telegrambot/pkg/repository"
+	"telegrambot/pkg/repository/repositorytest"
+	"testing"
+)
+
+func TestRepository(t *testing.T) {
+	repositorytest.Run(t, func(t *testing.T) repository.Repository {
+		r, err := New(filepath.Join(t.TempDir(), "bot.db"))
+		if err != nil {
+			t.Fatalf("New: %v", err)
+		}
+		if err := r.Init(context.Background()); err != nil {
+			t.Fatalf("Init: %v", err)
+		}
+		t.Cleanup(func() { _ = r.db.Close() })
+
+		return r
+	})
+}
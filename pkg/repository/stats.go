@@ -0,0 +1,74 @@
+package repository
+
+import (
+	"sort"
+	"time"
+)
+
+// CalcStats calculates statistics by scanning all pages of a user.
+func CalcStats(pages []*Page, now time.Time) *Stats {
+	var stats Stats
+	var unreadAge time.Duration
+
+	domains := make(map[string]int)
+	tags := make(map[string]int)
+
+	for _, p := range pages {
+		if p.IsDeleted() {
+			continue
+		}
+
+		stats.Total++
+
+		if p.IsRead() {
+			if !p.ReadAt.Before(WeekAgo(now)) {
+				stats.ReadThisWeek++
+			}
+		} else {
+			stats.Unread++
+			unreadAge += now.Sub(p.CreatedAt)
+		}
+
+		if d := p.Domain(); d != "" {
+			domains[d]++
+		}
+
+		for _, t := range p.Tags {
+			tags[t]++
+		}
+	}
+
+	if stats.Unread > 0 {
+		stats.AvgUnreadAge = unreadAge / time.Duration(stats.Unread)
+	}
+
+	stats.TopDomains = topCounts(domains)
+	stats.TopTags = topCounts(tags)
+
+	return &stats
+}
+
+// WeekAgo returns the start of the period counted in Stats.ReadThisWeek.
+func WeekAgo(now time.Time) time.Time {
+	return now.Add(-week)
+}
+
+func topCounts(counts map[string]int) []Count {
+	res := make([]Count, 0, len(counts))
+	for name, n := range counts {
+		res = append(res, Count{Name: name, Count: n})
+	}
+
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].Count != res[j].Count {
+			return res[i].Count > res[j].Count
+		}
+		return res[i].Name < res[j].Name
+	})
+
+	if len(res) > TopCountsLimit {
+		res = res[:TopCountsLimit]
+	}
+
+	return res
+}
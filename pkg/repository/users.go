@@ -0,0 +1,44 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Users is the registry of Telegram users who have written to the bot.
+type Users interface {
+	// TouchUser saves the user or updates the saved one. A zero ChatID keeps
+	// the saved chat. A user who writes to the private chat again is no
+	// longer considered to have blocked the bot.
+	TouchUser(ctx context.Context, u *User) error
+	// Users returns all known users ordered by id.
+	Users(ctx context.Context) ([]*User, error)
+	MarkBlocked(ctx context.Context, userID int, at time.Time) error
+}
+
+type User struct {
+	ID        int
+	Username  string
+	FirstName string
+	// ChatID is the private chat with the user, zero if the user has only
+	// written in group chats.
+	ChatID    int
+	FirstSeen time.Time
+	LastSeen  time.Time
+	// BlockedAt is zero unless the user has blocked the bot.
+	BlockedAt time.Time
+}
+
+// PagesOwner returns the key the personal list of the user is stored under.
+func (u *User) PagesOwner() string {
+	if u.Username != "" {
+		return u.Username
+	}
+
+	return fmt.Sprintf("user-%d", u.ID)
+}
+
+func (u *User) IsBlocked() bool {
+	return !u.BlockedAt.IsZero()
+}
@@ -0,0 +1,60 @@
+package scheduler
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"telegrambot/internal/logging"
+	"time"
+)
+
+type Job func(ctx context.Context) error
+
+type task struct {
+	name     string
+	interval time.Duration
+	job      Job
+}
+
+type Scheduler struct {
+	tasks []task
+}
+
+func New() *Scheduler {
+	return &Scheduler{}
+}
+
+// Every registers job which is run once per interval.
+func (s *Scheduler) Every(interval time.Duration, name string, job Job) {
+	s.tasks = append(s.tasks, task{name: name, interval: interval, job: job})
+}
+
+// Run runs registered jobs until ctx is canceled.
+func (s *Scheduler) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, t := range s.tasks {
+		wg.Add(1)
+		go func(t task) {
+			defer wg.Done()
+			t.run(ctx)
+		}(t)
+	}
+
+	wg.Wait()
+}
+
+func (t task) run(ctx context.Context) {
+	ticker := time.NewTicker(t.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := t.job(ctx); err != nil {
+				slog.Error("scheduler: job failed", "job", t.name, logging.Err(err))
+			}
+		}
+	}
+}
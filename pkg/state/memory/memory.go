@@ -0,0 +1,93 @@
+package memory
+
+import (
+	"context"
+	"sync"
+	"telegrambot/pkg/state"
+	"time"
+)
+
+// sweepInterval is how often expired values are removed.
+const sweepInterval = time.Minute
+
+// CacheMemory keeps state in memory, so it is lost on restart.
+type CacheMemory struct {
+	mu        sync.Mutex
+	values    map[string]string
+	expires   map[string]time.Time
+	lastSweep time.Time
+}
+
+func New() *CacheMemory {
+	return &CacheMemory{
+		values:  make(map[string]string),
+		expires: make(map[string]time.Time),
+	}
+}
+
+func (c *CacheMemory) GetState(ctx context.Context, key string) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if expiresAt, ok := c.expires[key]; ok && !time.Now().Before(expiresAt) {
+		c.delete(key)
+	}
+
+	value, ok := c.values[key]
+	if !ok {
+		return "", state.ErrNotFound
+	}
+
+	return value, nil
+}
+
+func (c *CacheMemory) SetState(ctx context.Context, key string, value string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.values[key] = value
+	delete(c.expires, key)
+
+	return nil
+}
+
+func (c *CacheMemory) SetStateTTL(ctx context.Context, key string, value string, ttl time.Duration) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	c.sweep(now)
+
+	c.values[key] = value
+	c.expires[key] = now.Add(ttl)
+
+	return nil
+}
+
+func (c *CacheMemory) DeleteState(ctx context.Context, key string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.delete(key)
+
+	return nil
+}
+
+func (c *CacheMemory) delete(key string) {
+	delete(c.values, key)
+	delete(c.expires, key)
+}
+
+// sweep removes expired values which are never read again.
+func (c *CacheMemory) sweep(now time.Time) {
+	if now.Sub(c.lastSweep) < sweepInterval {
+		return
+	}
+	c.lastSweep = now
+
+	for key, expiresAt := range c.expires {
+		if !now.Before(expiresAt) {
+			c.delete(key)
+		}
+	}
+}
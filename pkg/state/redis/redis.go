@@ -2,20 +2,23 @@ package redis
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"github.com/redis/go-redis/v9"
 	"telegrambot/internal/config"
+	"telegrambot/pkg/state"
+	"time"
 )
 
 type RepositoryRedis struct {
 	db *redis.Client
 }
 
-func New(config *config.Config) *RepositoryRedis {
+func New(config config.RedisConfig) *RepositoryRedis {
 	db := redis.NewClient(&redis.Options{
-		Addr:     fmt.Sprintf("%s:%d", config.RedisAddr, config.RedisPort),
-		Password: config.RedisPassword,
-		DB:       config.RedisDB,
+		Addr:     fmt.Sprintf("%s:%d", config.Addr, config.Port),
+		Password: config.Password.Value(),
+		DB:       config.DB,
 	})
 
 	db.Ping(context.Background())
@@ -23,14 +26,27 @@ func New(config *config.Config) *RepositoryRedis {
 	return &RepositoryRedis{db: db}
 }
 
+func (r *RepositoryRedis) Ping(ctx context.Context) error {
+	return r.db.Ping(ctx).Err()
+}
+
 func (r *RepositoryRedis) GetState(ctx context.Context, key string) (string, error) {
-	return r.db.Get(ctx, key).Result()
+	res, err := r.db.Get(ctx, key).Result()
+	if errors.Is(err, redis.Nil) {
+		return "", state.ErrNotFound
+	}
+
+	return res, err
 }
 
 func (r *RepositoryRedis) SetState(ctx context.Context, key string, value string) error {
 	return r.db.Set(ctx, key, value, 0).Err()
 }
 
+func (r *RepositoryRedis) SetStateTTL(ctx context.Context, key string, value string, ttl time.Duration) error {
+	return r.db.Set(ctx, key, value, ttl).Err()
+}
+
 func (r *RepositoryRedis) DeleteState(ctx context.Context, key string) error {
 	return r.db.Del(ctx, key).Err()
 }
@@ -0,0 +1,138 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"sync"
+	"telegrambot/internal/e"
+	"telegrambot/pkg/state"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+// sweepInterval is how often expired values are removed.
+const sweepInterval = time.Minute
+
+// CacheSQLite keeps state in a SQLite table, so that it survives restarts
+// without running Redis.
+type CacheSQLite struct {
+	db *sql.DB
+
+	mu        sync.Mutex
+	lastSweep time.Time
+}
+
+// New opens the database and creates the state table.
+func New(ctx context.Context, path string) (*CacheSQLite, error) {
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, e.Wrap("can't open sqlite db", err)
+	}
+
+	// expires_at is in unix milliseconds, NULL for values which never expire
+	q := `CREATE TABLE IF NOT EXISTS state (key TEXT PRIMARY KEY, value TEXT NOT NULL, expires_at INTEGER)`
+
+	if _, err := db.ExecContext(ctx, q); err != nil {
+		return nil, e.Wrap("can't create state table", err)
+	}
+
+	if err := addExpiresAt(ctx, db); err != nil {
+		return nil, e.Wrap("can't create state table", err)
+	}
+
+	return &CacheSQLite{db: db}, nil
+}
+
+// addExpiresAt upgrades the state table of an older version.
+func addExpiresAt(ctx context.Context, db *sql.DB) error {
+	var n int
+
+	q := `SELECT COUNT(*) FROM pragma_table_info('state') WHERE name = 'expires_at'`
+
+	if err := db.QueryRowContext(ctx, q).Scan(&n); err != nil {
+		return err
+	}
+
+	if n > 0 {
+		return nil
+	}
+
+	_, err := db.ExecContext(ctx, `ALTER TABLE state ADD COLUMN expires_at INTEGER`)
+
+	return err
+}
+
+func (c *CacheSQLite) Ping(ctx context.Context) error {
+	return c.db.PingContext(ctx)
+}
+
+func (c *CacheSQLite) GetState(ctx context.Context, key string) (string, error) {
+	var value string
+
+	q := `SELECT value FROM state WHERE key = ? AND (expires_at IS NULL OR expires_at > ?)`
+
+	err := c.db.QueryRowContext(ctx, q, key, time.Now().UnixMilli()).Scan(&value)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", state.ErrNotFound
+	}
+	if err != nil {
+		return "", e.Wrap("can't get state", err)
+	}
+
+	return value, nil
+}
+
+func (c *CacheSQLite) SetState(ctx context.Context, key string, value string) error {
+	q := `INSERT INTO state (key, value, expires_at) VALUES (?, ?, NULL)
+ON CONFLICT(key) DO UPDATE SET value = excluded.value, expires_at = NULL`
+
+	if _, err := c.db.ExecContext(ctx, q, key, value); err != nil {
+		return e.Wrap("can't set state", err)
+	}
+
+	return nil
+}
+
+func (c *CacheSQLite) SetStateTTL(ctx context.Context, key string, value string, ttl time.Duration) error {
+	now := time.Now()
+
+	if err := c.sweep(ctx, now); err != nil {
+		return e.Wrap("can't set state", err)
+	}
+
+	q := `INSERT INTO state (key, value, expires_at) VALUES (?, ?, ?)
+ON CONFLICT(key) DO UPDATE SET value = excluded.value, expires_at = excluded.expires_at`
+
+	if _, err := c.db.ExecContext(ctx, q, key, value, now.Add(ttl).UnixMilli()); err != nil {
+		return e.Wrap("can't set state", err)
+	}
+
+	return nil
+}
+
+func (c *CacheSQLite) DeleteState(ctx context.Context, key string) error {
+	if _, err := c.db.ExecContext(ctx, `DELETE FROM state WHERE key = ?`, key); err != nil {
+		return e.Wrap("can't delete state", err)
+	}
+
+	return nil
+}
+
+// sweep removes expired values which are never read again.
+func (c *CacheSQLite) sweep(ctx context.Context, now time.Time) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if now.Sub(c.lastSweep) < sweepInterval {
+		return nil
+	}
+
+	if _, err := c.db.ExecContext(ctx, `DELETE FROM state WHERE expires_at <= ?`, now.UnixMilli()); err != nil {
+		return err
+	}
+	c.lastSweep = now
+
+	return nil
+}
@@ -1,9 +1,18 @@
 package state
 
-import "context"
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+var ErrNotFound = errors.New("state not found")
 
 type Cache interface {
+	// GetState returns ErrNotFound if there is no value for the key.
 	GetState(ctx context.Context, key string) (string, error)
 	SetState(ctx context.Context, key string, value string) error
+	// SetStateTTL sets a value which is removed after ttl.
+	SetStateTTL(ctx context.Context, key string, value string, ttl time.Duration) error
 	DeleteState(ctx context.Context, key string) error
 }